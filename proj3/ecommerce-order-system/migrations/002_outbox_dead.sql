@@ -0,0 +1,18 @@
+-- 002_outbox_dead.sql
+
+alter table outbox_events add column if not exists dead_at timestamptz;
+
+drop index if exists outbox_events_pending_idx;
+drop index if exists outbox_events_unsent_idx;
+
+create index if not exists outbox_events_pending_idx
+  on outbox_events (next_attempt_at)
+  where sent_at is null and dead_at is null;
+
+create index if not exists outbox_events_unsent_idx
+  on outbox_events (created_at)
+  where sent_at is null and dead_at is null;
+
+create index if not exists outbox_events_dead_idx
+  on outbox_events (dead_at)
+  where dead_at is not null;
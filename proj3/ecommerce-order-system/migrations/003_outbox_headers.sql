@@ -0,0 +1,3 @@
+-- 003_outbox_headers.sql
+
+alter table outbox_events add column if not exists headers jsonb not null default '{}'::jsonb;
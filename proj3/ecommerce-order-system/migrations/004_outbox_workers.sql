@@ -0,0 +1,11 @@
+-- 004_outbox_workers.sql
+
+create table if not exists outbox_workers (
+  replica_id text primary key,
+  mode text not null,
+  role text not null,
+  shard int not null default 0,
+  shards int not null default 0,
+  started_at timestamptz not null default now(),
+  heartbeat_at timestamptz not null default now()
+);
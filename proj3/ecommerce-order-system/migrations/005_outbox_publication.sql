@@ -0,0 +1,10 @@
+-- 005_outbox_publication.sql
+-- Used by outbox-worker in OUTBOX_RELAY=cdc mode (requires wal_level=logical).
+
+do $$
+begin
+  if not exists (select 1 from pg_publication where pubname = 'outbox_pub') then
+    create publication outbox_pub for table outbox_events with (publish = 'insert');
+  end if;
+end
+$$;
@@ -0,0 +1,3 @@
+-- 006_orders_currency.sql
+
+alter table orders add column if not exists currency text not null default 'USD';
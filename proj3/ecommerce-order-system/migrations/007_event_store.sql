@@ -0,0 +1,32 @@
+-- 007_event_store.sql
+
+-- Append-only log of every event seen on orders.events, numbered per order.
+create table if not exists event_store (
+  order_id text not null,
+  seq bigint not null,
+  event_id text not null,
+  event_type text not null,
+  version int not null,
+  occurred_at timestamptz not null,
+  stored_at timestamptz not null default now(),
+  correlation_id text,
+  causation_id text,
+  envelope jsonb not null,
+  primary key (order_id, seq),
+  unique (event_id)
+);
+
+create index if not exists event_store_correlation_idx
+  on event_store (correlation_id)
+  where correlation_id is not null;
+
+create or replace function event_store_append_only() returns trigger as $$
+begin
+  raise exception 'event_store is append-only';
+end;
+$$ language plpgsql;
+
+drop trigger if exists event_store_append_only on event_store;
+create trigger event_store_append_only
+  before update or delete on event_store
+  for each row execute function event_store_append_only();
@@ -0,0 +1,22 @@
+-- 008_sagas.sql
+
+-- State of orchestrated order sagas (SAGA_MODE=orchestration).
+create table if not exists sagas (
+  order_id uuid primary key,
+  definition text not null,
+  status text not null,
+  step int not null default 0,
+  step_name text not null default '',
+  deadline_at timestamptz,
+  completed_steps text[] not null default '{}',
+  compensated_steps text[] not null default '{}',
+  failure_reason text,
+  correlation_id text,
+  data jsonb not null,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+
+create index if not exists sagas_active_deadline_idx
+  on sagas (deadline_at)
+  where status in ('running', 'compensating');
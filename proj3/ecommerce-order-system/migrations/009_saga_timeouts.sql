@@ -0,0 +1,4 @@
+-- 009_saga_timeouts.sql
+
+-- Step whose deadline expired, if the saga was aborted by a timeout.
+alter table sagas add column if not exists timed_out_step text;
@@ -0,0 +1,22 @@
+-- 010_event_sequence.sql
+
+-- Highest envelope seq applied to the order's status by order-status-service.
+alter table orders add column if not exists last_seq bigint not null default 0;
+
+-- Events that arrived before their cause; applied once the gap is filled or
+-- after ORDER_STATUS_REORDER_TIMEOUT.
+create table if not exists order_status_pending (
+  order_id uuid not null,
+  event_id text not null,
+  event_type text not null,
+  seq bigint not null,
+  status text not null default '',
+  received_at timestamptz not null default now(),
+  primary key (order_id, event_id)
+);
+
+create index if not exists order_status_pending_received_idx
+  on order_status_pending (received_at);
+
+-- Seq of the last message saga-orchestrator published for the order.
+alter table sagas add column if not exists last_seq bigint not null default 0;
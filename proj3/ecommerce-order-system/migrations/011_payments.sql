@@ -0,0 +1,22 @@
+-- 011_payments.sql
+
+-- One payment per order: authorized on reservation, captured after shipping
+-- is scheduled, voided on cancellation or expired when never captured.
+create table if not exists payments (
+  order_id uuid primary key,
+  authorization_id text not null unique,
+  status text not null,
+  amount_cents bigint not null default 0,
+  currency text not null default '',
+  expires_at timestamptz not null,
+  correlation_id text,
+  seq bigint not null default 0,
+  authorized_at timestamptz not null default now(),
+  captured_at timestamptz,
+  voided_at timestamptz,
+  updated_at timestamptz not null default now()
+);
+
+create index if not exists payments_authorized_expiry_idx
+  on payments (expires_at)
+  where status = 'authorized';
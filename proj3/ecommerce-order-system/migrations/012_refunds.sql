@@ -0,0 +1,19 @@
+-- 012_refunds.sql
+
+-- Refunds against captured payments. Only refunded rows count towards the
+-- captured amount; failed ones keep the reason.
+create table if not exists refunds (
+  refund_id uuid primary key,
+  order_id uuid not null,
+  status text not null,
+  amount_cents bigint not null,
+  reason text,
+  failure_reason text,
+  items jsonb not null default '[]',
+  created_at timestamptz not null default now()
+);
+
+create index if not exists refunds_order_idx on refunds (order_id);
+
+-- Refund total shown on the order detail, maintained by order-status-service.
+alter table orders add column if not exists refunded_cents bigint not null default 0;
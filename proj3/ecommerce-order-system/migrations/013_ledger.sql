@@ -0,0 +1,77 @@
+-- 013_ledger.sql
+
+-- Double-entry ledger of payment-service. Every journal entry has postings
+-- that sum to zero (debits positive, credits negative); entries and postings
+-- are never changed once written.
+create table if not exists ledger_accounts (
+  code text primary key,
+  name text not null,
+  type text not null check (type in ('asset', 'liability', 'revenue', 'expense'))
+);
+
+insert into ledger_accounts (code, name, type) values
+  ('authorization_holds', 'Funds held by authorizations', 'asset'),
+  ('authorization_commitments', 'Authorized funds not yet captured', 'liability'),
+  ('psp_receivable', 'Due from the payment provider', 'asset'),
+  ('sales', 'Captured sales', 'revenue'),
+  ('refunds', 'Refunds to customers', 'expense'),
+  ('psp_fees', 'Payment provider fees', 'expense')
+on conflict (code) do nothing;
+
+-- kind + ref identify the business fact (e.g. capture of an authorization),
+-- so an entry is posted at most once however often it is retried.
+create table if not exists ledger_entries (
+  id uuid primary key,
+  kind text not null,
+  ref text not null,
+  order_id uuid not null,
+  customer_id text not null default '',
+  currency text not null,
+  created_at timestamptz not null default now(),
+  unique (kind, ref)
+);
+
+create index if not exists ledger_entries_order_idx on ledger_entries (order_id, created_at);
+create index if not exists ledger_entries_customer_idx on ledger_entries (customer_id, created_at);
+create index if not exists ledger_entries_created_idx on ledger_entries (created_at);
+
+create table if not exists ledger_postings (
+  id bigserial primary key,
+  entry_id uuid not null references ledger_entries(id),
+  account_code text not null references ledger_accounts(code),
+  amount_cents bigint not null check (amount_cents <> 0)
+);
+
+create index if not exists ledger_postings_entry_idx on ledger_postings (entry_id);
+
+-- Checked at commit, once all postings of the entry are in.
+create or replace function ledger_entry_balanced() returns trigger as $$
+begin
+  if (select sum(amount_cents) from ledger_postings where entry_id = new.entry_id) <> 0 then
+    raise exception 'ledger entry % does not balance', new.entry_id;
+  end if;
+  return null;
+end;
+$$ language plpgsql;
+
+drop trigger if exists ledger_postings_balanced on ledger_postings;
+create constraint trigger ledger_postings_balanced
+  after insert on ledger_postings
+  deferrable initially deferred
+  for each row execute function ledger_entry_balanced();
+
+create or replace function ledger_append_only() returns trigger as $$
+begin
+  raise exception 'ledger is append-only';
+end;
+$$ language plpgsql;
+
+drop trigger if exists ledger_entries_append_only on ledger_entries;
+create trigger ledger_entries_append_only
+  before update or delete on ledger_entries
+  for each row execute function ledger_append_only();
+
+drop trigger if exists ledger_postings_append_only on ledger_postings;
+create trigger ledger_postings_append_only
+  before update or delete on ledger_postings
+  for each row execute function ledger_append_only();
@@ -0,0 +1,24 @@
+-- 014_fraud.sql
+
+-- Orders as seen by fraud-service (recorded from orders.created) and the
+-- screening decision, made once per order.
+create table if not exists fraud_screenings (
+  order_id uuid primary key,
+  user_id text not null,
+  email text not null,
+  total_cents bigint not null,
+  currency text not null,
+  skus text[] not null default '{}',
+  billing_country text not null default '',
+  shipping_country text not null default '',
+  ip_country text not null default '',
+  created_at timestamptz not null default now(),
+  decision text,
+  score int,
+  reasons jsonb,
+  screened_at timestamptz
+);
+
+-- velocity rules count recent orders per user and per email
+create index if not exists fraud_screenings_user_idx on fraud_screenings (user_id, created_at);
+create index if not exists fraud_screenings_email_idx on fraud_screenings (email, created_at);
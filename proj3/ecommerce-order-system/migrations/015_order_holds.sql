@@ -0,0 +1,22 @@
+-- 015_order_holds.sql
+
+-- Orders waiting for manual review. correlation_id and seq are the links of
+-- the order.held event, so the resolution published later from the admin API
+-- or the deadline sweep stays in the order's chain.
+create table if not exists order_holds (
+  order_id uuid primary key,
+  status text not null,
+  reason text not null,
+  reasons jsonb not null default '[]',
+  deadline_at timestamptz not null,
+  correlation_id text,
+  seq bigint not null default 0,
+  created_at timestamptz not null default now(),
+  resolved_at timestamptz,
+  resolution_reason text,
+  resolved_by text
+);
+
+create index if not exists order_holds_held_deadline_idx
+  on order_holds (deadline_at)
+  where status = 'held';
@@ -0,0 +1,22 @@
+-- 016_payment_webhooks.sql
+
+-- Authorizations the provider confirms later stay pending or requires_action
+-- until its webhook arrives; expires_at is then the confirmation deadline.
+-- Failed ones keep the reason; action_url is where the customer completes
+-- 3-D Secure.
+alter table payments add column if not exists failure_reason text;
+alter table payments add column if not exists action_url text;
+
+create index if not exists payments_unconfirmed_expiry_idx
+  on payments (expires_at)
+  where status in ('pending', 'requires_action');
+
+-- Every provider webhook applied, by its id, so a redelivered webhook is
+-- acknowledged without being applied twice.
+create table if not exists psp_webhook_events (
+  event_id text primary key,
+  type text not null,
+  reference text not null,
+  result text not null,
+  received_at timestamptz not null default now()
+);
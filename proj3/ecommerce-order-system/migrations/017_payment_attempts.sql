@@ -0,0 +1,32 @@
+-- 017_payment_attempts.sql
+
+-- A soft decline (or a provider that stayed unreachable) leaves the payment
+-- declined until expires_at, the window for the customer to retry with
+-- another payment method. Such payments may have no authorization id.
+alter table payments alter column authorization_id drop not null;
+
+drop index if exists payments_unconfirmed_expiry_idx;
+create index if not exists payments_open_expiry_idx
+  on payments (expires_at)
+  where status in ('pending', 'requires_action', 'declined');
+
+-- Every call to the provider. attempt numbers the customer's attempts (the
+-- first authorization, then each retry with a new payment method); try
+-- numbers the calls within one attempt, repeated on transient errors and
+-- timeouts. request_id is the retry request that started the attempt.
+create table if not exists payment_attempts (
+  id bigserial primary key,
+  order_id uuid not null,
+  attempt int not null,
+  try int not null,
+  request_id text,
+  payment_method text not null default '',
+  outcome text not null,
+  reason text,
+  reference text,
+  duration_ms bigint not null,
+  created_at timestamptz not null default now()
+);
+
+create index if not exists payment_attempts_order_idx on payment_attempts (order_id, id);
+create index if not exists payment_attempts_request_idx on payment_attempts (request_id) where request_id is not null;
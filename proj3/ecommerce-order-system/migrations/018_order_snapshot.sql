@@ -0,0 +1,11 @@
+-- 018_order_snapshot.sql
+
+-- Where the order ships to, as given at checkout; null for orders placed
+-- without one.
+alter table orders add column if not exists shipping_address jsonb;
+
+-- The order snapshot carried by fraud.passed (fraud-service keeps it from
+-- orders.created, so a hold approved later still has it) and by
+-- payment.authorized (payment-service keeps the one it charged).
+alter table fraud_screenings add column if not exists order_snapshot jsonb;
+alter table payments add column if not exists order_snapshot jsonb;
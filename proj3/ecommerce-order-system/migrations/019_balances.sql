@@ -0,0 +1,37 @@
+-- 019_balances.sql
+
+-- Gift cards and store credit. A gift card is found by its code, store
+-- credit by the customer's user id; either holds one currency and never
+-- goes below zero.
+create table if not exists balance_accounts (
+  id bigserial primary key,
+  kind text not null check (kind in ('gift_card', 'store_credit')),
+  code text not null,
+  currency text not null,
+  balance_cents bigint not null default 0 check (balance_cents >= 0),
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now(),
+  unique (kind, code)
+);
+
+-- Every change of a balance. idempotency_key makes each operation happen
+-- once however often it is retried; a reversal points at the redemption it
+-- returns (partly, for refunds).
+create table if not exists balance_transactions (
+  id bigserial primary key,
+  account_id bigint not null references balance_accounts(id),
+  kind text not null check (kind in ('issue', 'redeem', 'reverse')),
+  amount_cents bigint not null check (amount_cents > 0),
+  order_id uuid,
+  redemption_id bigint references balance_transactions(id),
+  idempotency_key text not null unique,
+  reason text,
+  created_at timestamptz not null default now()
+);
+
+create index if not exists balance_transactions_account_idx on balance_transactions (account_id, created_at);
+create index if not exists balance_transactions_order_idx on balance_transactions (order_id) where order_id is not null;
+
+-- The part of an order paid from balances; amount_cents stays the card's.
+alter table payments add column if not exists balance_cents int not null default 0;
+alter table refunds add column if not exists balance_cents int not null default 0;
@@ -0,0 +1,19 @@
+-- 020_shipments.sql
+
+-- The label bought for an order: one per order, kept when the order is
+-- cancelled (status cancelled) so the carrier's charge can be reconciled.
+create table if not exists shipments (
+  order_id uuid primary key,
+  carrier text not null,
+  service_level text not null,
+  tracking_number text not null,
+  label_url text,
+  cost_cents int not null check (cost_cents >= 0),
+  currency text not null,
+  status text not null default 'created' check (status in ('created', 'cancelled')),
+  estimated_delivery timestamptz,
+  created_at timestamptz not null default now(),
+  cancelled_at timestamptz,
+  cancel_reason text,
+  unique (carrier, tracking_number)
+);
@@ -0,0 +1,5 @@
+-- 021_saga_compensation_attempts.sql
+
+-- Times the current compensation command was sent again after its deadline;
+-- a saga whose compensation keeps timing out ends in status 'failed'.
+alter table sagas add column if not exists compensation_attempts int not null default 0;
@@ -0,0 +1,14 @@
+-- 022_refund_requests.sql
+
+-- Refund requests accepted by api-gateway, with the items each one asked for,
+-- so an item is never refunded more times than it was ordered. A request sent
+-- again with the same Idempotency-Key returns the stored one.
+create table if not exists refund_requests (
+  refund_id uuid primary key,
+  order_id uuid not null references orders(id) on delete cascade,
+  idempotency_key text,
+  amount_cents bigint not null,
+  items jsonb not null default '[]',
+  created_at timestamptz not null default now(),
+  unique (order_id, idempotency_key)
+);
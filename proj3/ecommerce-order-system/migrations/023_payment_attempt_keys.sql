@@ -0,0 +1,12 @@
+-- 023_payment_attempt_keys.sql
+
+-- idempotency_key is the key every call of an attempt sends to the provider,
+-- so an attempt continues under the same key after a redelivery. A call is
+-- recorded as 'started' before it is made and gets its outcome once the
+-- provider answered; a 'started' row left behind is a call whose answer was
+-- lost.
+alter table payment_attempts add column if not exists idempotency_key text;
+
+create index if not exists payment_attempts_key_idx
+  on payment_attempts (order_id, idempotency_key)
+  where idempotency_key is not null;
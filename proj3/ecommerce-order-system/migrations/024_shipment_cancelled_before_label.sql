@@ -0,0 +1,11 @@
+-- 024_shipment_cancelled_before_label.sql
+
+-- An order cancelled before its label was bought gets a shipments row with
+-- status cancelled and no label, so a schedule request arriving after the
+-- cancellation buys none.
+alter table shipments
+  alter column carrier drop not null,
+  alter column service_level drop not null,
+  alter column tracking_number drop not null,
+  alter column currency drop not null,
+  alter column cost_cents set default 0;
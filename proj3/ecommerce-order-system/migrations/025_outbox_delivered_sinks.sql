@@ -0,0 +1,5 @@
+-- 025_outbox_delivered_sinks.sql
+
+-- The sinks that already took a row. A row fanned out to several sinks is
+-- retried only on those that failed, so the others do not get it twice.
+alter table outbox_events add column if not exists delivered_sinks text[] not null default '{}';
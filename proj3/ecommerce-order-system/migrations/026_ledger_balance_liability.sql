@@ -0,0 +1,8 @@
+-- 026_ledger_balance_liability.sql
+
+-- Gift card and store credit balances owed to customers. A capture books the
+-- redeemed share of the sale against it, a refund returned to balances books
+-- it back.
+insert into ledger_accounts (code, name, type) values
+  ('balance_liability', 'Gift card and store credit balances', 'liability')
+on conflict (code) do nothing;
@@ -35,22 +35,47 @@ func main() {
 
 	ordersRepo := &repo.OrdersPG{DB: db}
 
+	clientCountry, err := handlers.NewClientCountry(cfg.HTTP.ClientCountryHeader, cfg.HTTP.TrustedProxies)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid HTTP_TRUSTED_PROXIES")
+	}
 	create := &handlers.CreateOrderHandler{
 		DB:     db,
 		Outbox: &repo.OutboxPG{},
 		Log:    log,
+
+		DualPublish:   cfg.Events.DualPublish,
+		ClientCountry: clientCountry,
 	}
 
 	get := &handlers.GetOrderHandler{
-		GetStatus: func(r *http.Request, orderID string) (string, error) {
-			return ordersRepo.GetStatus(r.Context(), orderID)
+		GetOrder: func(r *http.Request, orderID string) (repo.Order, error) {
+			return ordersRepo.Get(r.Context(), orderID)
 		},
 	}
 
+	refund := &handlers.CreateRefundHandler{
+		DB:       db,
+		Orders:   ordersRepo,
+		Requests: &repo.RefundRequestsPG{},
+		Outbox:   &repo.OutboxPG{},
+		Log:      log,
+	}
+
+	paymentAttempt := &handlers.CreatePaymentAttemptHandler{
+		DB:     db,
+		Orders: ordersRepo,
+		Outbox: &repo.OutboxPG{},
+		Log:    log,
+	}
+
 	router := httpx.NewRouter(&httpx.Handlers{
-		Health:      handlers.Health,
-		CreateOrder: create.ServeHTTP,
-		GetOrder:    get.ServeHTTP,
+		Health:       handlers.Health,
+		CreateOrder:  create.ServeHTTP,
+		GetOrder:     get.ServeHTTP,
+		CreateRefund: refund.ServeHTTP,
+
+		CreatePaymentAttempt: paymentAttempt.ServeHTTP,
 	})
 
 	srv := &http.Server{
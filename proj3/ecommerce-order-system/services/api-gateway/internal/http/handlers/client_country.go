@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ClientCountry reads the client's country from Header, which the edge proxy
+// sets from the client address. Anyone can send the header, so it is only
+// taken from a peer inside Trusted; otherwise the country is unknown ("").
+type ClientCountry struct {
+	Header  string
+	Trusted []netip.Prefix
+}
+
+// NewClientCountry parses the trusted proxy CIDRs (validated by config).
+func NewClientCountry(header string, trusted []string) (*ClientCountry, error) {
+	c := &ClientCountry{Header: header}
+	for _, s := range trusted {
+		p, err := netip.ParsePrefix(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		c.Trusted = append(c.Trusted, p.Masked())
+	}
+	return c, nil
+}
+
+// Of returns the ISO 3166 alpha-2 country of the client of r, or "".
+func (c *ClientCountry) Of(r *http.Request) string {
+	if c == nil || c.Header == "" || !c.fromProxy(r.RemoteAddr) {
+		return ""
+	}
+	country := strings.ToUpper(strings.TrimSpace(r.Header.Get(c.Header)))
+	if len(country) != 2 {
+		return ""
+	}
+	return country
+}
+
+func (c *ClientCountry) fromProxy(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range c.Trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
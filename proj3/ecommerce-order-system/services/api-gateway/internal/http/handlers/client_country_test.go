@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientCountryOf(t *testing.T) {
+	cc, err := NewClientCountry("X-Client-Country", []string{"10.0.0.0/8", " fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		remote string
+		header string
+		want   string
+	}{
+		{"trusted proxy", "10.1.2.3:5000", "de", "DE"},
+		{"trusted ipv6 proxy", "[fd00::1]:5000", "FR", "FR"},
+		{"ipv4-mapped trusted proxy", "[::ffff:10.0.0.1]:5000", "PL", "PL"},
+		{"untrusted client", "203.0.113.7:5000", "DE", ""},
+		{"trusted proxy without header", "10.1.2.3:5000", "", ""},
+		{"not a country", "10.1.2.3:5000", "Germany", ""},
+		{"unparsable peer", "proxy:5000", "DE", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/v1/orders", nil)
+			r.RemoteAddr = tt.remote
+			if tt.header != "" {
+				r.Header.Set("X-Client-Country", tt.header)
+			}
+			if got := cc.Of(r); got != tt.want {
+				t.Fatalf("Of = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientCountryTrustsNobodyByDefault(t *testing.T) {
+	cc, err := NewClientCountry("X-Client-Country", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("POST", "/api/v1/orders", nil)
+	r.RemoteAddr = "127.0.0.1:5000"
+	r.Header.Set("X-Client-Country", "DE")
+	if got := cc.Of(r); got != "" {
+		t.Fatalf("Of = %q, want the header ignored", got)
+	}
+}
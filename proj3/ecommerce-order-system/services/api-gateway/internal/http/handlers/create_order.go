@@ -3,7 +3,10 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"ecommerce-order-system/services/api-gateway/internal/repo"
@@ -18,12 +21,26 @@ type CreateOrderHandler struct {
 	DB     *pgxpool.Pool
 	Outbox *repo.OutboxPG
 	Log    zerolog.Logger
+
+	// DualPublish maps event type to an extra payload version to enqueue as well.
+	DualPublish map[string]int
+	// ClientCountry resolves the ip_country checked by fraud screening.
+	ClientCountry *ClientCountry
 }
 
 type createOrderReq struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
-	Items  []struct {
+	UserID   string `json:"user_id"`
+	Email    string `json:"email"`
+	Currency string `json:"currency"`
+	// Optional ISO 3166 alpha-2 countries, checked by fraud screening.
+	BillingCountry  string `json:"billing_country"`
+	ShippingCountry string `json:"shipping_country"`
+	// Optional; when given, shipping_country defaults to its country.
+	ShippingAddress *models.AddressPayload `json:"shipping_address"`
+	// Optional; gift cards and store credit are spent in the order given and
+	// the card pays the rest. None means the card pays everything.
+	PaymentMethods []models.PaymentMethodPayload `json:"payment_methods"`
+	Items          []struct {
 		SKU        string `json:"sku"`
 		Qty        int    `json:"qty"`
 		PriceCents int    `json:"price_cents"`
@@ -50,8 +67,39 @@ func (h *CreateOrderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if req.Currency == "" {
+		req.Currency = models.DefaultCurrency
+	}
+	req.Currency = strings.ToUpper(req.Currency)
+	if len(req.Currency) != 3 {
+		http.Error(w, "invalid currency", http.StatusBadRequest)
+		return
+	}
+	if a := req.ShippingAddress; a != nil {
+		a.Country = strings.ToUpper(strings.TrimSpace(a.Country))
+		if a.Name == "" || a.Line1 == "" || a.City == "" || a.PostalCode == "" || len(a.Country) != 2 {
+			http.Error(w, "invalid shipping_address", http.StatusBadRequest)
+			return
+		}
+		if req.ShippingCountry == "" {
+			req.ShippingCountry = a.Country
+		}
+	}
+	if err := validatePaymentMethods(req.PaymentMethods); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The correlation ID follows the order through the whole saga; clients may
+	// supply their own to tie the order to their request logs.
+	correlationID := r.Header.Get("X-Correlation-ID")
+	if correlationID == "" {
+		correlationID = uuid.NewString()
+	}
+	w.Header().Set("X-Correlation-ID", correlationID)
 
 	orderID := uuid.NewString()
+	log := h.Log.With().Str("order_id", orderID).Str("correlation_id", correlationID).Logger()
 	total := 0
 	for _, it := range req.Items {
 		total += it.PriceCents * it.Qty
@@ -62,18 +110,18 @@ func (h *CreateOrderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	tx, err := h.DB.Begin(ctx)
 	if err != nil {
-		h.Log.Error().Err(err).Msg("begin tx failed")
+		log.Error().Err(err).Msg("begin tx failed")
 		http.Error(w, "failed to create order", http.StatusInternalServerError)
 		return
 	}
 	defer func() { _ = tx.Rollback(ctx) }()
 
 	_, err = tx.Exec(ctx, `
-		insert into orders(id, user_id, email, status, total_cents)
-		values ($1, $2, $3, $4, $5)
-	`, orderID, req.UserID, req.Email, "created", total)
+		insert into orders(id, user_id, email, status, total_cents, currency, shipping_address)
+		values ($1, $2, $3, $4, $5, $6, $7)
+	`, orderID, req.UserID, req.Email, "created", total, req.Currency, req.ShippingAddress)
 	if err != nil {
-		h.Log.Error().Err(err).Msg("insert order failed")
+		log.Error().Err(err).Msg("insert order failed")
 		http.Error(w, "failed to create order", http.StatusInternalServerError)
 		return
 	}
@@ -84,7 +132,7 @@ func (h *CreateOrderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			values ($1, $2, $3, $4)
 		`, orderID, it.SKU, it.Qty, it.PriceCents)
 		if err != nil {
-			h.Log.Error().Err(err).Msg("insert order_items failed")
+			log.Error().Err(err).Msg("insert order_items failed")
 			http.Error(w, "failed to create order", http.StatusInternalServerError)
 			return
 		}
@@ -103,16 +151,41 @@ func (h *CreateOrderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}{SKU: it.SKU, Qty: it.Qty, PriceCents: it.PriceCents})
 	}
 
-	evt := models.NewOrderCreatedEvent(orderID, req.UserID, req.Email, total, itemsArg)
-
-	if err := h.Outbox.Enqueue(ctx, tx, evt.ID, evt.OrderID, evt.Type, evt); err != nil {
-		h.Log.Error().Err(err).Msg("outbox enqueue failed")
+	evt := models.NewOrderCreatedEvent(orderID, req.UserID, req.Email, req.Currency, total, itemsArg)
+	evt.CorrelationID = correlationID
+	evt.Payload.BillingCountry = strings.ToUpper(req.BillingCountry)
+	evt.Payload.ShippingCountry = strings.ToUpper(req.ShippingCountry)
+	evt.Payload.ShippingAddress = req.ShippingAddress
+	evt.Payload.PaymentMethods = req.PaymentMethods
+	evt.Payload.IPCountry = h.ClientCountry.Of(r)
+
+	var extra []int
+	if v, ok := h.DualPublish[evt.Type]; ok {
+		extra = append(extra, v)
+	}
+	copies, err := models.DualPublish(evt, extra...)
+	if err != nil {
+		log.Error().Err(err).Msg("build event versions failed")
 		http.Error(w, "failed to create order", http.StatusInternalServerError)
 		return
 	}
 
+	for _, c := range copies {
+		headers := models.NewOutboxHeaders(c)
+		headers.MessageID = models.CopyMessageID(c, evt.Version)
+		headers.Source = "api-gateway"
+		headers.TraceParent = r.Header.Get("traceparent")
+		headers.TraceState = r.Header.Get("tracestate")
+
+		if err := h.Outbox.Enqueue(ctx, tx, headers.MessageID, c.OrderID, c.Type, c, headers); err != nil {
+			log.Error().Err(err).Int("version", c.Version).Msg("outbox enqueue failed")
+			http.Error(w, "failed to create order", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	if err := tx.Commit(ctx); err != nil {
-		h.Log.Error().Err(err).Msg("commit failed")
+		log.Error().Err(err).Msg("commit failed")
 		http.Error(w, "failed to create order", http.StatusInternalServerError)
 		return
 	}
@@ -120,3 +193,34 @@ func (h *CreateOrderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(createOrderResp{ID: orderID})
 }
+
+// validatePaymentMethods allows at most one card and one store credit, each
+// gift card once, and amounts only on gift cards and store credit.
+func validatePaymentMethods(methods []models.PaymentMethodPayload) error {
+	seen := map[string]bool{}
+	for _, m := range methods {
+		key := m.Type
+		switch m.Type {
+		case models.PaymentMethodCard:
+			if m.AmountCents != 0 {
+				return errors.New("invalid payment_methods: the card pays the rest and takes no amount_cents")
+			}
+		case models.PaymentMethodStoreCredit:
+		case models.PaymentMethodGiftCard:
+			if m.Code == "" {
+				return errors.New("invalid payment_methods: gift_card needs a code")
+			}
+			key += ":" + m.Code
+		default:
+			return fmt.Errorf("invalid payment_methods: unknown type %q", m.Type)
+		}
+		if m.AmountCents < 0 {
+			return errors.New("invalid payment_methods: amount_cents must be >= 0")
+		}
+		if seen[key] {
+			return fmt.Errorf("invalid payment_methods: %s given twice", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"ecommerce-order-system/services/api-gateway/internal/repo"
+	"ecommerce-order-system/shared/pkg/models"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// CreatePaymentAttemptHandler accepts another payment method for a declined
+// payment and hands it to payment-service through the outbox. Whether the
+// payment may still be retried is decided there; the result is published as
+// payment.authorized, payment.pending, payment.declined or payment.failed.
+type CreatePaymentAttemptHandler struct {
+	DB     *pgxpool.Pool
+	Orders *repo.OrdersPG
+	Outbox *repo.OutboxPG
+	Log    zerolog.Logger
+}
+
+type createPaymentAttemptReq struct {
+	PaymentMethod string `json:"payment_method"`
+}
+
+type createPaymentAttemptResp struct {
+	RequestID string `json:"request_id"`
+	Status    string `json:"status"`
+}
+
+func (h *CreatePaymentAttemptHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	orderID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(orderID); err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var req createPaymentAttemptReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	req.PaymentMethod = strings.TrimSpace(req.PaymentMethod)
+	if req.PaymentMethod == "" {
+		http.Error(w, "payment_method is required", http.StatusBadRequest)
+		return
+	}
+
+	correlationID := r.Header.Get("X-Correlation-ID")
+	if correlationID == "" {
+		correlationID = uuid.NewString()
+	}
+	w.Header().Set("X-Correlation-ID", correlationID)
+
+	requestID := uuid.NewString()
+	log := h.Log.With().Str("order_id", orderID).Str("request_id", requestID).Str("correlation_id", correlationID).Logger()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if _, err := h.Orders.Get(ctx, orderID); errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Error().Err(err).Msg("load order failed")
+		http.Error(w, "failed to request payment attempt", http.StatusInternalServerError)
+		return
+	}
+
+	evt := models.NewEvent(models.TypePaymentRetryRequested, orderID, models.PaymentRetryRequestedPayload{
+		RequestID:     requestID,
+		PaymentMethod: req.PaymentMethod,
+	})
+	evt.CorrelationID = correlationID
+
+	headers := models.NewOutboxHeaders(evt)
+	headers.Source = "api-gateway"
+	headers.TraceParent = r.Header.Get("traceparent")
+	headers.TraceState = r.Header.Get("tracestate")
+
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("begin tx failed")
+		http.Error(w, "failed to request payment attempt", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := h.Outbox.Enqueue(ctx, tx, evt.ID, orderID, evt.Type, evt, headers); err != nil {
+		log.Error().Err(err).Msg("outbox enqueue failed")
+		http.Error(w, "failed to request payment attempt", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("commit failed")
+		http.Error(w, "failed to request payment attempt", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(createPaymentAttemptResp{RequestID: requestID, Status: "requested"})
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"ecommerce-order-system/services/api-gateway/internal/repo"
+	"ecommerce-order-system/shared/pkg/models"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// CreateRefundHandler accepts a refund request and hands it to payment-service
+// through the outbox. Whether it can be made (payment captured, enough left
+// to refund) is decided there, and reported as payment.refunded or
+// payment.refund_failed. Items are refunded at most as many times as they
+// were ordered, counting earlier requests not refused. A request with an
+// Idempotency-Key header already used for the order gets the stored refund.
+type CreateRefundHandler struct {
+	DB       *pgxpool.Pool
+	Orders   *repo.OrdersPG
+	Requests *repo.RefundRequestsPG
+	Outbox   *repo.OutboxPG
+	Log      zerolog.Logger
+}
+
+// createRefundReq asks for either an amount or a list of items; an empty
+// request refunds whatever is left of the payment.
+type createRefundReq struct {
+	AmountCents int    `json:"amount_cents"`
+	Reason      string `json:"reason"`
+	Items       []struct {
+		SKU string `json:"sku"`
+		Qty int    `json:"qty"`
+	} `json:"items"`
+}
+
+type createRefundResp struct {
+	RefundID    string `json:"refund_id"`
+	Status      string `json:"status"`
+	AmountCents int    `json:"amount_cents,omitempty"`
+}
+
+func (h *CreateRefundHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	orderID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(orderID); err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var req createRefundReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if req.AmountCents < 0 || (req.AmountCents > 0 && len(req.Items) > 0) {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	correlationID := r.Header.Get("X-Correlation-ID")
+	if correlationID == "" {
+		correlationID = uuid.NewString()
+	}
+	w.Header().Set("X-Correlation-ID", correlationID)
+
+	refundID := uuid.NewString()
+	key := r.Header.Get("Idempotency-Key")
+	log := h.Log.With().Str("order_id", orderID).Str("refund_id", refundID).Str("correlation_id", correlationID).Logger()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	order, err := h.Orders.Get(ctx, orderID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("load order failed")
+		http.Error(w, "failed to create refund", http.StatusInternalServerError)
+		return
+	}
+
+	// Requests of one order are checked and stored under its row lock, so two
+	// at once cannot both refund the last unit of an item.
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("begin tx failed")
+		http.Error(w, "failed to create refund", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := h.Requests.Lock(ctx, tx, orderID); err != nil {
+		log.Error().Err(err).Msg("lock order failed")
+		http.Error(w, "failed to create refund", http.StatusInternalServerError)
+		return
+	}
+	if key != "" {
+		prev, err := h.Requests.ByKey(ctx, tx, orderID, key)
+		if err == nil {
+			writeRefund(w, prev)
+			return
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msg("load refund request failed")
+			http.Error(w, "failed to create refund", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	payload := models.PaymentRefundRequestedPayload{
+		RefundID:    refundID,
+		AmountCents: req.AmountCents,
+		Reason:      req.Reason,
+	}
+	if len(req.Items) > 0 {
+		lines, err := h.Orders.Items(ctx, orderID)
+		if err != nil {
+			log.Error().Err(err).Msg("load order items failed")
+			http.Error(w, "failed to create refund", http.StatusInternalServerError)
+			return
+		}
+		refunded, err := h.Requests.RefundedQty(ctx, tx, orderID)
+		if err != nil {
+			log.Error().Err(err).Msg("load refunded items failed")
+			http.Error(w, "failed to create refund", http.StatusInternalServerError)
+			return
+		}
+		for _, it := range req.Items {
+			line, ok := lines[it.SKU]
+			if !ok || it.Qty <= 0 || it.Qty > line.Qty {
+				http.Error(w, "invalid items", http.StatusBadRequest)
+				return
+			}
+			if it.Qty > line.Qty-refunded[it.SKU] {
+				http.Error(w, "refund exceeds the quantity of "+it.SKU+" left to refund", http.StatusUnprocessableEntity)
+				return
+			}
+			refunded[it.SKU] += it.Qty
+			amount := line.PriceCents * it.Qty
+			payload.Items = append(payload.Items, models.RefundItemPayload{SKU: it.SKU, Qty: it.Qty, AmountCents: amount})
+			payload.AmountCents += amount
+		}
+	}
+	if payload.AmountCents > order.TotalCents {
+		http.Error(w, "refund exceeds order total", http.StatusUnprocessableEntity)
+		return
+	}
+
+	evt := models.NewEvent(models.TypePaymentRefundRequested, orderID, payload)
+	evt.CorrelationID = correlationID
+
+	headers := models.NewOutboxHeaders(evt)
+	headers.Source = "api-gateway"
+	headers.TraceParent = r.Header.Get("traceparent")
+	headers.TraceState = r.Header.Get("tracestate")
+
+	f := repo.RefundRequest{RefundID: refundID, OrderID: orderID, IdempotencyKey: key, AmountCents: payload.AmountCents, Items: payload.Items}
+	if err := h.Requests.Create(ctx, tx, f); err != nil {
+		log.Error().Err(err).Msg("store refund request failed")
+		http.Error(w, "failed to create refund", http.StatusInternalServerError)
+		return
+	}
+	if err := h.Outbox.Enqueue(ctx, tx, evt.ID, orderID, evt.Type, evt, headers); err != nil {
+		log.Error().Err(err).Msg("outbox enqueue failed")
+		http.Error(w, "failed to create refund", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("commit failed")
+		http.Error(w, "failed to create refund", http.StatusInternalServerError)
+		return
+	}
+
+	writeRefund(w, f)
+}
+
+func writeRefund(w http.ResponseWriter, f repo.RefundRequest) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(createRefundResp{RefundID: f.RefundID, Status: "requested", AmountCents: f.AmountCents})
+}
@@ -10,9 +10,12 @@ import (
 )
 
 type Handlers struct {
-	Health      http.HandlerFunc
-	CreateOrder http.HandlerFunc
-	GetOrder    http.HandlerFunc
+	Health       http.HandlerFunc
+	CreateOrder  http.HandlerFunc
+	GetOrder     http.HandlerFunc
+	CreateRefund http.HandlerFunc
+
+	CreatePaymentAttempt http.HandlerFunc
 }
 
 func NewRouter(h *Handlers) http.Handler {
@@ -24,6 +27,8 @@ func NewRouter(h *Handlers) http.Handler {
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Post("/orders", h.CreateOrder)
 		r.Get("/orders/{id}", h.GetOrder)
+		r.Post("/orders/{id}/refunds", h.CreateRefund)
+		r.Post("/orders/{id}/payment-attempts", h.CreatePaymentAttempt)
 	})
 	return r
 }
@@ -4,12 +4,82 @@ import (
 	"context"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"ecommerce-order-system/shared/pkg/models"
 )
 
 type OrdersPG struct{ DB *pgxpool.Pool }
 
-func (r *OrdersPG) GetStatus(ctx context.Context, orderID string) (string, error) {
-	var s string
-	err := r.DB.QueryRow(ctx, `select status from orders where id = $1`, orderID).Scan(&s)
-	return s, err
+// Order is the order detail served by the API.
+type Order struct {
+	Status          string                    `json:"status"`
+	UserID          string                    `json:"user_id"`
+	Email           string                    `json:"email"`
+	TotalCents      int                       `json:"total_cents"`
+	Currency        string                    `json:"currency"`
+	RefundedCents   int64                     `json:"refunded_cents"`
+	Items           []models.OrderItemPayload `json:"items"`
+	ShippingAddress *models.AddressPayload    `json:"shipping_address,omitempty"`
+}
+
+// OrderLine is an order's quantity of one SKU at its unit price.
+type OrderLine struct {
+	Qty        int
+	PriceCents int
+}
+
+// Get returns the order with its items in the order they were placed.
+func (r *OrdersPG) Get(ctx context.Context, orderID string) (Order, error) {
+	var o Order
+	err := r.DB.QueryRow(ctx, `
+		select status, user_id, email, total_cents, currency, refunded_cents, shipping_address
+		from orders where id = $1
+	`, orderID).Scan(&o.Status, &o.UserID, &o.Email, &o.TotalCents, &o.Currency, &o.RefundedCents, &o.ShippingAddress)
+	if err != nil {
+		return o, err
+	}
+
+	rows, err := r.DB.Query(ctx, `
+		select sku, qty, price_cents from order_items where order_id = $1 order by id
+	`, orderID)
+	if err != nil {
+		return o, err
+	}
+	defer rows.Close()
+	o.Items = []models.OrderItemPayload{}
+	for rows.Next() {
+		var it models.OrderItemPayload
+		if err := rows.Scan(&it.SKU, &it.Qty, &it.PriceCents); err != nil {
+			return o, err
+		}
+		o.Items = append(o.Items, it)
+	}
+	return o, rows.Err()
+}
+
+// Items returns the order's lines by SKU.
+func (r *OrdersPG) Items(ctx context.Context, orderID string) (map[string]OrderLine, error) {
+	rows, err := r.DB.Query(ctx, `
+		select sku, sum(qty)::int, min(price_cents)
+		from order_items
+		where order_id = $1
+		group by sku
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]OrderLine{}
+	for rows.Next() {
+		var (
+			sku  string
+			line OrderLine
+		)
+		if err := rows.Scan(&sku, &line.Qty, &line.PriceCents); err != nil {
+			return nil, err
+		}
+		out[sku] = line
+	}
+	return out, rows.Err()
 }
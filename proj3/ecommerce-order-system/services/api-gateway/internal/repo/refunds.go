@@ -0,0 +1,85 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+// RefundRequest is a refund accepted by the API; payment-service decides
+// whether it is made (table refunds).
+type RefundRequest struct {
+	RefundID       string
+	OrderID        string
+	IdempotencyKey string
+	AmountCents    int
+	Items          []models.RefundItemPayload
+	CreatedAt      time.Time
+}
+
+type RefundRequestsPG struct{}
+
+// Lock serializes the refund requests of an order on its orders row until tx
+// ends; pgx.ErrNoRows if there is no such order.
+func (r *RefundRequestsPG) Lock(ctx context.Context, tx pgx.Tx, orderID string) error {
+	var id string
+	return tx.QueryRow(ctx, `select id::text from orders where id = $1::uuid for update`, orderID).Scan(&id)
+}
+
+// ByKey returns the order's request made with idempotency key; pgx.ErrNoRows
+// if there is none.
+func (r *RefundRequestsPG) ByKey(ctx context.Context, tx pgx.Tx, orderID, key string) (RefundRequest, error) {
+	f := RefundRequest{OrderID: orderID, IdempotencyKey: key}
+	err := tx.QueryRow(ctx, `
+		select refund_id::text, amount_cents, items, created_at
+		from refund_requests
+		where order_id = $1::uuid and idempotency_key = $2
+	`, orderID, key).Scan(&f.RefundID, &f.AmountCents, &f.Items, &f.CreatedAt)
+	return f, err
+}
+
+// RefundedQty sums the quantities per SKU asked for by the order's requests,
+// leaving out those payment-service has refused.
+func (r *RefundRequestsPG) RefundedQty(ctx context.Context, tx pgx.Tx, orderID string) (map[string]int, error) {
+	rows, err := tx.Query(ctx, `
+		select it.sku, sum(it.qty)::int
+		from refund_requests q
+		cross join lateral jsonb_to_recordset(q.items) as it(sku text, qty int)
+		where q.order_id = $1::uuid
+		  and not exists (select 1 from refunds f where f.refund_id = q.refund_id and f.status = 'failed')
+		group by it.sku
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]int{}
+	for rows.Next() {
+		var (
+			sku string
+			qty int
+		)
+		if err := rows.Scan(&sku, &qty); err != nil {
+			return nil, err
+		}
+		out[sku] = qty
+	}
+	return out, rows.Err()
+}
+
+// Create stores f in tx.
+func (r *RefundRequestsPG) Create(ctx context.Context, tx pgx.Tx, f RefundRequest) error {
+	items := f.Items
+	if items == nil {
+		items = []models.RefundItemPayload{}
+	}
+	_, err := tx.Exec(ctx, `
+		insert into refund_requests (refund_id, order_id, idempotency_key, amount_cents, items)
+		values ($1::uuid, $2::uuid, nullif($3, ''), $4, $5)
+	`, f.RefundID, f.OrderID, f.IdempotencyKey, f.AmountCents, items)
+	return err
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	httpx "ecommerce-order-system/services/event-store-service/internal/http"
+	"ecommerce-order-system/services/event-store-service/internal/worker"
+	"ecommerce-order-system/shared/pkg/config"
+	"ecommerce-order-system/shared/pkg/eventstore"
+	"ecommerce-order-system/shared/pkg/logger"
+	"ecommerce-order-system/shared/pkg/models"
+	"ecommerce-order-system/shared/pkg/rabbit"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		panic(err)
+	}
+	log := logger.New("event-store-service", cfg.Common.LogLevel)
+
+	ctxDB, cancelDB := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelDB()
+	db, err := pgxpool.New(ctxDB, cfg.Postgres.DSN)
+	if err != nil {
+		log.Fatal().Err(err).Msg("pg connect failed")
+	}
+	defer db.Close()
+
+	store := &eventstore.StorePG{DB: db}
+
+	rc, err := rabbit.Connect(cfg.Rabbit.URL)
+	if err != nil {
+		log.Fatal().Err(err).Msg("rabbit connect failed")
+	}
+	defer func() { _ = rc.Close() }()
+
+	if err := rabbit.DeclareBase(rc.Ch); err != nil {
+		log.Fatal().Err(err).Msg("declare base failed")
+	}
+
+	if err := rabbit.DeclareQueueWithDLQ(rc.Ch, rabbit.QueueSpec{
+		Name:     "eventstore.q",
+		BindKeys: []string{"#"},
+		DLQKey:   "eventstore.dlq",
+		Prefetch: 50,
+	}); err != nil {
+		log.Fatal().Err(err).Msg("declare event store topology failed")
+	}
+
+	deliveries, err := rabbit.NewConsumer(rc.Ch).Consume("eventstore.q", 50)
+	if err != nil {
+		log.Fatal().Err(err).Msg("consume failed")
+	}
+
+	w := &worker.Consumer{
+		Log:         log,
+		Store:       store,
+		RetryPub:    rabbit.NewPublisher(rc.Ch, rabbit.ExchangeRetry),
+		DLQPub:      rabbit.NewPublisher(rc.Ch, rabbit.ExchangeDLX),
+		Events:      models.AcceptAll(),
+		Service:     "eventstore",
+		MaxAttempts: 0,
+		DLQKey:      "eventstore.dlq",
+	}
+
+	appCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(appCtx, deliveries)
+
+	get := &httpx.GetStreamHandler{Store: store, Log: log}
+	srv := &http.Server{
+		Addr:              cfg.EventStore.HTTPAddr,
+		Handler:           httpx.NewRouter(&httpx.Handlers{Health: httpx.Health, GetStream: get.ServeHTTP}),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		log.Info().Str("addr", srv.Addr).Msg("http started")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal().Err(err).Msg("http failed")
+		}
+	}()
+
+	log.Info().Msg("event-store-service started")
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+	log.Info().Msg("shutdown...")
+
+	cancel()
+	shCtx, shCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shCancel()
+	_ = srv.Shutdown(shCtx)
+}
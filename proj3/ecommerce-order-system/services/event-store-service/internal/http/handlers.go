@@ -0,0 +1,63 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+
+	"ecommerce-order-system/shared/pkg/eventstore"
+)
+
+func Health(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+type GetStreamHandler struct {
+	Store *eventstore.StorePG
+	Log   zerolog.Logger
+}
+
+type streamResp struct {
+	OrderID string              `json:"order_id"`
+	Events  []eventstore.Record `json:"events"`
+}
+
+// ServeHTTP returns the stored events of an order in sequence order.
+// ?after_seq=N returns only the events appended after N.
+func (h *GetStreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		http.Error(w, "missing id", http.StatusBadRequest)
+		return
+	}
+	var after int64
+	if s := r.URL.Query().Get("after_seq"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid after_seq", http.StatusBadRequest)
+			return
+		}
+		after = n
+	}
+
+	recs, err := h.Store.Stream(r.Context(), id, after)
+	if err != nil {
+		h.Log.Error().Err(err).Str("order_id", id).Msg("read event stream failed")
+		http.Error(w, "failed to read events", http.StatusInternalServerError)
+		return
+	}
+	if len(recs) == 0 && after == 0 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if recs == nil {
+		recs = []eventstore.Record{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(streamResp{OrderID: id, Events: recs})
+}
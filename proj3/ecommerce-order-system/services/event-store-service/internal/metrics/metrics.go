@@ -0,0 +1,18 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const (
+	OutcomeAppended  = "appended"
+	OutcomeDuplicate = "duplicate"
+	OutcomeError     = "error"
+)
+
+var EventStoreEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "event_store_events_total",
+	Help: "Events consumed by the event store, by event type and outcome (appended, duplicate, error)",
+}, []string{"event_type", "outcome"})
+
+func init() {
+	prometheus.MustRegister(EventStoreEventsTotal)
+}
@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"context"
+	"errors"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/rs/zerolog"
+
+	"ecommerce-order-system/services/event-store-service/internal/metrics"
+	"ecommerce-order-system/shared/pkg/eventstore"
+	"ecommerce-order-system/shared/pkg/logger"
+	"ecommerce-order-system/shared/pkg/models"
+	"ecommerce-order-system/shared/pkg/rabbit"
+)
+
+// Consumer appends every event from orders.events to the event store, at the
+// version it was published with (see models.AcceptAll).
+type Consumer struct {
+	Log   zerolog.Logger
+	Store *eventstore.StorePG
+
+	RetryPub *rabbit.Publisher
+	DLQPub   *rabbit.Publisher
+
+	Events models.Decoder
+
+	Service     string
+	MaxAttempts int
+	DLQKey      string
+}
+
+func (c *Consumer) Run(ctx context.Context, deliveries <-chan amqp.Delivery) {
+	c.Log.Info().Msg("event store consumer started")
+	for {
+		select {
+		case <-ctx.Done():
+			c.Log.Info().Msg("event store consumer stopped")
+			return
+		case d, ok := <-deliveries:
+			if !ok {
+				c.Log.Info().Msg("deliveries closed")
+				return
+			}
+			c.handle(ctx, d)
+		}
+	}
+}
+
+func (c *Consumer) handle(ctx context.Context, d amqp.Delivery) {
+	body, err := rabbit.EnvelopeBody(d)
+	if err != nil {
+		c.Log.Error().Err(err).Str("rk", d.RoutingKey).Msg("undecodable event -> dlq")
+		_ = rabbit.RetryOrDLQ(ctx, d, c.Service, 0, c.RetryPub, c.DLQPub, c.DLQKey)
+		return
+	}
+	evt, err := c.Events.Decode(d.RoutingKey, body)
+	if errors.Is(err, models.ErrSkipped) {
+		_ = d.Ack(false)
+		c.Log.Debug().Str("rk", d.RoutingKey).Int("version", evt.Version).Msg("dual-published copy skipped")
+		return
+	}
+	if errors.Is(err, models.ErrUnregisteredEvent) {
+		_ = d.Ack(false)
+		c.Log.Debug().Str("rk", d.RoutingKey).Msg("unregistered event ignored")
+		return
+	}
+	if err != nil {
+		c.Log.Error().Err(err).Str("rk", d.RoutingKey).Msg("invalid event -> dlq")
+		_ = rabbit.RetryOrDLQ(ctx, d, c.Service, 0, c.RetryPub, c.DLQPub, c.DLQKey)
+		return
+	}
+	if evt.ID == "" || evt.OrderID == "" {
+		c.Log.Error().Str("rk", d.RoutingKey).Msg("missing id/order_id -> dlq")
+		_ = rabbit.RetryOrDLQ(ctx, d, c.Service, 0, c.RetryPub, c.DLQPub, c.DLQKey)
+		return
+	}
+
+	log := logger.ForEvent(c.Log, evt)
+
+	seq, appended, err := c.Store.Append(ctx, evt, body)
+	if err != nil {
+		metrics.EventStoreEventsTotal.WithLabelValues(evt.Type, metrics.OutcomeError).Inc()
+		log.Error().Err(err).Msg("append failed -> retry/dlq")
+		_ = rabbit.RetryOrDLQ(ctx, d, c.Service, int32(c.MaxAttempts), c.RetryPub, c.DLQPub, c.DLQKey)
+		return
+	}
+
+	_ = d.Ack(false)
+	if !appended {
+		metrics.EventStoreEventsTotal.WithLabelValues(evt.Type, metrics.OutcomeDuplicate).Inc()
+		log.Debug().Msg("duplicate event ignored")
+		return
+	}
+	metrics.EventStoreEventsTotal.WithLabelValues(evt.Type, metrics.OutcomeAppended).Inc()
+	log.Info().Int64("seq", seq).Msg("event stored")
+}
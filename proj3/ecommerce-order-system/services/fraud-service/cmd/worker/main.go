@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	httpx "ecommerce-order-system/services/fraud-service/internal/http"
+	"ecommerce-order-system/services/fraud-service/internal/repo"
+	"ecommerce-order-system/services/fraud-service/internal/rules"
+	"ecommerce-order-system/services/fraud-service/internal/worker"
+	"ecommerce-order-system/shared/pkg/config"
+	"ecommerce-order-system/shared/pkg/httpauth"
+	"ecommerce-order-system/shared/pkg/logger"
+	"ecommerce-order-system/shared/pkg/models"
+	"ecommerce-order-system/shared/pkg/rabbit"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		panic(err)
+	}
+	log := logger.New("fraud-service", cfg.Common.LogLevel)
+
+	ruleStore := &rules.Store{Path: cfg.Fraud.RulesFile, Log: log}
+	if err := ruleStore.Load(); err != nil {
+		log.Fatal().Err(err).Str("path", cfg.Fraud.RulesFile).Msg("load fraud rules failed")
+	}
+
+	ctxDB, cancelDB := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelDB()
+	db, err := pgxpool.New(ctxDB, cfg.Postgres.DSN)
+	if err != nil {
+		log.Fatal().Err(err).Msg("pg connect failed")
+	}
+	defer db.Close()
+
+	rc, err := rabbit.Connect(cfg.Rabbit.URL)
+	if err != nil {
+		log.Fatal().Err(err).Msg("rabbit connect failed")
+	}
+	defer func() { _ = rc.Close() }()
+
+	if err := rabbit.DeclareBase(rc.Ch); err != nil {
+		log.Fatal().Err(err).Msg("declare base failed")
+	}
+
+	// Orders are recorded from orders.created in both modes.
+	keys, other := cfg.Saga.Bindings(
+		[]string{models.TypeInventoryReserved},
+		[]string{models.CmdFraudScreen},
+		models.TypeOrderCreated,
+	)
+
+	if err := rabbit.DeclareQueueWithDLQ(rc.Ch, rabbit.QueueSpec{
+		Name:       "fraud.q",
+		BindKeys:   keys,
+		UnbindKeys: other,
+		DLQKey:     "fraud.dlq",
+		Prefetch:   20,
+	}); err != nil {
+		log.Fatal().Err(err).Msg("declare fraud topology failed")
+	}
+
+	for _, k := range keys {
+		_ = rabbit.DeclareRetryQueue(rc.Ch, "fraud.retry."+k+".5s", "fraud."+k, k, 5000)
+	}
+
+	deliveries, err := rabbit.NewConsumer(rc.Ch).Consume("fraud.q", 20)
+	if err != nil {
+		log.Fatal().Err(err).Msg("consume failed")
+	}
+
+	screenings := &repo.ScreeningsPG{DB: db}
+	holds := &repo.HoldsPG{DB: db}
+	w := &worker.Consumer{
+		Log:          log,
+		EventsPub:    rabbit.NewPublisher(rc.Ch, rabbit.ExchangeEvents).UseFormat(cfg.Events.Format, "fraud-service").UseEncoding(cfg.Events.Encoding),
+		RetryPub:     rabbit.NewPublisher(rc.Ch, rabbit.ExchangeRetry),
+		DLQPub:       rabbit.NewPublisher(rc.Ch, rabbit.ExchangeDLX),
+		Events:       models.Decoder{},
+		Screenings:   screenings,
+		Rules:        ruleStore,
+		Holds:        holds,
+		HoldTimeout:  cfg.Fraud.HoldTimeout,
+		Choreography: cfg.Saga.Mode == config.SagaChoreography,
+		Service:      "fraud",
+		MaxAttempts:  5,
+		DLQKey:       "fraud.dlq",
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx, deliveries)
+	go ruleStore.Watch(ctx, cfg.Fraud.RulesReloadInterval)
+	go w.RunHoldExpiry(ctx, cfg.Fraud.HoldSweepInterval)
+
+	h := &httpx.FraudHandlers{Rules: ruleStore, Screenings: screenings, Log: log}
+	hh := &httpx.HoldHandlers{Holds: holds, Reviewer: w, Log: log}
+	srv := &http.Server{
+		Addr: cfg.Fraud.HTTPAddr,
+		Handler: httpx.NewRouter(&httpx.Handlers{
+			Health:       httpx.Health,
+			GetRules:     h.GetRules,
+			GetScreening: h.GetScreening,
+			AdminAuth:    httpauth.Bearer(cfg.Fraud.ReviewerTokens),
+			ListHolds:    hh.List,
+			GetHold:      hh.Get,
+			ApproveHold:  hh.Approve,
+			RejectHold:   hh.Reject,
+		}),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		log.Info().Str("addr", srv.Addr).Msg("http started")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal().Err(err).Msg("http failed")
+		}
+	}()
+
+	log.Info().Msg("fraud worker started")
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+
+	log.Info().Msg("shutdown")
+	cancel()
+	shCtx, shCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shCancel()
+	_ = srv.Shutdown(shCtx)
+}
@@ -0,0 +1,60 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+
+	"ecommerce-order-system/services/fraud-service/internal/repo"
+	"ecommerce-order-system/services/fraud-service/internal/rules"
+)
+
+func Health(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+type FraudHandlers struct {
+	Rules      *rules.Store
+	Screenings *repo.ScreeningsPG
+	Log        zerolog.Logger
+}
+
+type rulesResp struct {
+	Path     string       `json:"path"`
+	LoadedAt time.Time    `json:"loaded_at"`
+	Rules    *rules.Rules `json:"rules"`
+}
+
+// GetRules returns the rules in effect and when they were loaded.
+func (h *FraudHandlers) GetRules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rulesResp{Path: h.Rules.Path, LoadedAt: h.Rules.LoadedAt(), Rules: h.Rules.Current()})
+}
+
+// GetScreening returns the recorded order and its screening result.
+func (h *FraudHandlers) GetScreening(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	s, err := h.Screenings.Get(r.Context(), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Log.Error().Err(err).Str("order_id", id).Msg("get screening failed")
+		http.Error(w, "failed to read screening", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s)
+}
@@ -0,0 +1,140 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+
+	"ecommerce-order-system/services/fraud-service/internal/repo"
+	"ecommerce-order-system/shared/pkg/httpauth"
+)
+
+// Reviewer resolves held orders; the worker publishes the outcome.
+type Reviewer interface {
+	Approve(ctx context.Context, orderID, reason, by string) (repo.Hold, error)
+	Reject(ctx context.Context, orderID, reason, by string) (repo.Hold, error)
+}
+
+// HoldHandlers serve the holds API behind httpauth.Bearer; the reviewer of
+// a hold is the principal whose token the request carried.
+type HoldHandlers struct {
+	Holds    *repo.HoldsPG
+	Reviewer Reviewer
+	Log      zerolog.Logger
+}
+
+type resolveReq struct {
+	Reason string `json:"reason"`
+}
+
+// List returns holds, by default the ones still waiting for review.
+// ?status= is held, approved, rejected or all; ?limit= caps the result.
+func (h *HoldHandlers) List(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = repo.HoldHeld
+	case "all":
+		status = ""
+	case repo.HoldHeld, repo.HoldApproved, repo.HoldRejected:
+	default:
+		http.Error(w, "status must be held, approved, rejected or all", http.StatusBadRequest)
+		return
+	}
+	limit := 100
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "limit must be 1..1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	holds, err := h.Holds.List(r.Context(), status, limit)
+	if err != nil {
+		h.Log.Error().Err(err).Msg("list holds failed")
+		http.Error(w, "failed to list holds", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(holds)
+}
+
+// Get returns the hold of one order.
+func (h *HoldHandlers) Get(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	hold, err := h.Holds.Get(r.Context(), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Log.Error().Err(err).Str("order_id", id).Msg("get hold failed")
+		http.Error(w, "failed to read hold", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(hold)
+}
+
+// Approve lets a held order go on to payment.
+func (h *HoldHandlers) Approve(w http.ResponseWriter, r *http.Request) {
+	h.resolve(w, r, h.Reviewer.Approve)
+}
+
+// Reject cancels a held order.
+func (h *HoldHandlers) Reject(w http.ResponseWriter, r *http.Request) {
+	h.resolve(w, r, h.Reviewer.Reject)
+}
+
+func (h *HoldHandlers) resolve(w http.ResponseWriter, r *http.Request, fn func(ctx context.Context, orderID, reason, by string) (repo.Hold, error)) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	var req resolveReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+	by := httpauth.Principal(r.Context())
+	if by == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	hold, err := fn(r.Context(), id, req.Reason, by)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		http.Error(w, "order is not held", http.StatusNotFound)
+		return
+	case errors.Is(err, repo.ErrNotHeld):
+		http.Error(w, "hold already "+hold.Status, http.StatusConflict)
+		return
+	case err != nil:
+		h.Log.Error().Err(err).Str("order_id", id).Msg("resolve hold failed")
+		http.Error(w, "failed to resolve hold", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(hold)
+}
@@ -0,0 +1,42 @@
+package httpx
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"ecommerce-order-system/shared/pkg/metrics"
+)
+
+type Handlers struct {
+	Health       http.HandlerFunc
+	GetRules     http.HandlerFunc
+	GetScreening http.HandlerFunc
+
+	AdminAuth   func(http.Handler) http.Handler
+	ListHolds   http.HandlerFunc
+	GetHold     http.HandlerFunc
+	ApproveHold http.HandlerFunc
+	RejectHold  http.HandlerFunc
+}
+
+func NewRouter(h *Handlers) http.Handler {
+	r := chi.NewRouter()
+	r.Use(metrics.Middleware("fraud-service"))
+	r.Handle("/metrics", promhttp.Handler())
+
+	r.Get("/health", h.Health)
+	r.Route("/api/v1/fraud", func(r chi.Router) {
+		r.Get("/rules", h.GetRules)
+		r.Get("/screenings/{id}", h.GetScreening)
+	})
+	r.Route("/admin/holds", func(r chi.Router) {
+		r.Use(h.AdminAuth)
+		r.Get("/", h.ListHolds)
+		r.Get("/{id}", h.GetHold)
+		r.Post("/{id}/approve", h.ApproveHold)
+		r.Post("/{id}/reject", h.RejectHold)
+	})
+	return r
+}
@@ -0,0 +1,22 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	ScreeningsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "fraud_screenings_total",
+		Help: "Orders screened, by decision (pass, manual_review, reject)",
+	}, []string{"decision"})
+	RulesReloadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "fraud_rules_reloads_total",
+		Help: "Rules file reloads, by result (ok, error)",
+	}, []string{"result"})
+	HoldsResolvedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "fraud_holds_resolved_total",
+		Help: "Manual review holds resolved, by status (approved, rejected) and by (review, deadline)",
+	}, []string{"status", "by"})
+)
+
+func init() {
+	prometheus.MustRegister(ScreeningsTotal, RulesReloadsTotal, HoldsResolvedTotal)
+}
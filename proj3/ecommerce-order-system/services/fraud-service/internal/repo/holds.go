@@ -0,0 +1,160 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	HoldHeld     = "held"
+	HoldApproved = "approved"
+	HoldRejected = "rejected"
+)
+
+// ErrNotHeld: the hold was already approved or rejected.
+var ErrNotHeld = errors.New("order is not on hold")
+
+// Hold is one row of order_holds.
+type Hold struct {
+	OrderID          string     `json:"order_id"`
+	Status           string     `json:"status"`
+	Reason           string     `json:"reason"`
+	Reasons          []string   `json:"reasons"`
+	DeadlineAt       time.Time  `json:"deadline_at"`
+	CorrelationID    string     `json:"correlation_id,omitempty"`
+	Seq              int64      `json:"-"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ResolvedAt       *time.Time `json:"resolved_at,omitempty"`
+	ResolutionReason string     `json:"resolution_reason,omitempty"`
+	ResolvedBy       string     `json:"resolved_by,omitempty"`
+}
+
+type HoldsPG struct{ DB *pgxpool.Pool }
+
+const holdColumns = `
+	order_id::text, status, reason, reasons, deadline_at, coalesce(correlation_id, ''), seq, created_at,
+	resolved_at, coalesce(resolution_reason, ''), coalesce(resolved_by, '')`
+
+// Open puts an order on hold. An order is held at most once: if it already
+// was, the stored hold is returned unchanged.
+func (r *HoldsPG) Open(ctx context.Context, h Hold) (Hold, error) {
+	if h.Reasons == nil {
+		h.Reasons = []string{}
+	}
+	if _, err := r.DB.Exec(ctx, `
+		insert into order_holds (order_id, status, reason, reasons, deadline_at, correlation_id, seq)
+		values ($1::uuid, $2, $3, $4, $5, nullif($6, ''), $7)
+		on conflict (order_id) do nothing
+	`, h.OrderID, HoldHeld, h.Reason, h.Reasons, h.DeadlineAt, h.CorrelationID, h.Seq); err != nil {
+		return Hold{}, err
+	}
+	return r.Get(ctx, h.OrderID)
+}
+
+// Get returns the hold of an order; pgx.ErrNoRows if it was never held.
+func (r *HoldsPG) Get(ctx context.Context, orderID string) (Hold, error) {
+	return scanHold(r.DB.QueryRow(ctx, `select `+holdColumns+` from order_holds where order_id = $1::uuid`, orderID))
+}
+
+// List returns holds by status (all if empty), oldest deadline first.
+func (r *HoldsPG) List(ctx context.Context, status string, limit int) ([]Hold, error) {
+	rows, err := r.DB.Query(ctx, `
+		select `+holdColumns+`
+		from order_holds
+		where $1 = '' or status = $1
+		order by deadline_at, order_id
+		limit $2
+	`, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Hold, error) { return scanHold(row) })
+}
+
+// Resolve approves or rejects a held order and calls fn with tx before
+// committing, so what fn writes (the outbox rows of the outcome) commits with
+// the resolution or not at all. ErrNotHeld is returned with the hold if it
+// was already resolved.
+func (r *HoldsPG) Resolve(ctx context.Context, orderID, status, reason, by string, fn func(pgx.Tx, Hold) error) (Hold, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return Hold{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	h, err := scanHold(tx.QueryRow(ctx, `select `+holdColumns+` from order_holds where order_id = $1::uuid for update`, orderID))
+	if err != nil {
+		return Hold{}, err
+	}
+	if h.Status != HoldHeld {
+		return h, ErrNotHeld
+	}
+	if err := resolveTx(ctx, tx, &h, status, reason, by); err != nil {
+		return Hold{}, err
+	}
+	if err := fn(tx, h); err != nil {
+		return Hold{}, err
+	}
+	return h, tx.Commit(ctx)
+}
+
+// RejectExpired rejects one hold past its deadline, calling fn with tx before
+// committing like Resolve. Rows locked by another replica or a reviewer are
+// skipped. Returns the rejected hold and whether one was found.
+func (r *HoldsPG) RejectExpired(ctx context.Context, reason, by string, fn func(pgx.Tx, Hold) error) (Hold, bool, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return Hold{}, false, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	h, err := scanHold(tx.QueryRow(ctx, `
+		select `+holdColumns+`
+		from order_holds
+		where status = 'held'
+		  and deadline_at <= now()
+		order by deadline_at
+		limit 1
+		for update skip locked
+	`))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Hold{}, false, nil
+	}
+	if err != nil {
+		return Hold{}, false, err
+	}
+	if err := resolveTx(ctx, tx, &h, HoldRejected, reason, by); err != nil {
+		return Hold{}, false, err
+	}
+	if err := fn(tx, h); err != nil {
+		return Hold{}, false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Hold{}, false, err
+	}
+	return h, true, nil
+}
+
+func resolveTx(ctx context.Context, tx pgx.Tx, h *Hold, status, reason, by string) error {
+	now := time.Now()
+	if _, err := tx.Exec(ctx, `
+		update order_holds
+		set status = $2, resolved_at = $3, resolution_reason = $4, resolved_by = $5
+		where order_id = $1::uuid
+	`, h.OrderID, status, now, reason, by); err != nil {
+		return err
+	}
+	h.Status, h.ResolvedAt, h.ResolutionReason, h.ResolvedBy = status, &now, reason, by
+	return nil
+}
+
+func scanHold(row pgx.Row) (Hold, error) {
+	var h Hold
+	err := row.Scan(&h.OrderID, &h.Status, &h.Reason, &h.Reasons, &h.DeadlineAt, &h.CorrelationID, &h.Seq, &h.CreatedAt,
+		&h.ResolvedAt, &h.ResolutionReason, &h.ResolvedBy)
+	return h, err
+}
@@ -0,0 +1,104 @@
+package repo
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"ecommerce-order-system/services/fraud-service/internal/rules"
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+// Screening is an order recorded from orders.created and, once screened, its
+// result. Decision is empty until then. Snapshot is the order as passed on
+// to payment; nil for orders recorded before it was kept.
+type Screening struct {
+	Order      rules.Order                  `json:"order"`
+	Snapshot   *models.OrderSnapshotPayload `json:"snapshot,omitempty"`
+	CreatedAt  time.Time                    `json:"created_at"`
+	Result     rules.Result                 `json:"result"`
+	ScreenedAt *time.Time                   `json:"screened_at,omitempty"`
+}
+
+type ScreeningsPG struct{ DB *pgxpool.Pool }
+
+// Record stores an order to be screened. Recording it again is a no-op.
+func (r *ScreeningsPG) Record(ctx context.Context, o rules.Order, snapshot *models.OrderSnapshotPayload) error {
+	_, err := r.DB.Exec(ctx, `
+		insert into fraud_screenings (order_id, user_id, email, total_cents, currency, skus,
+		                              billing_country, shipping_country, ip_country, order_snapshot)
+		values ($1::uuid, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		on conflict (order_id) do nothing
+	`, o.OrderID, o.UserID, strings.ToLower(o.Email), o.TotalCents, o.Currency, o.SKUs,
+		strings.ToUpper(o.BillingCountry), strings.ToUpper(o.ShippingCountry), strings.ToUpper(o.IPCountry), snapshot)
+	return err
+}
+
+// KeepSnapshot stores snapshot for an order recorded without one.
+func (r *ScreeningsPG) KeepSnapshot(ctx context.Context, orderID string, snapshot *models.OrderSnapshotPayload) error {
+	_, err := r.DB.Exec(ctx, `
+		update fraud_screenings set order_snapshot = $2 where order_id = $1::uuid and order_snapshot is null
+	`, orderID, snapshot)
+	return err
+}
+
+// Get returns the recorded order; pgx.ErrNoRows if orders.created has not
+// been seen yet.
+func (r *ScreeningsPG) Get(ctx context.Context, orderID string) (Screening, error) {
+	var (
+		s        Screening
+		decision *string
+		score    *int
+	)
+	err := r.DB.QueryRow(ctx, `
+		select order_id::text, user_id, email, total_cents, currency, skus,
+		       billing_country, shipping_country, ip_country, created_at,
+		       decision, score, coalesce(reasons, '[]'::jsonb), screened_at, order_snapshot
+		from fraud_screenings
+		where order_id = $1::uuid
+	`, orderID).Scan(&s.Order.OrderID, &s.Order.UserID, &s.Order.Email, &s.Order.TotalCents, &s.Order.Currency, &s.Order.SKUs,
+		&s.Order.BillingCountry, &s.Order.ShippingCountry, &s.Order.IPCountry, &s.CreatedAt,
+		&decision, &score, &s.Result.Reasons, &s.ScreenedAt, &s.Snapshot)
+	if decision != nil {
+		s.Result.Decision = *decision
+	}
+	if score != nil {
+		s.Result.Score = *score
+	}
+	return s, err
+}
+
+// Decide stores res unless the order already has a decision, and returns the
+// decision in effect, so a redelivered message gets the same answer even if
+// the rules changed meanwhile. decided reports whether res was stored.
+func (r *ScreeningsPG) Decide(ctx context.Context, orderID string, res rules.Result) (out rules.Result, decided bool, err error) {
+	ct, err := r.DB.Exec(ctx, `
+		update fraud_screenings
+		set decision = $2, score = $3, reasons = $4, screened_at = now()
+		where order_id = $1::uuid and decision is null
+	`, orderID, res.Decision, res.Score, res.Reasons)
+	if err != nil {
+		return rules.Result{}, false, err
+	}
+	if ct.RowsAffected() == 1 {
+		return res, true, nil
+	}
+	s, err := r.Get(ctx, orderID)
+	return s.Result, false, err
+}
+
+// CountOrders implements rules.History.
+func (r *ScreeningsPG) CountOrders(ctx context.Context, by, value string, since time.Time, exceptOrderID string) (int, error) {
+	column := "user_id"
+	if by == rules.ByEmail {
+		column = "email"
+	}
+	var n int
+	err := r.DB.QueryRow(ctx, `
+		select count(*) from fraud_screenings
+		where `+column+` = $1 and created_at >= $2 and order_id <> $3::uuid
+	`, value, since, exceptOrderID).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,245 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Decisions, by total score: below ReviewScore the order passes, from
+// RejectScore on it is rejected, in between it waits for manual review.
+const (
+	DecisionPass   = "pass"
+	DecisionReview = "manual_review"
+	DecisionReject = "reject"
+)
+
+// Velocity rule keys.
+const (
+	ByUser  = "user"
+	ByEmail = "email"
+)
+
+// Rules is the rules file. Every rule that matches adds its Score.
+type Rules struct {
+	ReviewScore int `json:"review_score"`
+	RejectScore int `json:"reject_score"`
+
+	Velocity        []VelocityRule       `json:"velocity"`
+	Amount          []AmountRule         `json:"amount"`
+	Blocklist       Blocklist            `json:"blocklist"`
+	CountryMismatch *CountryMismatchRule `json:"country_mismatch,omitempty"`
+}
+
+// VelocityRule matches when the user (or email) placed more than MaxOrders
+// orders within Window, this one included.
+type VelocityRule struct {
+	By        string   `json:"by"`
+	Window    Duration `json:"window"`
+	MaxOrders int      `json:"max_orders"`
+	Score     int      `json:"score"`
+}
+
+// AmountRule matches orders of at least MinCents, in Currency if set.
+type AmountRule struct {
+	Currency string `json:"currency,omitempty"`
+	MinCents int    `json:"min_cents"`
+	Score    int    `json:"score"`
+}
+
+// Blocklist matches an order whose email, email domain or any SKU is listed.
+// Matching is case-insensitive.
+type Blocklist struct {
+	Emails       []string `json:"emails"`
+	EmailDomains []string `json:"email_domains"`
+	SKUs         []string `json:"skus"`
+	Score        int      `json:"score"`
+}
+
+// CountryMismatchRule matches when the billing, shipping and IP countries the
+// order has are not all the same. Missing countries are not compared.
+type CountryMismatchRule struct {
+	Score int `json:"score"`
+}
+
+// Duration is a time.Duration written as a string ("1h", "30m") in JSON.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"1h\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Parse reads and validates a rules file.
+func Parse(b []byte) (*Rules, error) {
+	var r Rules
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&r); err != nil {
+		return nil, fmt.Errorf("parse fraud rules: %w", err)
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ParseFile reads the rules file at path.
+func ParseFile(path string) (*Rules, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+func (r *Rules) Validate() error {
+	if r.ReviewScore <= 0 {
+		return fmt.Errorf("fraud rules: review_score must be > 0, got %d", r.ReviewScore)
+	}
+	if r.RejectScore < r.ReviewScore {
+		return fmt.Errorf("fraud rules: reject_score must be >= review_score, got %d", r.RejectScore)
+	}
+	for i, v := range r.Velocity {
+		if v.By != ByUser && v.By != ByEmail {
+			return fmt.Errorf("fraud rules: velocity[%d]: by must be user or email, got %q", i, v.By)
+		}
+		if v.Window <= 0 || v.MaxOrders <= 0 || v.Score < 0 {
+			return fmt.Errorf("fraud rules: velocity[%d]: window and max_orders must be > 0, score >= 0", i)
+		}
+	}
+	for i, a := range r.Amount {
+		if a.MinCents <= 0 || a.Score < 0 {
+			return fmt.Errorf("fraud rules: amount[%d]: min_cents must be > 0, score >= 0", i)
+		}
+	}
+	if r.Blocklist.Score < 0 {
+		return errors.New("fraud rules: blocklist score must be >= 0")
+	}
+	if r.CountryMismatch != nil && r.CountryMismatch.Score < 0 {
+		return errors.New("fraud rules: country_mismatch score must be >= 0")
+	}
+	return nil
+}
+
+// Order is what the rules look at.
+type Order struct {
+	OrderID         string   `json:"order_id"`
+	UserID          string   `json:"user_id"`
+	Email           string   `json:"email"`
+	TotalCents      int      `json:"total_cents"`
+	Currency        string   `json:"currency"`
+	SKUs            []string `json:"skus"`
+	BillingCountry  string   `json:"billing_country,omitempty"`
+	ShippingCountry string   `json:"shipping_country,omitempty"`
+	IPCountry       string   `json:"ip_country,omitempty"`
+}
+
+// History counts a user's or email's orders placed since a time, not counting
+// the order being screened.
+type History interface {
+	CountOrders(ctx context.Context, by, value string, since time.Time, exceptOrderID string) (int, error)
+}
+
+// Result is the outcome of screening one order.
+type Result struct {
+	Decision string   `json:"decision"`
+	Score    int      `json:"score"`
+	Reasons  []string `json:"reasons"`
+}
+
+// Evaluate scores o against every rule.
+func (r *Rules) Evaluate(ctx context.Context, o Order, h History) (Result, error) {
+	res := Result{Reasons: []string{}}
+	hit := func(score int, reason string) {
+		res.Score += score
+		res.Reasons = append(res.Reasons, reason)
+	}
+
+	now := time.Now()
+	for _, v := range r.Velocity {
+		value := o.UserID
+		if v.By == ByEmail {
+			value = strings.ToLower(o.Email)
+		}
+		n, err := h.CountOrders(ctx, v.By, value, now.Add(-time.Duration(v.Window)), o.OrderID)
+		if err != nil {
+			return Result{}, err
+		}
+		if n+1 > v.MaxOrders {
+			hit(v.Score, fmt.Sprintf("velocity: %d orders per %s in %s", n+1, v.By, time.Duration(v.Window)))
+		}
+	}
+
+	for _, a := range r.Amount {
+		if a.Currency != "" && !strings.EqualFold(a.Currency, o.Currency) {
+			continue
+		}
+		if o.TotalCents >= a.MinCents {
+			hit(a.Score, fmt.Sprintf("amount: %d %s >= %d", o.TotalCents, o.Currency, a.MinCents))
+		}
+	}
+
+	email := strings.ToLower(o.Email)
+	_, domain, _ := strings.Cut(email, "@")
+	switch {
+	case containsFold(r.Blocklist.Emails, email):
+		hit(r.Blocklist.Score, "blocklist: email "+email)
+	case domain != "" && containsFold(r.Blocklist.EmailDomains, domain):
+		hit(r.Blocklist.Score, "blocklist: email domain "+domain)
+	}
+	for _, sku := range o.SKUs {
+		if containsFold(r.Blocklist.SKUs, sku) {
+			hit(r.Blocklist.Score, "blocklist: sku "+sku)
+			break
+		}
+	}
+
+	if r.CountryMismatch != nil {
+		var seen, parts []string
+		for _, c := range [][2]string{{"billing", o.BillingCountry}, {"shipping", o.ShippingCountry}, {"ip", o.IPCountry}} {
+			if c[1] == "" {
+				continue
+			}
+			parts = append(parts, c[0]+"="+c[1])
+			if !containsFold(seen, c[1]) {
+				seen = append(seen, c[1])
+			}
+		}
+		if len(seen) > 1 {
+			hit(r.CountryMismatch.Score, "country mismatch: "+strings.Join(parts, " "))
+		}
+	}
+
+	switch {
+	case res.Score >= r.RejectScore:
+		res.Decision = DecisionReject
+	case res.Score >= r.ReviewScore:
+		res.Decision = DecisionReview
+	default:
+		res.Decision = DecisionPass
+	}
+	return res, nil
+}
+
+func containsFold(list []string, s string) bool {
+	return slices.ContainsFunc(list, func(v string) bool { return strings.EqualFold(v, s) })
+}
@@ -0,0 +1,148 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantErr bool
+	}{
+		{"minimal", `{"review_score": 50, "reject_score": 100}`, false},
+		{"review equals reject", `{"review_score": 50, "reject_score": 50}`, false},
+		{"all rules", `{"review_score": 50, "reject_score": 100,
+			"velocity": [{"by": "email", "window": "24h", "max_orders": 20, "score": 100}],
+			"amount": [{"currency": "USD", "min_cents": 100000, "score": 50}],
+			"blocklist": {"emails": ["a@b.c"], "email_domains": ["b.c"], "skus": ["X"], "score": 100},
+			"country_mismatch": {"score": 30}}`, false},
+		{"unknown field", `{"review_score": 50, "reject_score": 100, "rejectscore": 10}`, true},
+		{"unknown rule field", `{"review_score": 50, "reject_score": 100, "amount": [{"min": 1, "score": 1}]}`, true},
+		{"not json", `review_score: 50`, true},
+		{"zero review score", `{"review_score": 0, "reject_score": 100}`, true},
+		{"reject below review", `{"review_score": 50, "reject_score": 49}`, true},
+		{"velocity by ip", `{"review_score": 50, "reject_score": 100, "velocity": [{"by": "ip", "window": "1h", "max_orders": 5, "score": 50}]}`, true},
+		{"velocity bad duration", `{"review_score": 50, "reject_score": 100, "velocity": [{"by": "user", "window": "1 hour", "max_orders": 5, "score": 50}]}`, true},
+		{"velocity duration as number", `{"review_score": 50, "reject_score": 100, "velocity": [{"by": "user", "window": 3600, "max_orders": 5, "score": 50}]}`, true},
+		{"velocity zero window", `{"review_score": 50, "reject_score": 100, "velocity": [{"by": "user", "window": "0s", "max_orders": 5, "score": 50}]}`, true},
+		{"velocity zero max orders", `{"review_score": 50, "reject_score": 100, "velocity": [{"by": "user", "window": "1h", "max_orders": 0, "score": 50}]}`, true},
+		{"amount zero min", `{"review_score": 50, "reject_score": 100, "amount": [{"min_cents": 0, "score": 50}]}`, true},
+		{"amount negative score", `{"review_score": 50, "reject_score": 100, "amount": [{"min_cents": 100, "score": -1}]}`, true},
+		{"blocklist negative score", `{"review_score": 50, "reject_score": 100, "blocklist": {"score": -1}}`, true},
+		{"country mismatch negative score", `{"review_score": 50, "reject_score": 100, "country_mismatch": {"score": -1}}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.in))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseFileExample(t *testing.T) {
+	if _, err := ParseFile("../../rules.json"); err != nil {
+		t.Fatalf("example rules: %v", err)
+	}
+}
+
+// history is a History with fixed counts keyed by "by:value".
+type history map[string]int
+
+func (h history) CountOrders(_ context.Context, by, value string, _ time.Time, _ string) (int, error) {
+	return h[by+":"+value], nil
+}
+
+func TestEvaluate(t *testing.T) {
+	velocity := func(by string, max int) *Rules {
+		return &Rules{ReviewScore: 50, RejectScore: 100, Velocity: []VelocityRule{
+			{By: by, Window: Duration(time.Hour), MaxOrders: max, Score: 50},
+		}}
+	}
+	blocklist := &Rules{ReviewScore: 50, RejectScore: 100, Blocklist: Blocklist{
+		Emails: []string{"fraud@test.com"}, EmailDomains: []string{"test.com", "mailinator.com"}, SKUs: []string{"GIFT-CARD"}, Score: 100,
+	}}
+	mismatch := &Rules{ReviewScore: 50, RejectScore: 100, CountryMismatch: &CountryMismatchRule{Score: 30}}
+	amount := func(score int) *Rules {
+		return &Rules{ReviewScore: 50, RejectScore: 100, Amount: []AmountRule{{MinCents: 1000, Score: score}}}
+	}
+	usd := &Rules{ReviewScore: 50, RejectScore: 100, Amount: []AmountRule{{Currency: "USD", MinCents: 1000, Score: 50}}}
+
+	order := Order{OrderID: "o1", UserID: "u1", Email: "Buyer@Example.com", TotalCents: 500, Currency: "USD"}
+	with := func(f func(*Order)) Order {
+		o := order
+		f(&o)
+		return o
+	}
+
+	tests := []struct {
+		name     string
+		rules    *Rules
+		order    Order
+		history  history
+		decision string
+		reasons  []string
+	}{
+		{"velocity below limit", velocity(ByUser, 3), order, history{"user:u1": 1}, DecisionPass, nil},
+		{"velocity at limit", velocity(ByUser, 3), order, history{"user:u1": 2}, DecisionPass, nil},
+		{"velocity over limit", velocity(ByUser, 3), order, history{"user:u1": 3}, DecisionReview,
+			[]string{"velocity: 4 orders per user in 1h0m0s"}},
+		{"velocity by lowercased email", velocity(ByEmail, 1), order, history{"email:buyer@example.com": 1}, DecisionReview,
+			[]string{"velocity: 2 orders per email in 1h0m0s"}},
+
+		{"blocked email wins over its domain", blocklist, with(func(o *Order) { o.Email = "Fraud@Test.com" }), nil, DecisionReject,
+			[]string{"blocklist: email fraud@test.com"}},
+		{"blocked domain", blocklist, with(func(o *Order) { o.Email = "x@MAILINATOR.com" }), nil, DecisionReject,
+			[]string{"blocklist: email domain mailinator.com"}},
+		{"email without domain", blocklist, with(func(o *Order) { o.Email = "test.com" }), nil, DecisionPass, nil},
+		{"blocked sku", blocklist, with(func(o *Order) { o.SKUs = []string{"A", "gift-card"} }), nil, DecisionReject,
+			[]string{"blocklist: sku gift-card"}},
+
+		{"countries match", mismatch, with(func(o *Order) { o.BillingCountry, o.ShippingCountry, o.IPCountry = "US", "us", "US" }), nil, DecisionPass, nil},
+		{"countries differ", mismatch, with(func(o *Order) { o.BillingCountry, o.ShippingCountry = "US", "DE" }), nil, DecisionPass,
+			[]string{"country mismatch: billing=US shipping=DE"}},
+		{"ip differs", mismatch, with(func(o *Order) { o.BillingCountry, o.IPCountry = "US", "NG" }), nil, DecisionPass,
+			[]string{"country mismatch: billing=US ip=NG"}},
+		{"one country known", mismatch, with(func(o *Order) { o.ShippingCountry = "DE" }), nil, DecisionPass, nil},
+		{"no countries known", mismatch, order, nil, DecisionPass, nil},
+
+		{"amount in another currency", usd, with(func(o *Order) { o.TotalCents, o.Currency = 5000, "EUR" }), nil, DecisionPass, nil},
+		{"amount at threshold", usd, with(func(o *Order) { o.TotalCents = 1000 }), nil, DecisionReview,
+			[]string{"amount: 1000 USD >= 1000"}},
+
+		{"score below review", amount(49), with(func(o *Order) { o.TotalCents = 1000 }), nil, DecisionPass, []string{"amount: 1000 USD >= 1000"}},
+		{"score at review", amount(50), with(func(o *Order) { o.TotalCents = 1000 }), nil, DecisionReview, []string{"amount: 1000 USD >= 1000"}},
+		{"score below reject", amount(99), with(func(o *Order) { o.TotalCents = 1000 }), nil, DecisionReview, []string{"amount: 1000 USD >= 1000"}},
+		{"score at reject", amount(100), with(func(o *Order) { o.TotalCents = 1000 }), nil, DecisionReject, []string{"amount: 1000 USD >= 1000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.rules.Evaluate(context.Background(), tt.order, tt.history)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Decision != tt.decision || !slices.Equal(res.Reasons, append([]string{}, tt.reasons...)) {
+				t.Fatalf("Evaluate = %s %v, want %s %v", res.Decision, res.Reasons, tt.decision, tt.reasons)
+			}
+		})
+	}
+}
+
+type failingHistory struct{}
+
+func (failingHistory) CountOrders(context.Context, string, string, time.Time, string) (int, error) {
+	return 0, errors.New("db down")
+}
+
+func TestEvaluateHistoryError(t *testing.T) {
+	r := &Rules{ReviewScore: 50, RejectScore: 100, Velocity: []VelocityRule{{By: ByUser, Window: Duration(time.Hour), MaxOrders: 1, Score: 50}}}
+	if _, err := r.Evaluate(context.Background(), Order{UserID: "u1"}, failingHistory{}); err == nil {
+		t.Fatal("Evaluate with a failing history succeeded")
+	}
+}
@@ -0,0 +1,89 @@
+package rules
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"ecommerce-order-system/services/fraud-service/internal/metrics"
+)
+
+// Store holds the current rules of a file and swaps them when the file
+// changes. A file that fails to parse is reported and the previous rules stay
+// in effect.
+type Store struct {
+	Path string
+	Log  zerolog.Logger
+
+	current  atomic.Pointer[Rules]
+	mu       sync.Mutex
+	modTime  time.Time
+	loadedAt time.Time
+}
+
+// Load reads the file; at startup a bad file is fatal, so the error is
+// returned instead of keeping the (absent) previous rules.
+func (s *Store) Load() error {
+	_, err := s.reload(true)
+	return err
+}
+
+// Current returns the rules in effect.
+func (s *Store) Current() *Rules { return s.current.Load() }
+
+// LoadedAt reports when the current rules were read.
+func (s *Store) LoadedAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadedAt
+}
+
+// Watch re-reads the file whenever its modification time changes, checking
+// every interval until ctx is done.
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			reloaded, err := s.reload(false)
+			switch {
+			case err != nil:
+				metrics.RulesReloadsTotal.WithLabelValues("error").Inc()
+				s.Log.Error().Err(err).Str("path", s.Path).Msg("fraud rules reload failed, keeping previous rules")
+			case reloaded:
+				metrics.RulesReloadsTotal.WithLabelValues("ok").Inc()
+				s.Log.Info().Str("path", s.Path).Msg("fraud rules reloaded")
+			}
+		}
+	}
+}
+
+func (s *Store) reload(force bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fi, err := os.Stat(s.Path)
+	if err != nil {
+		return false, err
+	}
+	if !force && fi.ModTime().Equal(s.modTime) {
+		return false, nil
+	}
+	// remember the attempt, so a bad file is reported once, not every tick
+	s.modTime = fi.ModTime()
+
+	r, err := ParseFile(s.Path)
+	if err != nil {
+		return false, err
+	}
+	s.current.Store(r)
+	s.loadedAt = time.Now()
+	return true, nil
+}
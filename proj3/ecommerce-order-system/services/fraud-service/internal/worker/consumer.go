@@ -0,0 +1,215 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/rs/zerolog"
+
+	"ecommerce-order-system/services/fraud-service/internal/metrics"
+	"ecommerce-order-system/services/fraud-service/internal/repo"
+	"ecommerce-order-system/services/fraud-service/internal/rules"
+	"ecommerce-order-system/shared/pkg/logger"
+	"ecommerce-order-system/shared/pkg/models"
+	"ecommerce-order-system/shared/pkg/rabbit"
+)
+
+type Consumer struct {
+	Log zerolog.Logger
+
+	EventsPub *rabbit.Publisher
+	RetryPub  *rabbit.Publisher
+	DLQPub    *rabbit.Publisher
+
+	// Events declares the payload versions this consumer understands.
+	Events models.Decoder
+
+	Screenings *repo.ScreeningsPG
+	Rules      *rules.Store
+	Holds      *repo.HoldsPG
+
+	// HoldTimeout is how long a held order waits for review. Choreography
+	// says whether a rejected hold must cancel the order itself (no saga).
+	HoldTimeout  time.Duration
+	Choreography bool
+
+	Service     string
+	MaxAttempts int
+	DLQKey      string
+}
+
+func (c *Consumer) Run(ctx context.Context, deliveries <-chan amqp.Delivery) {
+	c.Log.Info().Msg("fraud consumer started")
+	for {
+		select {
+		case <-ctx.Done():
+			c.Log.Info().Msg("fraud consumer stopped")
+			return
+		case d, ok := <-deliveries:
+			if !ok {
+				c.Log.Info().Msg("deliveries closed")
+				return
+			}
+			c.handle(ctx, d)
+		}
+	}
+}
+
+func (c *Consumer) handle(ctx context.Context, d amqp.Delivery) {
+	evt, err := rabbit.DecodeDelivery(c.Events, d)
+	if errors.Is(err, models.ErrSkipped) {
+		_ = d.Ack(false)
+		c.Log.Debug().Str("rk", d.RoutingKey).Int("version", evt.Version).Msg("dual-published copy skipped")
+		return
+	}
+	if err != nil {
+		c.Log.Error().Err(err).Str("rk", d.RoutingKey).Msg("invalid event -> dlq")
+		_ = rabbit.RetryOrDLQ(ctx, d, c.Service, 0, c.RetryPub, c.DLQPub, c.DLQKey)
+		return
+	}
+	if evt.OrderID == "" || evt.ID == "" {
+		c.Log.Error().Str("rk", d.RoutingKey).Msg("missing order_id/event_id -> dlq")
+		_ = rabbit.RetryOrDLQ(ctx, d, c.Service, 0, c.RetryPub, c.DLQPub, c.DLQKey)
+		return
+	}
+
+	ctx = models.ContextWithEvent(ctx, evt)
+	log := logger.ForEvent(c.Log, evt)
+
+	var msg string
+	switch d.RoutingKey {
+	case models.TypeOrderCreated:
+		msg, err = c.record(ctx, evt)
+	case models.TypeInventoryReserved, models.CmdFraudScreen:
+		msg, err = c.screen(ctx, d.RoutingKey == models.CmdFraudScreen, evt)
+	default:
+		log.Warn().Str("rk", d.RoutingKey).Msg("unexpected routing key -> ack")
+		_ = d.Ack(false)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("fraud step failed -> retry/dlq")
+		_ = rabbit.RetryOrDLQ(ctx, d, c.Service, int32(c.MaxAttempts), c.RetryPub, c.DLQPub, c.DLQKey)
+		return
+	}
+	_ = d.Ack(false)
+	if msg != "" {
+		log.Info().Msg(msg)
+	}
+}
+
+// record keeps what screening needs from the order; velocity rules count
+// these records.
+func (c *Consumer) record(ctx context.Context, evt models.Event[json.RawMessage]) (string, error) {
+	var p models.OrderCreatedPayload
+	if err := json.Unmarshal(evt.Payload, &p); err != nil {
+		return "", fmt.Errorf("orders.created payload: %w", err)
+	}
+	skus := make([]string, 0, len(p.Items))
+	for _, it := range p.Items {
+		skus = append(skus, it.SKU)
+	}
+	err := c.Screenings.Record(ctx, rules.Order{
+		OrderID:         evt.OrderID,
+		UserID:          p.UserID,
+		Email:           p.Email,
+		TotalCents:      p.TotalCents,
+		Currency:        p.Currency,
+		SKUs:            skus,
+		BillingCountry:  p.BillingCountry,
+		ShippingCountry: p.ShippingCountry,
+		IPCountry:       p.IPCountry,
+	}, p.Snapshot())
+	if err != nil {
+		return "", err
+	}
+	return "order recorded for screening", nil
+}
+
+// screen decides once per order whether it may go on to payment. Answering a
+// command only reports the result; in choreography a rejection also releases
+// stock and cancels the order. The order snapshot passed on is the one
+// recorded from orders.created; inventory.reserved carries it as well and
+// fills it in for an order recorded without one.
+func (c *Consumer) screen(ctx context.Context, command bool, evt models.Event[json.RawMessage]) (string, error) {
+	s, err := c.Screenings.Get(ctx, evt.OrderID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// orders.created travels on its own; retry until it is recorded
+		return "", fmt.Errorf("order %s not recorded yet", evt.OrderID)
+	}
+	if err != nil {
+		return "", err
+	}
+	if !command && s.Snapshot == nil {
+		var reserved models.InventoryReservedPayload
+		if err := json.Unmarshal(evt.Payload, &reserved); err != nil {
+			return "", fmt.Errorf("inventory.reserved payload: %w", err)
+		}
+		if reserved.Order != nil {
+			if err := c.Screenings.KeepSnapshot(ctx, evt.OrderID, reserved.Order); err != nil {
+				return "", err
+			}
+			s.Snapshot = reserved.Order
+		}
+	}
+
+	res := s.Result
+	if res.Decision == "" {
+		r, err := c.Rules.Current().Evaluate(ctx, s.Order, c.Screenings)
+		if err != nil {
+			return "", err
+		}
+		var decided bool
+		res, decided, err = c.Screenings.Decide(ctx, evt.OrderID, r)
+		if err != nil {
+			return "", err
+		}
+		if decided {
+			metrics.ScreeningsTotal.WithLabelValues(res.Decision).Inc()
+		}
+	}
+
+	var out []models.Event[any]
+	switch res.Decision {
+	case rules.DecisionPass:
+		out = append(out, models.NewEvent[any](models.TypeFraudPassed, evt.OrderID, models.FraudPassedPayload{
+			Score: res.Score, Reasons: res.Reasons, Order: s.Snapshot,
+		}))
+	case rules.DecisionReview:
+		out, err = c.hold(ctx, evt.OrderID, res, s.Snapshot, !command)
+		if err != nil {
+			return "", err
+		}
+	default:
+		reason := "fraud suspected"
+		out = append(out, models.NewEvent[any](models.TypeOrderRejectedFraud, evt.OrderID, models.OrderRejectedFraudPayload{
+			Score: res.Score, Reasons: res.Reasons, Reason: reason,
+		}))
+		if !command {
+			out = append(out,
+				models.NewEvent[any](models.TypeInventoryReleaseRequested, evt.OrderID, models.InventoryReleaseRequestedPayload{Reason: reason}),
+				models.NewEvent[any](models.TypeOrderCancelled, evt.OrderID, models.OrderCancelledPayload{Reason: reason}))
+		}
+	}
+	if err := c.publish(ctx, out...); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("fraud screening: %s (score %d)", res.Decision, res.Score), nil
+}
+
+// publish sends events in order and stops at the first failure.
+func (c *Consumer) publish(ctx context.Context, events ...models.Event[any]) error {
+	pubCtx, cancel := rabbit.WithTimeout(ctx)
+	defer cancel()
+	for _, e := range events {
+		if err := c.EventsPub.PublishJSON(pubCtx, e.Type, e, nil); err != nil {
+			return fmt.Errorf("publish %s: %w", e.Type, err)
+		}
+	}
+	return nil
+}
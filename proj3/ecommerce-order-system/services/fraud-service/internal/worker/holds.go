@@ -0,0 +1,145 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"ecommerce-order-system/services/fraud-service/internal/metrics"
+	"ecommerce-order-system/services/fraud-service/internal/repo"
+	"ecommerce-order-system/services/fraud-service/internal/rules"
+	"ecommerce-order-system/shared/pkg/models"
+	"ecommerce-order-system/shared/pkg/outbox"
+)
+
+// ReviewerSystem is recorded as the reviewer of holds rejected at their deadline.
+const ReviewerSystem = "system"
+
+// hold puts an order sent to manual review on hold and returns the events to
+// publish. If the order was held and resolved before (redelivery), the
+// resolution is answered again instead.
+func (c *Consumer) hold(ctx context.Context, orderID string, res rules.Result, order *models.OrderSnapshotPayload, choreography bool) ([]models.Event[any], error) {
+	links, _ := models.LinksFromContext(ctx)
+	h, err := c.Holds.Open(ctx, repo.Hold{
+		OrderID:       orderID,
+		Reason:        fmt.Sprintf("fraud score %d", res.Score),
+		Reasons:       res.Reasons,
+		DeadlineAt:    time.Now().Add(c.HoldTimeout),
+		CorrelationID: links.CorrelationID,
+		Seq:           links.Seq + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if h.Status != repo.HoldHeld {
+		return resolution(h, order, choreography), nil
+	}
+	return []models.Event[any]{
+		models.NewEvent[any](models.TypeFraudReviewRequired, orderID, models.FraudReviewRequiredPayload{
+			Score: res.Score, Reasons: res.Reasons,
+		}),
+		models.NewEvent[any](models.TypeOrderHeld, orderID, models.OrderHeldPayload{
+			Reason:   h.Reason,
+			Reasons:  h.Reasons,
+			Deadline: h.DeadlineAt.UTC().Format(time.RFC3339),
+		}),
+	}, nil
+}
+
+// Approve releases a held order to payment.
+func (c *Consumer) Approve(ctx context.Context, orderID, reason, by string) (repo.Hold, error) {
+	return c.resolve(ctx, orderID, repo.HoldApproved, reason, by)
+}
+
+// Reject cancels a held order.
+func (c *Consumer) Reject(ctx context.Context, orderID, reason, by string) (repo.Hold, error) {
+	return c.resolve(ctx, orderID, repo.HoldRejected, reason, by)
+}
+
+func (c *Consumer) resolve(ctx context.Context, orderID, status, reason, by string) (repo.Hold, error) {
+	h, err := c.Holds.Resolve(ctx, orderID, status, reason, by, func(tx pgx.Tx, h repo.Hold) error {
+		return c.enqueueResolution(ctx, tx, h)
+	})
+	if err != nil {
+		return h, err
+	}
+	metrics.HoldsResolvedTotal.WithLabelValues(h.Status, "review").Inc()
+	c.Log.Info().Str("order_id", h.OrderID).Str("status", h.Status).Str("reviewed_by", by).Str("reason", reason).Msg("hold resolved")
+	return h, nil
+}
+
+// RunHoldExpiry rejects holds past their deadline every interval until ctx
+// is done.
+func (c *Consumer) RunHoldExpiry(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			for {
+				h, expired, err := c.Holds.RejectExpired(ctx, "manual review deadline passed", ReviewerSystem, func(tx pgx.Tx, h repo.Hold) error {
+					return c.enqueueResolution(ctx, tx, h)
+				})
+				if err != nil {
+					c.Log.Error().Err(err).Msg("reject expired hold failed")
+				}
+				if err != nil || !expired {
+					break
+				}
+				metrics.HoldsResolvedTotal.WithLabelValues(h.Status, "deadline").Inc()
+				c.Log.Warn().Str("order_id", h.OrderID).Time("deadline", h.DeadlineAt).Msg("hold not reviewed in time, rejected")
+			}
+		}
+	}
+}
+
+// enqueueResolution writes what a resolved hold publishes to the outbox in
+// the transaction that resolves it, in the order's chain; outbox-worker
+// publishes it once the resolution is committed.
+func (c *Consumer) enqueueResolution(ctx context.Context, tx pgx.Tx, h repo.Hold) error {
+	s, err := c.Screenings.Get(ctx, h.OrderID)
+	if err != nil {
+		return fmt.Errorf("screening of held order: %w", err)
+	}
+	ctx = models.ContextWithLinks(ctx, models.Links{CorrelationID: h.CorrelationID, Seq: h.Seq})
+	for _, e := range resolution(h, s.Snapshot, c.Choreography) {
+		if err := outbox.EnqueueEvent(ctx, tx, "fraud-service", e); err != nil {
+			return fmt.Errorf("enqueue %s: %w", e.Type, err)
+		}
+	}
+	return nil
+}
+
+// resolution is what a resolved hold publishes. Besides the hold outcome, an
+// approval answers screening with fraud.passed and a rejection with
+// order.rejected_fraud, so payment (or the saga) goes on exactly as if the
+// rules had decided.
+func resolution(h repo.Hold, order *models.OrderSnapshotPayload, choreography bool) []models.Event[any] {
+	resolved := models.OrderHoldResolvedPayload{Reason: h.ResolutionReason, ReviewedBy: h.ResolvedBy}
+	if h.Status == repo.HoldApproved {
+		return []models.Event[any]{
+			models.NewEvent[any](models.TypeOrderHoldApproved, h.OrderID, resolved),
+			models.NewEvent[any](models.TypeFraudPassed, h.OrderID, models.FraudPassedPayload{
+				Reasons: []string{"manual review approved by " + h.ResolvedBy},
+				Order:   order,
+			}),
+		}
+	}
+	reason := "manual review rejected: " + h.ResolutionReason
+	events := []models.Event[any]{
+		models.NewEvent[any](models.TypeOrderHoldRejected, h.OrderID, resolved),
+		models.NewEvent[any](models.TypeOrderRejectedFraud, h.OrderID, models.OrderRejectedFraudPayload{
+			Reasons: h.Reasons, Reason: reason,
+		}),
+	}
+	if choreography {
+		events = append(events,
+			models.NewEvent[any](models.TypeInventoryReleaseRequested, h.OrderID, models.InventoryReleaseRequestedPayload{Reason: reason}),
+			models.NewEvent[any](models.TypeOrderCancelled, h.OrderID, models.OrderCancelledPayload{Reason: reason}))
+	}
+	return events
+}
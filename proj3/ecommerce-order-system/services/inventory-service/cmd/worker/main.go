@@ -9,6 +9,7 @@ import (
 	"ecommerce-order-system/services/inventory-service/internal/worker"
 	"ecommerce-order-system/shared/pkg/config"
 	"ecommerce-order-system/shared/pkg/logger"
+	"ecommerce-order-system/shared/pkg/models"
 	"ecommerce-order-system/shared/pkg/rabbit"
 )
 
@@ -29,19 +30,26 @@ func main() {
 		log.Fatal().Err(err).Msg("declare base failed")
 	}
 
+	keys, other := cfg.Saga.Bindings(
+		[]string{models.TypeOrderCreated, models.TypeInventoryReleaseRequested},
+		[]string{models.CmdInventoryReserve, models.CmdInventoryRelease},
+	)
+
 	// main queue + dlq
 	if err := rabbit.DeclareQueueWithDLQ(rc.Ch, rabbit.QueueSpec{
-		Name:     "inventory.q",
-		BindKeys: []string{"orders.created", "inventory.release_requested"},
-		DLQKey:   "inventory.dlq",
-		Prefetch: 20,
+		Name:       "inventory.q",
+		BindKeys:   keys,
+		UnbindKeys: other,
+		DLQKey:     "inventory.dlq",
+		Prefetch:   20,
 	}); err != nil {
 		log.Fatal().Err(err).Msg("declare inventory topology failed")
 	}
 
 	// retry per routing key
-	_ = rabbit.DeclareRetryQueue(rc.Ch, "inventory.retry.orders.created.5s", "inventory.orders.created", "orders.created", 5000)
-	_ = rabbit.DeclareRetryQueue(rc.Ch, "inventory.retry.inventory.release_requested.5s", "inventory.inventory.release_requested", "inventory.release_requested", 5000)
+	for _, k := range keys {
+		_ = rabbit.DeclareRetryQueue(rc.Ch, "inventory.retry."+k+".5s", "inventory."+k, k, 5000)
+	}
 
 	deliveries, err := rabbit.NewConsumer(rc.Ch).Consume("inventory.q", 20)
 	if err != nil {
@@ -49,10 +57,14 @@ func main() {
 	}
 
 	w := &worker.Consumer{
-		Log:         log,
-		EventsPub:   rabbit.NewPublisher(rc.Ch, rabbit.ExchangeEvents),
-		RetryPub:    rabbit.NewPublisher(rc.Ch, rabbit.ExchangeRetry),
-		DLQPub:      rabbit.NewPublisher(rc.Ch, rabbit.ExchangeDLX),
+		Log:       log,
+		EventsPub: rabbit.NewPublisher(rc.Ch, rabbit.ExchangeEvents).UseFormat(cfg.Events.Format, "inventory-service").UseEncoding(cfg.Events.Encoding),
+		RetryPub:  rabbit.NewPublisher(rc.Ch, rabbit.ExchangeRetry),
+		DLQPub:    rabbit.NewPublisher(rc.Ch, rabbit.ExchangeDLX),
+		Events: models.Decoder{Accept: map[string][]int{
+			// only the envelope is read, so every orders.created version works
+			models.TypeOrderCreated: {1, 2},
+		}},
 		Service:     "inventory",
 		MaxAttempts: 5,
 		DLQKey:      "inventory.dlq",
@@ -3,15 +3,14 @@ package worker
 import (
 	"context"
 	"encoding/json"
-	"time"
+	"errors"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/rs/zerolog"
 
+	"ecommerce-order-system/shared/pkg/logger"
 	"ecommerce-order-system/shared/pkg/models"
 	"ecommerce-order-system/shared/pkg/rabbit"
-
-	"github.com/google/uuid"
 )
 
 type Consumer struct {
@@ -21,19 +20,14 @@ type Consumer struct {
 	RetryPub  *rabbit.Publisher
 	DLQPub    *rabbit.Publisher
 
+	// Events declares the payload versions this consumer understands.
+	Events models.Decoder
+
 	Service     string
 	MaxAttempts int
 	DLQKey      string
 }
 
-type InventoryReservedPayload struct {
-	Note string `json:"note,omitempty"`
-}
-
-type InventoryReleasedPayload struct {
-	Note string `json:"note,omitempty"`
-}
-
 func (c *Consumer) Run(ctx context.Context, deliveries <-chan amqp.Delivery) {
 	c.Log.Info().Msg("inventory consumer started")
 	for {
@@ -52,9 +46,14 @@ func (c *Consumer) Run(ctx context.Context, deliveries <-chan amqp.Delivery) {
 }
 
 func (c *Consumer) handle(ctx context.Context, d amqp.Delivery) {
-	var evt models.Event[json.RawMessage]
-	if err := json.Unmarshal(d.Body, &evt); err != nil {
-		c.Log.Error().Err(err).Str("rk", d.RoutingKey).Msg("bad json -> dlq")
+	evt, err := rabbit.DecodeDelivery(c.Events, d)
+	if errors.Is(err, models.ErrSkipped) {
+		_ = d.Ack(false)
+		c.Log.Debug().Str("rk", d.RoutingKey).Int("version", evt.Version).Msg("dual-published copy skipped")
+		return
+	}
+	if err != nil {
+		c.Log.Error().Err(err).Str("rk", d.RoutingKey).Msg("invalid event -> dlq")
 		_ = rabbit.RetryOrDLQ(ctx, d, c.Service, 0, c.RetryPub, c.DLQPub, c.DLQKey)
 		return
 	}
@@ -64,51 +63,51 @@ func (c *Consumer) handle(ctx context.Context, d amqp.Delivery) {
 		return
 	}
 
+	ctx = models.ContextWithEvent(ctx, evt)
+	log := logger.ForEvent(c.Log, evt)
+
 	switch d.RoutingKey {
-	case "orders.created":
-		reserved := models.Event[InventoryReservedPayload]{
-			ID:      uuid.NewString(),
-			Type:    "inventory.reserved",
-			Version: 1,
-			Time:    time.Now(),
-			OrderID: evt.OrderID,
-			Payload: InventoryReservedPayload{Note: "reserved"},
+	case models.TypeOrderCreated, models.CmdInventoryReserve:
+		payload := models.InventoryReservedPayload{Note: "reserved"}
+		if d.RoutingKey == models.TypeOrderCreated {
+			// in choreography the order travels on with the reservation
+			var order models.OrderCreatedPayload
+			if err := json.Unmarshal(evt.Payload, &order); err != nil {
+				log.Error().Err(err).Msg("orders.created payload -> dlq")
+				_ = rabbit.RetryOrDLQ(ctx, d, c.Service, 0, c.RetryPub, c.DLQPub, c.DLQKey)
+				return
+			}
+			payload.Order = order.Snapshot()
 		}
+		reserved := models.NewEvent(models.TypeInventoryReserved, evt.OrderID, payload)
 		pubCtx, cancel := rabbit.WithTimeout(ctx)
-		err := c.EventsPub.PublishJSON(pubCtx, reserved.Type, reserved, amqp.Table{"x-correlation-id": evt.ID})
+		err := c.EventsPub.PublishJSON(pubCtx, reserved.Type, reserved, nil)
 		cancel()
 		if err != nil {
-			c.Log.Error().Err(err).Str("order_id", evt.OrderID).Msg("publish inventory.reserved failed -> retry/dlq")
+			log.Error().Err(err).Msg("publish inventory.reserved failed -> retry/dlq")
 			_ = rabbit.RetryOrDLQ(ctx, d, c.Service, int32(c.MaxAttempts), c.RetryPub, c.DLQPub, c.DLQKey)
 			return
 		}
 		_ = d.Ack(false)
-		c.Log.Info().Str("order_id", evt.OrderID).Msg("inventory reserved")
+		log.Info().Msg("inventory reserved")
 		return
 
-	case "inventory.release_requested":
-		released := models.Event[InventoryReleasedPayload]{
-			ID:      uuid.NewString(),
-			Type:    "inventory.released",
-			Version: 1,
-			Time:    time.Now(),
-			OrderID: evt.OrderID,
-			Payload: InventoryReleasedPayload{Note: "released"},
-		}
+	case models.TypeInventoryReleaseRequested, models.CmdInventoryRelease:
+		released := models.NewEvent(models.TypeInventoryReleased, evt.OrderID, models.InventoryReleasedPayload{Note: "released"})
 		pubCtx, cancel := rabbit.WithTimeout(ctx)
-		err := c.EventsPub.PublishJSON(pubCtx, released.Type, released, amqp.Table{"x-correlation-id": evt.ID})
+		err := c.EventsPub.PublishJSON(pubCtx, released.Type, released, nil)
 		cancel()
 		if err != nil {
-			c.Log.Error().Err(err).Str("order_id", evt.OrderID).Msg("publish inventory.released failed -> retry/dlq")
+			log.Error().Err(err).Msg("publish inventory.released failed -> retry/dlq")
 			_ = rabbit.RetryOrDLQ(ctx, d, c.Service, int32(c.MaxAttempts), c.RetryPub, c.DLQPub, c.DLQKey)
 			return
 		}
 		_ = d.Ack(false)
-		c.Log.Info().Str("order_id", evt.OrderID).Msg("inventory released (compensation)")
+		log.Info().Msg("inventory released (compensation)")
 		return
 
 	default:
-		c.Log.Warn().Str("rk", d.RoutingKey).Str("order_id", evt.OrderID).Msg("unexpected routing key -> ack")
+		log.Warn().Str("rk", d.RoutingKey).Msg("unexpected routing key -> ack")
 		_ = d.Ack(false)
 		return
 	}
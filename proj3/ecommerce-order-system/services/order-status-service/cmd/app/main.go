@@ -72,6 +72,7 @@ func main() {
 	appCtx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go w.Run(appCtx, deliveries)
+	go w.RunReorderSweep(appCtx, cfg.OrderStatus.ReorderSweepInterval, cfg.OrderStatus.ReorderTimeout)
 
 	addr := os.Getenv("ORDER_STATUS_HTTP_ADDR")
 	if addr == "" {
@@ -79,8 +80,8 @@ func main() {
 	}
 
 	get := &httpx.GetOrderHandler{
-		GetStatus: func(r *http.Request, orderID string) (string, error) {
-			return repoOrders.GetStatus(r.Context(), orderID)
+		GetOrder: func(r *http.Request, orderID string) (repo.Order, error) {
+			return repoOrders.Get(r.Context(), orderID)
 		},
 	}
 
@@ -0,0 +1,56 @@
+// Command replay rebuilds the order status projection from the event store.
+//
+//	go run ./services/order-status-service/cmd/replay              # every stored order
+//	go run ./services/order-status-service/cmd/replay -order <id>  # one order
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	"ecommerce-order-system/services/order-status-service/internal/worker"
+	"ecommerce-order-system/shared/pkg/config"
+	"ecommerce-order-system/shared/pkg/eventstore"
+	"ecommerce-order-system/shared/pkg/logger"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func main() {
+	orders := flag.String("order", "", "comma-separated order IDs to rebuild (default: all stored orders)")
+	timeout := flag.Duration("timeout", 10*time.Minute, "overall replay timeout")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		panic(err)
+	}
+	log := logger.New("order-status-replay", cfg.Common.LogLevel)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	db, err := pgxpool.New(ctx, cfg.Postgres.DSN)
+	if err != nil {
+		log.Fatal().Err(err).Msg("pg connect failed")
+	}
+	defer db.Close()
+
+	var ids []string
+	for _, id := range strings.Split(*orders, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	store := &eventstore.StorePG{DB: db}
+	stats, err := store.Replay(ctx, worker.StatusProjection{}, ids)
+	if err != nil {
+		log.Error().Err(err).Int("orders", stats.Orders).Int("events", stats.Events).Msg("replay failed")
+		os.Exit(1)
+	}
+	log.Info().Int("orders", stats.Orders).Int("events", stats.Events).Msg("status projection rebuilt")
+}
@@ -1,13 +1,16 @@
 package httpx
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+
+	"ecommerce-order-system/services/order-status-service/internal/repo"
 )
 
 type GetOrderHandler struct {
-	GetStatus func(r *http.Request, orderID string) (string, error)
+	GetOrder func(r *http.Request, orderID string) (repo.Order, error)
 }
 
 func Health(w http.ResponseWriter, r *http.Request) {
@@ -21,11 +24,11 @@ func (h *GetOrderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "missing id", http.StatusBadRequest)
 		return
 	}
-	s, err := h.GetStatus(r, id)
+	o, err := h.GetOrder(r, id)
 	if err != nil {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	_, _ = w.Write([]byte(`{"status":"` + s + `"}`))
+	_ = json.NewEncoder(w).Encode(o)
 }
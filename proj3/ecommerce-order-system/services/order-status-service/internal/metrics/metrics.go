@@ -0,0 +1,12 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var ReorderedEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "order_status_reordered_events_total",
+	Help: "Events that arrived before their cause: buffered, released once the cause was applied, expired (applied after ORDER_STATUS_REORDER_TIMEOUT), or stale (older than the applied status, not applied)",
+}, []string{"outcome"})
+
+func init() {
+	prometheus.MustRegister(ReorderedEventsTotal)
+}
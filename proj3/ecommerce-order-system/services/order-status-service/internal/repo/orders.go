@@ -2,35 +2,255 @@ package repo
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type OrdersPG struct{ DB *pgxpool.Pool }
 
-func (r *OrdersPG) GetStatus(ctx context.Context, orderID string) (string, error) {
-	var s string
-	err := r.DB.QueryRow(ctx, `select status from orders where id = $1`, orderID).Scan(&s)
-	return s, err
+// Order is the order detail served by the API.
+type Order struct {
+	Status        string `json:"status"`
+	TotalCents    int    `json:"total_cents"`
+	Currency      string `json:"currency"`
+	RefundedCents int64  `json:"refunded_cents"`
 }
 
-// TryMarkProcessed returns true if inserted (new), false if already processed.
-func (r *OrdersPG) TryMarkProcessed(ctx context.Context, eventID string) (bool, error) {
-	ct, err := r.DB.Exec(ctx, `insert into processed_events(event_id) values ($1) on conflict do nothing`, eventID)
+func (r *OrdersPG) Get(ctx context.Context, orderID string) (Order, error) {
+	var o Order
+	err := r.DB.QueryRow(ctx, `
+		select status, total_cents, currency, refunded_cents from orders where id = $1
+	`, orderID).Scan(&o.Status, &o.TotalCents, &o.Currency, &o.RefundedCents)
+	return o, err
+}
+
+// Outcomes of Apply.
+const (
+	OutcomeApplied   = "applied"
+	OutcomeBuffered  = "buffered"
+	OutcomeDuplicate = "duplicate"
+	OutcomeStale     = "stale"
+)
+
+// decide returns what happens to an event with seq for an order whose last
+// applied seq is lastSeq: an event more than one past it is buffered, one
+// behind it is stale and leaves the status alone, the rest are applied.
+// Events without seq are always applied.
+func decide(seq, lastSeq int64) string {
+	switch {
+	case seq == 0:
+		return OutcomeApplied
+	case seq > lastSeq+1:
+		return OutcomeBuffered
+	case seq < lastSeq:
+		return OutcomeStale
+	default:
+		return OutcomeApplied
+	}
+}
+
+// Event is what Apply needs from an event. Status is the order status it
+// sets, empty for events that only advance the sequence. RefundedCents is the
+// refund total reported by payment.refunded; totals only grow, so it is
+// applied at once, even if the event is buffered.
+type Event struct {
+	OrderID       string
+	ID            string
+	Type          string
+	Seq           int64
+	Status        string
+	RefundedCents int64
+}
+
+// ApplyResult reports what Apply did. Released counts buffered events that
+// became applicable because of this one.
+type ApplyResult struct {
+	Outcome  string
+	Released int
+}
+
+// Apply updates the order status for evt in causal order (see ApplyTx). The
+// event is marked processed in the same transaction, so redeliveries are
+// ignored whether it was applied or buffered.
+func (r *OrdersPG) Apply(ctx context.Context, evt Event) (ApplyResult, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return ApplyResult{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	ct, err := tx.Exec(ctx, `insert into processed_events(event_id) values ($1) on conflict do nothing`, evt.ID)
 	if err != nil {
-		return false, err
+		return ApplyResult{}, err
+	}
+	if ct.RowsAffected() == 0 {
+		return ApplyResult{Outcome: OutcomeDuplicate}, nil
+	}
+
+	res, err := ApplyTx(ctx, tx, evt)
+	if err != nil {
+		return ApplyResult{}, err
+	}
+	return res, tx.Commit(ctx)
+}
+
+// ApplyTx applies evt within tx. An event whose seq is more than one past the
+// order's last_seq arrived before its cause and is buffered in
+// order_status_pending; one older than last_seq came after a later event was
+// applied and does not change the status. Otherwise it is applied together
+// with every buffered event it unblocks. Events without seq are applied at
+// once.
+func ApplyTx(ctx context.Context, tx pgx.Tx, evt Event) (ApplyResult, error) {
+	var lastSeq int64
+	err := tx.QueryRow(ctx, `select last_seq from orders where id = $1::uuid for update`, evt.OrderID).Scan(&lastSeq)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// unknown order: nothing to update or to wait for
+		return ApplyResult{Outcome: OutcomeApplied}, nil
+	}
+	if err != nil {
+		return ApplyResult{}, err
+	}
+
+	if evt.RefundedCents > 0 {
+		if _, err := tx.Exec(ctx, `
+			update orders set refunded_cents = greatest(refunded_cents, $2), updated_at = now() where id = $1::uuid
+		`, evt.OrderID, evt.RefundedCents); err != nil {
+			return ApplyResult{}, err
+		}
+	}
+
+	switch decide(evt.Seq, lastSeq) {
+	case OutcomeStale:
+		return ApplyResult{Outcome: OutcomeStale}, nil
+	case OutcomeBuffered:
+		if _, err := tx.Exec(ctx, `
+			insert into order_status_pending (order_id, event_id, event_type, seq, status)
+			values ($1::uuid, $2, $3, $4, $5)
+			on conflict do nothing
+		`, evt.OrderID, evt.ID, evt.Type, evt.Seq, evt.Status); err != nil {
+			return ApplyResult{}, err
+		}
+		return ApplyResult{Outcome: OutcomeBuffered}, nil
+	}
+
+	if err := applyStatus(ctx, tx, evt.OrderID, evt.Status, evt.Seq); err != nil {
+		return ApplyResult{}, err
+	}
+	released, err := drainPending(ctx, tx, evt.OrderID, max(lastSeq, evt.Seq), nil)
+	if err != nil {
+		return ApplyResult{}, err
+	}
+	return ApplyResult{Outcome: OutcomeApplied, Released: released}, nil
+}
+
+// ReleaseExpired stops waiting for causes that never came: for each order with
+// an event buffered longer than olderThan, the expired events are applied in
+// seq order, then whatever they unblock. Returns the number of events applied.
+func (r *OrdersPG) ReleaseExpired(ctx context.Context, olderThan time.Duration) (int, error) {
+	cutoff := time.Now().Add(-olderThan)
+	rows, err := r.DB.Query(ctx, `
+		select distinct order_id::text
+		from order_status_pending
+		where received_at < $1
+	`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	orderIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, orderID := range orderIDs {
+		n, err := r.releaseExpired(ctx, orderID, cutoff)
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func (r *OrdersPG) releaseExpired(ctx context.Context, orderID string, cutoff time.Time) (int, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var lastSeq int64
+	if err := tx.QueryRow(ctx, `select last_seq from orders where id = $1::uuid for update`, orderID).Scan(&lastSeq); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			_, err = tx.Exec(ctx, `delete from order_status_pending where order_id = $1::uuid`, orderID)
+			if err != nil {
+				return 0, err
+			}
+			return 0, tx.Commit(ctx)
+		}
+		return 0, err
+	}
+
+	n, err := drainPending(ctx, tx, orderID, lastSeq, &cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit(ctx)
+}
+
+// drainPending applies buffered events of an order whose cause has been
+// applied, lowest seq first. With cutoff set, events buffered before it are
+// applied regardless of gaps, and an event left behind by such a jump is
+// dropped as stale. The order row must be locked by tx.
+func drainPending(ctx context.Context, tx pgx.Tx, orderID string, lastSeq int64, cutoff *time.Time) (int, error) {
+	n := 0
+	for {
+		var (
+			eventID, status string
+			seq             int64
+		)
+		err := tx.QueryRow(ctx, `
+			select event_id, status, seq
+			from order_status_pending
+			where order_id = $1::uuid
+			  and (seq <= $2 + 1 or received_at < $3)
+			order by seq, received_at
+			limit 1
+		`, orderID, lastSeq, cutoff).Scan(&eventID, &status, &seq)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if decide(seq, lastSeq) != OutcomeStale {
+			if err := applyStatus(ctx, tx, orderID, status, seq); err != nil {
+				return n, err
+			}
+		}
+		if _, err := tx.Exec(ctx, `delete from order_status_pending where order_id = $1::uuid and event_id = $2`, orderID, eventID); err != nil {
+			return n, err
+		}
+		lastSeq = max(lastSeq, seq)
+		n++
 	}
-	return ct.RowsAffected() == 1, nil
 }
 
-// UpdateStatus applies terminal guard: do not override completed/cancelled.
-func (r *OrdersPG) UpdateStatus(ctx context.Context, orderID string, status string) error {
-	_, err := r.DB.Exec(ctx, `
+// applyStatus sets the status with the terminal guard (completed/cancelled are
+// never overridden) and advances last_seq.
+func applyStatus(ctx context.Context, tx pgx.Tx, orderID, status string, seq int64) error {
+	_, err := tx.Exec(ctx, `
 		update orders
-		set status = $2,
+		set status = case
+		      when $2 = '' or status in ('completed','cancelled') then status
+		      else $2
+		    end,
+		    last_seq = greatest(last_seq, $3),
 		    updated_at = now()
-		where id = $1
-		  and status not in ('completed','cancelled')
-	`, orderID, status)
+		where id = $1::uuid
+	`, orderID, status, seq)
 	return err
 }
@@ -0,0 +1,30 @@
+package repo
+
+import "testing"
+
+func TestDecide(t *testing.T) {
+	tests := []struct {
+		name    string
+		seq     int64
+		lastSeq int64
+		want    string
+	}{
+		{"no seq", 0, 5, OutcomeApplied},
+		{"no seq on a new order", 0, 0, OutcomeApplied},
+		{"next in chain", 6, 5, OutcomeApplied},
+		{"sibling of the last applied", 5, 5, OutcomeApplied},
+		{"first event", 1, 0, OutcomeApplied},
+		{"cause missing", 7, 5, OutcomeBuffered},
+		{"far ahead", 20, 5, OutcomeBuffered},
+		{"before its cause on a new order", 2, 0, OutcomeBuffered},
+		{"one behind", 4, 5, OutcomeStale},
+		{"far behind", 1, 5, OutcomeStale},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decide(tt.seq, tt.lastSeq); got != tt.want {
+				t.Fatalf("decide(%d, %d) = %q, want %q", tt.seq, tt.lastSeq, got, tt.want)
+			}
+		})
+	}
+}
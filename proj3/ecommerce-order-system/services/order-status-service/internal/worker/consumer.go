@@ -3,12 +3,16 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"strings"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/rs/zerolog"
 
+	"ecommerce-order-system/services/order-status-service/internal/metrics"
 	"ecommerce-order-system/services/order-status-service/internal/repo"
+	"ecommerce-order-system/shared/pkg/logger"
 	"ecommerce-order-system/shared/pkg/models"
 	"ecommerce-order-system/shared/pkg/rabbit"
 )
@@ -20,6 +24,9 @@ type Consumer struct {
 	RetryPub *rabbit.Publisher
 	DLQPub   *rabbit.Publisher
 
+	// Events declares the payload versions this consumer understands.
+	Events models.Decoder
+
 	Service     string
 	MaxAttempts int
 	DLQKey      string
@@ -43,9 +50,19 @@ func (c *Consumer) Run(ctx context.Context, deliveries <-chan amqp.Delivery) {
 }
 
 func (c *Consumer) handle(ctx context.Context, d amqp.Delivery) {
-	var evt models.Event[json.RawMessage]
-	if err := json.Unmarshal(d.Body, &evt); err != nil {
-		c.Log.Error().Err(err).Str("rk", d.RoutingKey).Msg("bad json -> dlq")
+	evt, err := rabbit.DecodeDelivery(c.Events, d)
+	if errors.Is(err, models.ErrSkipped) {
+		_ = d.Ack(false)
+		c.Log.Debug().Str("rk", d.RoutingKey).Int("version", evt.Version).Msg("dual-published copy skipped")
+		return
+	}
+	if errors.Is(err, models.ErrUnregisteredEvent) {
+		_ = d.Ack(false)
+		c.Log.Debug().Str("rk", d.RoutingKey).Msg("unregistered event ignored")
+		return
+	}
+	if err != nil {
+		c.Log.Error().Err(err).Str("rk", d.RoutingKey).Msg("invalid event -> dlq")
 		_ = rabbit.RetryOrDLQ(ctx, d, c.Service, 0, c.RetryPub, c.DLQPub, c.DLQKey)
 		return
 	}
@@ -55,48 +72,103 @@ func (c *Consumer) handle(ctx context.Context, d amqp.Delivery) {
 		return
 	}
 
-	ok, err := c.Repo.TryMarkProcessed(ctx, evt.ID)
+	ctx = models.ContextWithEvent(ctx, evt)
+	log := logger.ForEvent(c.Log, evt)
+
+	res, err := c.Repo.Apply(ctx, repo.Event{
+		OrderID: evt.OrderID,
+		ID:      evt.ID,
+		Type:    evt.Type,
+		Seq:     evt.Seq,
+		Status:  mapRoutingKeyToStatus(d.RoutingKey),
+
+		RefundedCents: refundedCents(evt.Type, evt.Payload),
+	})
 	if err != nil {
-		c.Log.Error().Err(err).Str("order_id", evt.OrderID).Msg("try mark processed failed -> retry/dlq")
+		log.Error().Err(err).Msg("apply event failed -> retry/dlq")
 		_ = rabbit.RetryOrDLQ(ctx, d, c.Service, int32(c.MaxAttempts), c.RetryPub, c.DLQPub, c.DLQKey)
 		return
 	}
-	if !ok {
-		_ = d.Ack(false)
-		c.Log.Debug().Str("event_id", evt.ID).Msg("duplicate event ignored")
-		return
-	}
+	_ = d.Ack(false)
 
-	status := mapRoutingKeyToStatus(d.RoutingKey)
-	if status == "" {
-		_ = d.Ack(false)
-		return
+	switch res.Outcome {
+	case repo.OutcomeDuplicate:
+		log.Debug().Msg("duplicate event ignored")
+	case repo.OutcomeStale:
+		metrics.ReorderedEventsTotal.WithLabelValues("stale").Inc()
+		log.Info().Str("rk", d.RoutingKey).Int64("seq", evt.Seq).Msg("event older than the applied status, status kept")
+	case repo.OutcomeBuffered:
+		metrics.ReorderedEventsTotal.WithLabelValues("buffered").Inc()
+		log.Info().Str("rk", d.RoutingKey).Int64("seq", evt.Seq).Msg("event arrived before its cause, buffered")
+	default:
+		if res.Released > 0 {
+			metrics.ReorderedEventsTotal.WithLabelValues("released").Add(float64(res.Released))
+		}
+		log.Info().Str("rk", d.RoutingKey).Int64("seq", evt.Seq).Int("released", res.Released).Msg("status updated")
 	}
+}
 
-	if err := c.Repo.UpdateStatus(ctx, evt.OrderID, status); err != nil {
-		c.Log.Error().Err(err).Str("order_id", evt.OrderID).Str("status", status).Msg("update status failed -> retry/dlq")
-		_ = rabbit.RetryOrDLQ(ctx, d, c.Service, int32(c.MaxAttempts), c.RetryPub, c.DLQPub, c.DLQKey)
-		return
+// RunReorderSweep applies events that have waited for their cause longer than
+// timeout, every interval until ctx is done.
+func (c *Consumer) RunReorderSweep(ctx context.Context, interval, timeout time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			n, err := c.Repo.ReleaseExpired(ctx, timeout)
+			if err != nil {
+				c.Log.Error().Err(err).Msg("release expired buffered events failed")
+			}
+			if n > 0 {
+				metrics.ReorderedEventsTotal.WithLabelValues("expired").Add(float64(n))
+				c.Log.Warn().Int("events", n).Dur("timeout", timeout).Msg("applied buffered events without their cause")
+			}
+		}
 	}
+}
 
-	_ = d.Ack(false)
-	c.Log.Info().Str("order_id", evt.OrderID).Str("status", status).Str("rk", d.RoutingKey).Msg("status updated")
+// refundedCents is the order's refund total reported by a payment.refunded
+// payload, 0 for other events.
+func refundedCents(eventType string, payload json.RawMessage) int64 {
+	if eventType != models.TypePaymentRefunded {
+		return 0
+	}
+	var p models.PaymentRefundedPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return 0
+	}
+	return int64(p.RefundedTotalCents)
 }
 
 func mapRoutingKeyToStatus(rk string) string {
 	rk = strings.ToLower(rk)
 	switch rk {
-	case "orders.created":
+	case models.TypeOrderCreated:
 		return "created"
-	case "inventory.reserved":
+	case models.TypeInventoryReserved:
 		return "reserved"
-	case "payment.processed":
+	case models.TypeOrderHeld:
+		// published with fraud.review_required, which sets no status of its own
+		return "on_hold"
+	case models.TypeOrderHoldApproved:
+		return "approved"
+	case models.TypePaymentPending:
+		return "payment_pending"
+	case models.TypePaymentDeclined:
+		return "payment_declined"
+	case models.TypePaymentAuthorized:
+		return "payment_authorized"
+	case models.TypePaymentCaptured, models.TypePaymentProcessed:
+		// payment.processed: orders charged before two-phase payments
 		return "paid"
-	case "shipping.scheduled":
+	case models.TypeShippingScheduled:
 		return "shipping_scheduled"
-	case "order.completed":
+	case models.TypeOrderCompleted:
 		return "completed"
-	case "inventory.failed", "payment.failed", "order.cancelled":
+	case models.TypeInventoryFailed, models.TypeOrderRejectedFraud, models.TypeOrderHoldRejected, models.TypePaymentFailed, models.TypePaymentCaptureFailed, models.TypeOrderCancelled:
 		return "cancelled"
 	default:
 		return ""
@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5"
+
+	"ecommerce-order-system/services/order-status-service/internal/repo"
+	"ecommerce-order-system/shared/pkg/eventstore"
+)
+
+// StatusProjection rebuilds orders.status from the event store with the same
+// mapping, causal ordering and terminal guard the live consumer applies.
+type StatusProjection struct{}
+
+func (StatusProjection) Name() string { return "status" }
+
+func (StatusProjection) Reset(ctx context.Context, tx pgx.Tx, orderID string) error {
+	if _, err := tx.Exec(ctx, `update orders set status = 'created', last_seq = 0, refunded_cents = 0, updated_at = now() where id = $1::uuid`, orderID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `delete from order_status_pending where order_id = $1::uuid`, orderID)
+	return err
+}
+
+func (StatusProjection) Apply(ctx context.Context, tx pgx.Tx, rec eventstore.Record) error {
+	// The store keeps arrival order; ApplyTx restores causal order the same
+	// way as for live events.
+	var env struct {
+		Seq     int64           `json:"seq"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	_ = json.Unmarshal(rec.Envelope, &env)
+
+	if _, err := repo.ApplyTx(ctx, tx, repo.Event{
+		OrderID: rec.OrderID,
+		ID:      rec.EventID,
+		Type:    rec.Type,
+		Seq:     env.Seq,
+		Status:  mapRoutingKeyToStatus(rec.Type),
+
+		RefundedCents: refundedCents(rec.Type, env.Payload),
+	}); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `insert into processed_events(event_id) values ($1) on conflict do nothing`, rec.EventID)
+	return err
+}
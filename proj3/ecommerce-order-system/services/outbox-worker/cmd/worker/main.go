@@ -8,8 +8,11 @@ import (
 	"syscall"
 	"time"
 
+	"ecommerce-order-system/services/outbox-worker/internal/cdc"
+	"ecommerce-order-system/services/outbox-worker/internal/coord"
 	httpx "ecommerce-order-system/services/outbox-worker/internal/http"
 	"ecommerce-order-system/services/outbox-worker/internal/outbox"
+	"ecommerce-order-system/services/outbox-worker/internal/sink"
 	"ecommerce-order-system/shared/pkg/config"
 	"ecommerce-order-system/shared/pkg/logger"
 	"ecommerce-order-system/shared/pkg/rabbit"
@@ -22,6 +25,9 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	if err := cfg.ValidateOutboxWorker(); err != nil {
+		panic(err)
+	}
 	log := logger.New("outbox-worker", cfg.Common.LogLevel)
 
 	ctxDB, cancelDB := context.WithTimeout(context.Background(), 5*time.Second)
@@ -42,23 +48,56 @@ func main() {
 		log.Fatal().Err(err).Msg("declare base failed")
 	}
 
-	runner := &outbox.Runner{
-		Log:          log,
-		DB:           db,
-		EventsPub:    rabbit.NewPublisher(rc.Ch, rabbit.ExchangeEvents),
-		PollInterval: 500 * time.Millisecond,
-		BatchSize:    50,
-		MaxAttempts:  10,
-		BackoffMax:   60 * time.Second,
+	pub, err := rabbit.NewConfirmPublisher(rc.Ch, rabbit.ExchangeEvents)
+	if err != nil {
+		log.Fatal().Err(err).Msg("rabbit confirm mode failed")
 	}
+	// Rows carry their producer as headers.source; older rows fall back to the relay.
+	pub.UseFormat(cfg.Events.Format, "outbox-worker").UseEncoding(cfg.Events.Encoding)
+
+	sinks, err := sink.FromConfig(cfg.OutboxSinks, pub)
+	if err != nil {
+		log.Fatal().Err(err).Msg("outbox sinks init failed")
+	}
+	defer func() { _ = sinks.Close() }()
+
+	runner, err := outbox.NewRunner(log, db, sinks, cfg.Outbox)
+	if err != nil {
+		log.Fatal().Err(err).Msg("outbox runner init failed")
+	}
+
+	co := coord.New(log, db, cfg.OutboxCoord)
+	runner.Coord = co
 
 	appCtx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	go runner.Run(appCtx)
+	switch cfg.OutboxRelay.Mode {
+	case config.RelayCDC:
+		retry, err := outbox.NewRetryPolicy(cfg.Outbox)
+		if err != nil {
+			log.Fatal().Err(err).Msg("outbox retry policy failed")
+		}
+		relay := &cdc.Relay{
+			Log:    log,
+			DB:     db,
+			DSN:    cfg.Postgres.DSN,
+			Sink:   sinks,
+			Cfg:    cfg.OutboxRelay,
+			Runner: runner,
+			Coord:  co,
+			Retry:  retry,
+		}
+		go co.Run(appCtx)
+		go relay.Run(appCtx)
+		go runner.RunStats(appCtx)
+	default:
+		go co.Run(appCtx)
+		go runner.Run(appCtx)
+	}
 
 	httpSrv := &http.Server{
 		Addr:              cfg.OutboxHTTP.Addr,
-		Handler:           (&httpx.Server{DB: db}).Handler(),
+		Handler:           (&httpx.Server{DB: db, Runner: runner, Coord: co, AdminToken: cfg.Outbox.AdminToken}).Handler(),
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 	go func() {
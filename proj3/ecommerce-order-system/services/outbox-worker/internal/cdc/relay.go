@@ -0,0 +1,370 @@
+package cdc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+
+	"ecommerce-order-system/services/outbox-worker/internal/coord"
+	"ecommerce-order-system/services/outbox-worker/internal/metrics"
+	"ecommerce-order-system/services/outbox-worker/internal/outbox"
+	"ecommerce-order-system/services/outbox-worker/internal/sink"
+	"ecommerce-order-system/shared/pkg/config"
+)
+
+// Relay streams outbox_events inserts from a pgoutput logical replication slot
+// and publishes each committed transaction's events in commit order. The slot
+// position is confirmed only after every event of a transaction was accepted by
+// the sink (broker confirms for RabbitMQ), so after a crash or restart the server
+// resends everything past the last confirmed LSN. Rows already marked sent or
+// dead are skipped on replay; a row that still fails after MaxAttempts is
+// dead-lettered and the stream moves past it. Before streaming, rows that are
+// already pending are drained through the poll path.
+type Relay struct {
+	Log  zerolog.Logger
+	DB   *pgxpool.Pool
+	DSN  string
+	Sink sink.Sink
+	Cfg  config.OutboxRelayConfig
+	// Runner drains pending rows and supplies MaxAttempts.
+	Runner *outbox.Runner
+	// Coord, when set, lets the relay stream only while this replica is
+	// active (leader).
+	Coord *coord.Coordinator
+	Retry outbox.RetryPolicy
+}
+
+// errInactive stops the stream when the coordinator hands the relay to
+// another replica.
+var errInactive = errors.New("replica not active")
+
+func (r *Relay) Run(ctx context.Context) {
+	attempt := 0
+	for {
+		if !r.active() {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(r.Cfg.StatusInterval):
+			}
+			continue
+		}
+		err := r.Runner.Drain(ctx)
+		if err == nil {
+			err = r.stream(ctx)
+		}
+		if ctx.Err() != nil {
+			r.Log.Info().Msg("outbox cdc relay stopped")
+			return
+		}
+		if errors.Is(err, errInactive) {
+			r.Log.Info().Msg("outbox cdc relay paused: replica no longer active")
+			attempt = 0
+			continue
+		}
+		attempt++
+		delay := r.Retry.Delay(attempt)
+		metrics.OutboxCDCRestartsTotal.Inc()
+		r.Log.Error().Err(err).Int("attempt", attempt).Dur("retry_in", delay).Msg("outbox cdc stream failed -> restarting from confirmed lsn")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+type txState struct {
+	events []outbox.EventRow
+}
+
+func (r *Relay) stream(ctx context.Context) error {
+	conn, err := pgconn.Connect(ctx, replicationDSN(r.DSN))
+	if err != nil {
+		return fmt.Errorf("replication connect: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if err := r.ensureSlot(ctx, conn); err != nil {
+		return err
+	}
+
+	// LSN 0 makes the server resume from the slot's confirmed_flush_lsn.
+	if err := pglogrepl.StartReplication(ctx, conn, r.Cfg.Slot, 0, pglogrepl.StartReplicationOptions{
+		PluginArgs: []string{"proto_version '1'", "publication_names '" + r.Cfg.Publication + "'"},
+	}); err != nil {
+		return fmt.Errorf("start replication: %w", err)
+	}
+	r.Log.Info().Str("slot", r.Cfg.Slot).Str("publication", r.Cfg.Publication).Msg("outbox cdc stream started")
+
+	var (
+		confirmed  pglogrepl.LSN
+		serverEnd  pglogrepl.LSN
+		relations  = map[uint32]*pglogrepl.RelationMessage{}
+		typeMap    = pgtype.NewMap()
+		tx         *txState
+		nextStatus = time.Now().Add(r.Cfg.StatusInterval)
+	)
+
+	sendStatus := func() error {
+		if err := pglogrepl.SendStandbyStatusUpdate(ctx, conn, pglogrepl.StandbyStatusUpdate{WALWritePosition: confirmed}); err != nil {
+			return fmt.Errorf("standby status: %w", err)
+		}
+		metrics.OutboxCDCConfirmedLSN.Set(float64(confirmed))
+		if serverEnd > confirmed {
+			metrics.OutboxCDCLagBytes.Set(float64(serverEnd - confirmed))
+		} else {
+			metrics.OutboxCDCLagBytes.Set(0)
+		}
+		nextStatus = time.Now().Add(r.Cfg.StatusInterval)
+		return nil
+	}
+
+	for {
+		if time.Now().After(nextStatus) {
+			if err := sendStatus(); err != nil {
+				return err
+			}
+			if !r.active() {
+				return errInactive
+			}
+		}
+
+		recvCtx, cancel := context.WithDeadline(ctx, nextStatus)
+		raw, err := conn.ReceiveMessage(recvCtx)
+		cancel()
+		if err != nil {
+			if pgconn.Timeout(err) && ctx.Err() == nil {
+				continue
+			}
+			return fmt.Errorf("receive: %w", err)
+		}
+
+		switch m := raw.(type) {
+		case *pgproto3.ErrorResponse:
+			return fmt.Errorf("replication error: %s", m.Message)
+		case *pgproto3.CopyData:
+			switch m.Data[0] {
+			case pglogrepl.PrimaryKeepaliveMessageByteID:
+				pkm, err := pglogrepl.ParsePrimaryKeepaliveMessage(m.Data[1:])
+				if err != nil {
+					return err
+				}
+				serverEnd = pkm.ServerWALEnd
+				// nothing in flight: everything up to the server end is handled,
+				// confirm it so the slot does not pin WAL on a quiet outbox
+				if tx == nil && pkm.ServerWALEnd > confirmed {
+					confirmed = pkm.ServerWALEnd
+				}
+				if pkm.ReplyRequested {
+					nextStatus = time.Time{}
+				}
+
+			case pglogrepl.XLogDataByteID:
+				xld, err := pglogrepl.ParseXLogData(m.Data[1:])
+				if err != nil {
+					return err
+				}
+				serverEnd = xld.ServerWALEnd
+
+				msg, err := pglogrepl.Parse(xld.WALData)
+				if err != nil {
+					return fmt.Errorf("parse pgoutput: %w", err)
+				}
+				switch msg := msg.(type) {
+				case *pglogrepl.RelationMessage:
+					relations[msg.RelationID] = msg
+				case *pglogrepl.BeginMessage:
+					tx = &txState{}
+				case *pglogrepl.InsertMessage:
+					rel, ok := relations[msg.RelationID]
+					if !ok {
+						return fmt.Errorf("unknown relation id %d", msg.RelationID)
+					}
+					e, err := decodeRow(typeMap, rel, msg.Tuple)
+					if err != nil {
+						return err
+					}
+					if tx == nil {
+						tx = &txState{}
+					}
+					tx.events = append(tx.events, e)
+				case *pglogrepl.CommitMessage:
+					if tx != nil && len(tx.events) > 0 {
+						if err := r.publish(ctx, tx.events); err != nil {
+							return err
+						}
+					}
+					tx = nil
+					confirmed = msg.TransactionEndLSN
+					nextStatus = time.Time{}
+				}
+			}
+		default:
+			r.Log.Debug().Str("type", fmt.Sprintf("%T", raw)).Msg("outbox cdc: unexpected message")
+		}
+	}
+}
+
+func (r *Relay) active() bool {
+	return r.Coord == nil || r.Coord.Assignment().Active
+}
+
+// publish sends one transaction's events in order and marks them sent. A
+// failed event fails the transaction, so the stream restarts and replays
+// it, until the event reaches MaxAttempts: then it is dead-lettered and the
+// rest of the transaction goes on without it.
+func (r *Relay) publish(ctx context.Context, events []outbox.EventRow) error {
+	ids := make([]string, 0, len(events))
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+
+	type state struct {
+		done      bool
+		attempts  int
+		delivered []string
+	}
+	stored := map[string]state{}
+	rows, err := r.DB.Query(ctx, `
+		select id::text, sent_at is not null or dead_at is not null, attempts, delivered_sinks
+		from outbox_events where id = any($1::uuid[])
+	`, ids)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id string
+		var st state
+		if err := rows.Scan(&id, &st.done, &st.attempts, &st.delivered); err != nil {
+			rows.Close()
+			return err
+		}
+		stored[id] = st
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	maxAttempts := r.Runner.Config().MaxAttempts
+	sent := make([]string, 0, len(events))
+	for _, e := range events {
+		st := stored[e.ID]
+		if st.done {
+			r.Log.Debug().Str("id", e.ID).Msg("outbox cdc: already sent or dead, skipping")
+			continue
+		}
+		if st.attempts >= maxAttempts {
+			if err := r.dead(ctx, e, st.attempts, "max attempts reached"); err != nil {
+				return err
+			}
+			continue
+		}
+		e.Attempts, e.Delivered = st.attempts, st.delivered
+		if err := outbox.Deliver(ctx, r.Sink, e); err != nil {
+			if st.attempts+1 >= maxAttempts {
+				if err := r.dead(ctx, e, st.attempts+1, err.Error()); err != nil {
+					return err
+				}
+				continue
+			}
+			_, _ = r.DB.Exec(ctx, `
+				update outbox_events set attempts = attempts + 1, last_error = $2, delivered_sinks = $3 where id = $1
+			`, e.ID, err.Error(), outbox.Delivered(e, err))
+			return fmt.Errorf("publish %s (%s): %w", e.ID, e.EventType, err)
+		}
+		sent = append(sent, e.ID)
+	}
+
+	_, err = r.DB.Exec(ctx, `update outbox_events set sent_at = now(), last_error = null where id = any($1::uuid[]) and sent_at is null`, sent)
+	return err
+}
+
+// dead dead-letters a row the stream gives up on.
+func (r *Relay) dead(ctx context.Context, e outbox.EventRow, attempts int, reason string) error {
+	if _, err := r.DB.Exec(ctx, `
+		update outbox_events set attempts = $2, last_error = $3, dead_at = now() where id = $1 and dead_at is null
+	`, e.ID, attempts, reason); err != nil {
+		return err
+	}
+	metrics.OutboxEventsTotal.WithLabelValues(e.EventType, metrics.OutcomeDead).Inc()
+	r.Log.Warn().Str("id", e.ID).Str("type", e.EventType).Str("correlation_id", e.Headers.CorrelationID).Int("attempts", attempts).Msg("outbox cdc: dead (max attempts)")
+	return nil
+}
+
+func (r *Relay) ensureSlot(ctx context.Context, conn *pgconn.PgConn) error {
+	var exists bool
+	if err := r.DB.QueryRow(ctx, `select exists(select 1 from pg_replication_slots where slot_name = $1)`, r.Cfg.Slot).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	if _, err := pglogrepl.CreateReplicationSlot(ctx, conn, r.Cfg.Slot, "pgoutput", pglogrepl.CreateReplicationSlotOptions{}); err != nil {
+		var pgErr *pgconn.PgError
+		// another replica created it between our check and create
+		if errors.As(err, &pgErr) && pgErr.Code == "42710" {
+			return nil
+		}
+		return fmt.Errorf("create replication slot: %w", err)
+	}
+	r.Log.Info().Str("slot", r.Cfg.Slot).Msg("outbox cdc slot created")
+	return nil
+}
+
+func decodeRow(m *pgtype.Map, rel *pglogrepl.RelationMessage, tuple *pglogrepl.TupleData) (outbox.EventRow, error) {
+	var e outbox.EventRow
+	if tuple == nil {
+		return e, fmt.Errorf("insert without tuple")
+	}
+	for i, col := range tuple.Columns {
+		if i >= len(rel.Columns) || col.DataType != pglogrepl.TupleDataTypeText {
+			continue
+		}
+		data := col.Data
+		var err error
+		switch rel.Columns[i].Name {
+		case "id":
+			e.ID = string(data)
+		case "event_type":
+			e.EventType = string(data)
+		case "payload":
+			e.Payload = append([]byte(nil), data...)
+		case "headers":
+			err = json.Unmarshal(data, &e.Headers)
+		case "attempts":
+			err = m.Scan(rel.Columns[i].DataType, pgtype.TextFormatCode, data, &e.Attempts)
+		case "created_at":
+			err = m.Scan(rel.Columns[i].DataType, pgtype.TextFormatCode, data, &e.CreatedAt)
+		}
+		if err != nil {
+			return e, fmt.Errorf("decode column %s: %w", rel.Columns[i].Name, err)
+		}
+	}
+	if e.ID == "" || e.EventType == "" {
+		return e, fmt.Errorf("outbox insert without id/event_type")
+	}
+	return e, nil
+}
+
+func replicationDSN(dsn string) string {
+	if strings.Contains(dsn, "://") {
+		if strings.Contains(dsn, "?") {
+			return dsn + "&replication=database"
+		}
+		return dsn + "?replication=database"
+	}
+	return dsn + " replication=database"
+}
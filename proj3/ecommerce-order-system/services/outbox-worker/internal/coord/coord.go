@@ -0,0 +1,268 @@
+package coord
+
+import (
+	"context"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+
+	"ecommerce-order-system/services/outbox-worker/internal/metrics"
+	"ecommerce-order-system/shared/pkg/config"
+)
+
+// Assignment tells the runner whether to poll and which order_id hash shard it owns.
+// Shards == 0 means no sharding: the replica takes any row.
+type Assignment struct {
+	Active bool
+	Shard  int
+	Shards int
+}
+
+type Status struct {
+	Mode      string    `json:"mode"`
+	ReplicaID string    `json:"replica_id"`
+	Role      string    `json:"role"`
+	Active    bool      `json:"active"`
+	Shard     int       `json:"shard"`
+	Shards    int       `json:"shards"`
+	Members   []string  `json:"members"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Coordinator decides which outbox-worker replicas poll.
+//
+//   - none:   every replica polls everything (skip locked keeps rows exclusive).
+//   - leader: the replica holding a Postgres advisory lock polls, others stand by.
+//   - shard:  live replicas (heartbeats in outbox_workers) are sorted by ID and each
+//     polls rows where hash(order_id) mod N equals its index. A replica whose
+//     heartbeat lapses past MemberTTL drops out and the rest rebalance.
+type Coordinator struct {
+	Log zerolog.Logger
+	DB  *pgxpool.Pool
+	Cfg config.OutboxCoordConfig
+
+	mu     sync.RWMutex
+	status Status
+
+	lock *pgxpool.Conn // session holding the advisory lock while leader
+}
+
+func New(log zerolog.Logger, db *pgxpool.Pool, cfg config.OutboxCoordConfig) *Coordinator {
+	if cfg.ReplicaID == "" {
+		if h, err := os.Hostname(); err == nil && h != "" {
+			cfg.ReplicaID = h
+		} else {
+			cfg.ReplicaID = uuid.NewString()
+		}
+	}
+	c := &Coordinator{Log: log, DB: db, Cfg: cfg}
+	c.status = Status{
+		Mode:      cfg.Mode,
+		ReplicaID: cfg.ReplicaID,
+		Role:      "starting",
+		Active:    cfg.Mode == config.CoordNone,
+	}
+	return c
+}
+
+func (c *Coordinator) Assignment() Assignment {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return Assignment{Active: c.status.Active, Shard: c.status.Shard, Shards: c.status.Shards}
+}
+
+func (c *Coordinator) Status() Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	s := c.status
+	s.Members = slices.Clone(s.Members)
+	return s
+}
+
+func (c *Coordinator) Run(ctx context.Context) {
+	t := time.NewTicker(c.Cfg.HeartbeatInterval)
+	defer t.Stop()
+
+	c.step(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			c.stop()
+			return
+		case <-t.C:
+			c.step(ctx)
+		}
+	}
+}
+
+func (c *Coordinator) step(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, c.Cfg.HeartbeatInterval)
+	defer cancel()
+
+	next := Status{Mode: c.Cfg.Mode, ReplicaID: c.Cfg.ReplicaID, UpdatedAt: time.Now()}
+
+	members, err := c.liveMembers(ctx)
+	if err != nil {
+		c.Log.Error().Err(err).Msg("coordination: list members failed")
+	}
+	if !slices.Contains(members, c.Cfg.ReplicaID) {
+		members = append(members, c.Cfg.ReplicaID)
+		slices.Sort(members)
+	}
+	next.Members = members
+
+	switch c.Cfg.Mode {
+	case config.CoordLeader:
+		if c.holdLock(ctx) {
+			next.Role, next.Active = "leader", true
+		} else {
+			next.Role = "standby"
+		}
+	case config.CoordShard:
+		if err == nil {
+			next.Role, next.Active = "shard", true
+			next.Shard, next.Shards = slices.Index(members, c.Cfg.ReplicaID), len(members)
+		} else {
+			// without a view of the membership another replica may take our shard
+			next.Role = "isolated"
+		}
+	default:
+		next.Role, next.Active = "active", true
+	}
+
+	if err := c.heartbeat(ctx, next); err != nil {
+		c.Log.Error().Err(err).Msg("coordination: heartbeat failed")
+		if c.Cfg.Mode == config.CoordShard {
+			next.Role, next.Active = "isolated", false
+		}
+	}
+
+	c.publish(next)
+}
+
+func (c *Coordinator) publish(next Status) {
+	c.mu.Lock()
+	prev := c.status
+	c.status = next
+	c.mu.Unlock()
+
+	if prev.Active != next.Active || prev.Shard != next.Shard || prev.Shards != next.Shards {
+		metrics.OutboxRebalancesTotal.Inc()
+		c.Log.Info().
+			Str("role", next.Role).
+			Bool("active", next.Active).
+			Int("shard", next.Shard).
+			Int("shards", next.Shards).
+			Strs("members", next.Members).
+			Msg("outbox assignment changed")
+	}
+
+	active := 0.0
+	if next.Active {
+		active = 1
+	}
+	metrics.OutboxActive.Set(active)
+	metrics.OutboxShardIndex.Set(float64(next.Shard))
+	metrics.OutboxShardCount.Set(float64(next.Shards))
+	metrics.OutboxMembers.Set(float64(len(next.Members)))
+}
+
+func (c *Coordinator) liveMembers(ctx context.Context) ([]string, error) {
+	ttl := c.Cfg.MemberTTL.Seconds()
+	if _, err := c.DB.Exec(ctx, `
+		delete from outbox_workers
+		where heartbeat_at < now() - make_interval(secs => $1)
+	`, ttl); err != nil {
+		return nil, err
+	}
+
+	rows, err := c.DB.Query(ctx, `
+		select replica_id from outbox_workers
+		where mode = $1 and heartbeat_at >= now() - make_interval(secs => $2)
+		order by replica_id
+	`, c.Cfg.Mode, ttl)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+func (c *Coordinator) heartbeat(ctx context.Context, s Status) error {
+	_, err := c.DB.Exec(ctx, `
+		insert into outbox_workers(replica_id, mode, role, shard, shards)
+		values ($1, $2, $3, $4, $5)
+		on conflict (replica_id) do update
+		set mode = excluded.mode,
+		    role = excluded.role,
+		    shard = excluded.shard,
+		    shards = excluded.shards,
+		    heartbeat_at = now()
+	`, s.ReplicaID, s.Mode, s.Role, s.Shard, s.Shards)
+	return err
+}
+
+// holdLock keeps (or tries to take) the advisory lock on a dedicated session.
+func (c *Coordinator) holdLock(ctx context.Context) bool {
+	if c.lock != nil {
+		if _, err := c.lock.Exec(ctx, `select 1`); err == nil {
+			return true
+		}
+		c.Log.Warn().Msg("coordination: lost leader session")
+		c.dropLock()
+	}
+
+	conn, err := c.DB.Acquire(ctx)
+	if err != nil {
+		c.Log.Error().Err(err).Msg("coordination: acquire conn failed")
+		return false
+	}
+	var ok bool
+	if err := conn.QueryRow(ctx, `select pg_try_advisory_lock($1)`, c.Cfg.LeaderLockKey).Scan(&ok); err != nil || !ok {
+		conn.Release()
+		return false
+	}
+	c.lock = conn
+	return true
+}
+
+// dropLock closes the leader session so the server frees the lock even if
+// the connection is half-broken, instead of returning a locked session to the pool.
+func (c *Coordinator) dropLock() {
+	if c.lock == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_ = c.lock.Conn().Close(ctx)
+	c.lock.Release()
+	c.lock = nil
+}
+
+func (c *Coordinator) stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if c.lock != nil {
+		_, _ = c.lock.Exec(ctx, `select pg_advisory_unlock($1)`, c.Cfg.LeaderLockKey)
+		c.dropLock()
+	}
+	// leave immediately so the others rebalance without waiting for the TTL
+	_, _ = c.DB.Exec(ctx, `delete from outbox_workers where replica_id = $1`, c.Cfg.ReplicaID)
+	c.publish(Status{Mode: c.Cfg.Mode, ReplicaID: c.Cfg.ReplicaID, Role: "stopped", UpdatedAt: time.Now()})
+	c.Log.Info().Msg("coordination stopped")
+}
@@ -0,0 +1,98 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"ecommerce-order-system/shared/pkg/config"
+)
+
+// outboxConfigView is the wire form of config.OutboxConfig; durations are Go duration strings.
+type outboxConfigView struct {
+	PollInterval string `json:"poll_interval"`
+	BatchSize    int    `json:"batch_size"`
+	MaxAttempts  int    `json:"max_attempts"`
+	RetryPolicy  string `json:"retry_policy"`
+	BackoffBase  string `json:"backoff_base"`
+	BackoffMax   string `json:"backoff_max"`
+}
+
+// outboxConfigPatch holds the fields a PUT may change; omitted fields keep their current value.
+type outboxConfigPatch struct {
+	PollInterval *string `json:"poll_interval"`
+	BatchSize    *int    `json:"batch_size"`
+	MaxAttempts  *int    `json:"max_attempts"`
+	RetryPolicy  *string `json:"retry_policy"`
+	BackoffBase  *string `json:"backoff_base"`
+	BackoffMax   *string `json:"backoff_max"`
+}
+
+func viewOf(c config.OutboxConfig) outboxConfigView {
+	return outboxConfigView{
+		PollInterval: c.PollInterval.String(),
+		BatchSize:    c.BatchSize,
+		MaxAttempts:  c.MaxAttempts,
+		RetryPolicy:  c.RetryPolicy,
+		BackoffBase:  c.BackoffBase.String(),
+		BackoffMax:   c.BackoffMax.String(),
+	}
+}
+
+func (p outboxConfigPatch) applyTo(c config.OutboxConfig) (config.OutboxConfig, error) {
+	for _, d := range []struct {
+		src *string
+		dst *time.Duration
+	}{
+		{p.PollInterval, &c.PollInterval},
+		{p.BackoffBase, &c.BackoffBase},
+		{p.BackoffMax, &c.BackoffMax},
+	} {
+		if d.src == nil {
+			continue
+		}
+		v, err := time.ParseDuration(*d.src)
+		if err != nil {
+			return c, err
+		}
+		*d.dst = v
+	}
+	if p.BatchSize != nil {
+		c.BatchSize = *p.BatchSize
+	}
+	if p.MaxAttempts != nil {
+		c.MaxAttempts = *p.MaxAttempts
+	}
+	if p.RetryPolicy != nil {
+		c.RetryPolicy = *p.RetryPolicy
+	}
+	return c, nil
+}
+
+func (s *Server) outboxConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPatch:
+		var patch outboxConfigPatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			http.Error(w, "bad json", http.StatusBadRequest)
+			return
+		}
+		next, err := patch.applyTo(s.Runner.Config())
+		if err != nil {
+			http.Error(w, "invalid config: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.Runner.Apply(next); err != nil {
+			http.Error(w, "invalid config: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT, PATCH")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(viewOf(s.Runner.Config()))
+}
@@ -6,14 +6,20 @@ import (
 	"net/http"
 	"time"
 
+	"ecommerce-order-system/services/outbox-worker/internal/coord"
 	"ecommerce-order-system/services/outbox-worker/internal/metrics"
+	"ecommerce-order-system/services/outbox-worker/internal/outbox"
+	"ecommerce-order-system/shared/pkg/httpauth"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type Server struct {
-	DB *pgxpool.Pool
+	DB         *pgxpool.Pool
+	Runner     *outbox.Runner
+	Coord      *coord.Coordinator
+	AdminToken string
 }
 
 func (s *Server) Handler() http.Handler {
@@ -25,19 +31,28 @@ func (s *Server) Handler() http.Handler {
 		_, _ = w.Write([]byte("ok"))
 	})
 
+	mux.HandleFunc("/health/coordination", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.Coord.Status())
+	})
+
 	mux.HandleFunc("/outbox/pending", func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
 		defer cancel()
 
-		var n int
-		if err := s.DB.QueryRow(ctx, `select count(*) from outbox_events where sent_at is null`).Scan(&n); err != nil {
+		st, err := outbox.QueryStats(ctx, s.DB)
+		if err != nil {
 			http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-		metrics.OutboxPending.Set(float64(n))
+		metrics.OutboxPending.Set(float64(st.Pending))
+		metrics.OutboxOldestPendingAgeSeconds.Set(st.OldestAgeSeconds)
+		metrics.OutboxDead.Set(float64(st.Dead))
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]int{"pending": n})
+		_ = json.NewEncoder(w).Encode(st)
 	})
 
+	mux.Handle("/admin/outbox/config", httpauth.Single(s.AdminToken)(http.HandlerFunc(s.outboxConfig)))
+
 	return mux
 }
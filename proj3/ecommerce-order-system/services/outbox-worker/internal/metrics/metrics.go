@@ -2,21 +2,99 @@ package metrics
 
 import "github.com/prometheus/client_golang/prometheus"
 
+const (
+	OutcomeSent  = "sent"
+	OutcomeError = "error"
+	OutcomeDead  = "dead"
+)
+
 var (
-	OutboxSentTotal = prometheus.NewCounter(prometheus.CounterOpts{
-		Name: "outbox_sent_total",
-		Help: "Total outbox events successfully published",
-	})
-	OutboxPublishErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
-		Name: "outbox_publish_errors_total",
-		Help: "Total outbox publish errors",
+	OutboxEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_events_total",
+		Help: "Total outbox events handled, by event type and outcome (sent, error, dead)",
+	}, []string{"event_type", "outcome"})
+	OutboxEnqueueToPublishSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "outbox_enqueue_to_publish_seconds",
+		Help:    "Time from outbox insert (created_at) to successful publish",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600},
+	}, []string{"event_type"})
+	OutboxPublishDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "outbox_publish_duration_seconds",
+		Help:    "Duration of a single broker publish call",
+		Buckets: []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+	}, []string{"event_type", "outcome"})
+	OutboxSinkSendsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_sink_sends_total",
+		Help: "Sends per outbox sink, by outcome (sent, error)",
+	}, []string{"sink", "outcome"})
+	OutboxBatchSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "outbox_batch_size",
+		Help:    "Number of outbox rows picked up per tick",
+		Buckets: []float64{0, 1, 2, 5, 10, 20, 50, 100, 200, 500},
 	})
 	OutboxPending = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "outbox_pending",
-		Help: "Number of pending outbox events",
+		Help: "Estimated number of pending outbox events",
+	})
+	OutboxOldestPendingAgeSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "outbox_oldest_pending_age_seconds",
+		Help: "Age of the oldest pending outbox event (0 when nothing is pending)",
+	})
+	OutboxDead = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "outbox_dead",
+		Help: "Number of outbox events that exhausted their attempts",
+	})
+	OutboxActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "outbox_active",
+		Help: "1 if this replica is currently polling the outbox (leader or shard owner)",
+	})
+	OutboxShardIndex = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "outbox_shard_index",
+		Help: "Shard owned by this replica in shard mode",
+	})
+	OutboxShardCount = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "outbox_shard_count",
+		Help: "Number of shards (live replicas) in shard mode, 0 when not sharding",
+	})
+	OutboxMembers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "outbox_members",
+		Help: "Live outbox-worker replicas seen via heartbeats",
+	})
+	OutboxRebalancesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "outbox_rebalances_total",
+		Help: "Times this replica's leadership or shard assignment changed",
+	})
+	OutboxCDCConfirmedLSN = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "outbox_cdc_confirmed_lsn",
+		Help: "Last WAL position acknowledged to the replication slot",
+	})
+	OutboxCDCLagBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "outbox_cdc_lag_bytes",
+		Help: "Server WAL end minus the confirmed position",
+	})
+	OutboxCDCRestartsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "outbox_cdc_restarts_total",
+		Help: "Replication stream restarts after an error",
 	})
 )
 
 func init() {
-	prometheus.MustRegister(OutboxSentTotal, OutboxPublishErrorsTotal, OutboxPending)
+	prometheus.MustRegister(
+		OutboxEventsTotal,
+		OutboxEnqueueToPublishSeconds,
+		OutboxPublishDurationSeconds,
+		OutboxSinkSendsTotal,
+		OutboxBatchSize,
+		OutboxPending,
+		OutboxOldestPendingAgeSeconds,
+		OutboxDead,
+		OutboxActive,
+		OutboxShardIndex,
+		OutboxShardCount,
+		OutboxMembers,
+		OutboxRebalancesTotal,
+		OutboxCDCConfirmedLSN,
+		OutboxCDCLagBytes,
+		OutboxCDCRestartsTotal,
+	)
 }
@@ -0,0 +1,68 @@
+package outbox
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"ecommerce-order-system/shared/pkg/config"
+)
+
+// RetryPolicy decides how long to wait before the given (1-based) attempt.
+type RetryPolicy interface {
+	Delay(attempt int) time.Duration
+}
+
+// ExponentialJitter is exponential backoff with full jitter: the delay is drawn
+// uniformly from [0, min(Max, Base*2^attempt)] so failed rows do not retry in lockstep.
+type ExponentialJitter struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+func (p ExponentialJitter) Delay(attempt int) time.Duration {
+	ceil := float64(p.Base) * math.Pow(2, float64(attempt))
+	if ceil > float64(p.Max) || math.IsInf(ceil, 1) {
+		ceil = float64(p.Max)
+	}
+	if ceil <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceil) + 1))
+}
+
+// Fixed waits the same Interval before every attempt.
+type Fixed struct {
+	Interval time.Duration
+}
+
+func (p Fixed) Delay(int) time.Duration { return p.Interval }
+
+// Linear waits Step*attempt, capped at Max.
+type Linear struct {
+	Step time.Duration
+	Max  time.Duration
+}
+
+func (p Linear) Delay(attempt int) time.Duration {
+	d := p.Step * time.Duration(attempt)
+	if d > p.Max || d < 0 {
+		return p.Max
+	}
+	return d
+}
+
+// NewRetryPolicy builds the policy named by cfg.RetryPolicy.
+func NewRetryPolicy(cfg config.OutboxConfig) (RetryPolicy, error) {
+	switch cfg.RetryPolicy {
+	case config.RetryExponential:
+		return ExponentialJitter{Base: cfg.BackoffBase, Max: cfg.BackoffMax}, nil
+	case config.RetryFixed:
+		return Fixed{Interval: cfg.BackoffBase}, nil
+	case config.RetryLinear:
+		return Linear{Step: cfg.BackoffBase, Max: cfg.BackoffMax}, nil
+	default:
+		return nil, fmt.Errorf("unknown retry policy %q", cfg.RetryPolicy)
+	}
+}
@@ -2,27 +2,70 @@ package outbox
 
 import (
 	"context"
-	"math"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
-	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/rs/zerolog"
 
+	"ecommerce-order-system/services/outbox-worker/internal/coord"
 	"ecommerce-order-system/services/outbox-worker/internal/metrics"
-	"ecommerce-order-system/shared/pkg/rabbit"
+	"ecommerce-order-system/services/outbox-worker/internal/sink"
+	"ecommerce-order-system/shared/pkg/config"
+	"ecommerce-order-system/shared/pkg/models"
 )
 
 type Runner struct {
 	Log zerolog.Logger
 	DB  *pgxpool.Pool
 
-	EventsPub *rabbit.Publisher
+	Sink sink.Sink
+	// Coord, when set, gates polling and restricts it to this replica's shard.
+	Coord *coord.Coordinator
 
-	PollInterval time.Duration
-	BatchSize    int
-	MaxAttempts  int
-	BackoffMax   time.Duration
+	mu     sync.RWMutex
+	cfg    config.OutboxConfig
+	policy RetryPolicy
+}
+
+func NewRunner(log zerolog.Logger, db *pgxpool.Pool, s sink.Sink, cfg config.OutboxConfig) (*Runner, error) {
+	r := &Runner{Log: log, DB: db, Sink: s}
+	if err := r.Apply(cfg); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Config returns the settings currently in effect.
+func (r *Runner) Config() config.OutboxConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cfg
+}
+
+// Apply validates cfg and swaps it in; the next tick picks it up.
+func (r *Runner) Apply(cfg config.OutboxConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	policy, err := NewRetryPolicy(cfg)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.cfg = cfg
+	r.policy = policy
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Runner) settings() (config.OutboxConfig, RetryPolicy) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cfg, r.policy
 }
 
 type EventRow struct {
@@ -30,10 +73,15 @@ type EventRow struct {
 	EventType string
 	Payload   []byte
 	Attempts  int
+	CreatedAt time.Time
+	Headers   models.OutboxHeaders
+	// Delivered names the sinks that took the row on an earlier try.
+	Delivered []string
 }
 
 func (r *Runner) Run(ctx context.Context) {
-	t := time.NewTicker(r.PollInterval)
+	interval := r.Config().PollInterval
+	t := time.NewTicker(interval)
 	defer t.Stop()
 
 	for {
@@ -45,107 +93,200 @@ func (r *Runner) Run(ctx context.Context) {
 			if err := r.tick(ctx); err != nil {
 				r.Log.Error().Err(err).Msg("outbox tick failed")
 			}
+			if next := r.Config().PollInterval; next != interval {
+				interval = next
+				t.Reset(interval)
+				r.Log.Info().Dur("poll_interval", interval).Msg("outbox poll interval changed")
+			}
 		}
 	}
 }
 
 func (r *Runner) tick(ctx context.Context) error {
-	// update pending gauge
-	_ = r.updatePending(ctx)
+	cfg, policy := r.settings()
+
+	a := coord.Assignment{Active: true}
+	if r.Coord != nil {
+		a = r.Coord.Assignment()
+	}
+
+	// update backlog gauges
+	_ = r.updateStats(ctx)
+
+	if !a.Active {
+		return nil
+	}
+	_, _, err := r.publishBatch(ctx, cfg, policy, a, false, nil)
+	return err
+}
+
+// Drain publishes every pending row once, oldest first, without waiting for
+// retry backoff. The CDC relay calls it before streaming, so rows written
+// while nothing streamed (or before CDC was turned on) are not left behind.
+// Rows that fail keep their attempt count, or are dead-lettered at
+// MaxAttempts, and make Drain fail so it is run again.
+func (r *Runner) Drain(ctx context.Context) error {
+	cfg, policy := r.settings()
+	var failed []string
+	for {
+		picked, f, err := r.publishBatch(ctx, cfg, policy, coord.Assignment{Active: true}, true, failed)
+		if err != nil {
+			return err
+		}
+		failed = append(failed, f...)
+		if picked == 0 {
+			break
+		}
+	}
+	_ = r.updateStats(ctx)
+	if len(failed) > 0 {
+		return fmt.Errorf("outbox drain: %d rows failed", len(failed))
+	}
+	return nil
+}
 
+// publishBatch sends up to cfg.BatchSize pending rows of a's shard in one
+// transaction. Unless anyTime is set only rows whose retry is due are
+// picked; rows in skip are left out. It reports how many rows were picked
+// and the ids of those that failed.
+func (r *Runner) publishBatch(ctx context.Context, cfg config.OutboxConfig, policy RetryPolicy, a coord.Assignment, anyTime bool, skip []string) (int, []string, error) {
 	tx, err := r.DB.Begin(ctx)
 	if err != nil {
-		return err
+		return 0, nil, err
 	}
 	defer func() { _ = tx.Rollback(ctx) }()
 
 	rows, err := tx.Query(ctx, `
-		select id, event_type, payload::text, attempts
+		select id, event_type, payload::text, headers::text, attempts, created_at, delivered_sinks
 		from outbox_events
-		where sent_at is null and next_attempt_at <= now()
+		where sent_at is null and dead_at is null and ($4::bool or next_attempt_at <= now())
+		  and not (id = any(coalesce($5::uuid[], '{}')))
+		  and ($2::int = 0 or mod(abs(hashtext(order_id::text)::bigint), $2::int) = $3::int)
 		order by created_at
 		limit $1
 		for update skip locked
-	`, r.BatchSize)
+	`, cfg.BatchSize, a.Shards, a.Shard, anyTime, skip)
 	if err != nil {
-		return err
+		return 0, nil, err
 	}
 	defer rows.Close()
 
 	var batch []EventRow
 	for rows.Next() {
 		var e EventRow
-		var payloadText string
-		if err := rows.Scan(&e.ID, &e.EventType, &payloadText, &e.Attempts); err != nil {
-			return err
+		var payloadText, headersText string
+		if err := rows.Scan(&e.ID, &e.EventType, &payloadText, &headersText, &e.Attempts, &e.CreatedAt, &e.Delivered); err != nil {
+			return 0, nil, err
 		}
 		e.Payload = []byte(payloadText)
+		if err := json.Unmarshal([]byte(headersText), &e.Headers); err != nil {
+			r.Log.Warn().Err(err).Str("id", e.ID).Msg("bad outbox headers, using defaults")
+			e.Headers = models.OutboxHeaders{}
+		}
 		batch = append(batch, e)
 	}
 	if err := rows.Err(); err != nil {
-		return err
+		return 0, nil, err
 	}
+	metrics.OutboxBatchSize.Observe(float64(len(batch)))
 
+	var failed []string
 	for _, e := range batch {
-		if e.Attempts >= r.MaxAttempts {
-			_, _ = tx.Exec(ctx, `update outbox_events set last_error=$2, sent_at=now() where id=$1`, e.ID, "max attempts reached")
-			r.Log.Warn().Str("id", e.ID).Int("attempts", e.Attempts).Msg("outbox drop (max attempts), marked sent")
+		if e.Attempts >= cfg.MaxAttempts {
+			_, _ = tx.Exec(ctx, `update outbox_events set last_error=$2, dead_at=now() where id=$1`, e.ID, "max attempts reached")
+			metrics.OutboxEventsTotal.WithLabelValues(e.EventType, metrics.OutcomeDead).Inc()
+			r.Log.Warn().Str("id", e.ID).Str("type", e.EventType).Str("correlation_id", e.Headers.CorrelationID).Int("attempts", e.Attempts).Msg("outbox dead (max attempts)")
 			continue
 		}
 
-		pubCtx, cancel := rabbit.WithTimeout(ctx)
-		err := r.EventsPub.Publish(pubCtx, e.EventType, e.Payload, amqp.Table{
-			"x-outbox-id": e.ID,
-			"x-attempts":  int32(e.Attempts),
-		})
-		cancel()
-
+		err := Deliver(ctx, r.Sink, e)
 		if err == nil {
-			metrics.OutboxSentTotal.Inc()
 			_, err2 := tx.Exec(ctx, `update outbox_events set sent_at=now(), last_error=null where id=$1`, e.ID)
 			if err2 != nil {
-				return err2
+				return 0, nil, err2
 			}
 			continue
 		}
 
-		metrics.OutboxPublishErrorsTotal.Inc()
-		next := time.Now().Add(backoff(e.Attempts+1, r.BackoffMax))
+		failed = append(failed, e.ID)
+		next := time.Now().Add(policy.Delay(e.Attempts + 1))
 		_, err2 := tx.Exec(ctx, `
 			update outbox_events
 			set attempts = attempts + 1,
 			    next_attempt_at = $2,
-			    last_error = $3
+			    last_error = $3,
+			    delivered_sinks = $4
 			where id = $1
-		`, e.ID, next, err.Error())
+		`, e.ID, next, err.Error(), Delivered(e, err))
 		if err2 != nil {
-			return err2
+			return 0, nil, err2
 		}
-		r.Log.Error().Err(err).Str("id", e.ID).Str("type", e.EventType).Int("attempts", e.Attempts+1).Time("next", next).Msg("publish failed -> retry scheduled")
+		r.Log.Error().Err(err).Str("id", e.ID).Str("type", e.EventType).Str("correlation_id", e.Headers.CorrelationID).Int("attempts", e.Attempts+1).Time("next", next).Msg("publish failed -> retry scheduled")
 	}
 
-	return tx.Commit(ctx)
+	return len(batch), failed, tx.Commit(ctx)
 }
 
-func (r *Runner) updatePending(ctx context.Context) error {
-	ctx2, cancel := context.WithTimeout(ctx, 2*time.Second)
-	defer cancel()
-	var n int
-	if err := r.DB.QueryRow(ctx2, `select count(*) from outbox_events where sent_at is null`).Scan(&n); err != nil {
-		return err
+// Deliver sends one row through s and records the publish metrics.
+func Deliver(ctx context.Context, s sink.Sink, e EventRow) error {
+	start := time.Now()
+	err := s.Send(ctx, sink.Message{
+		ID:        e.ID,
+		EventType: e.EventType,
+		Payload:   e.Payload,
+		Attempts:  e.Attempts,
+		CreatedAt: e.CreatedAt,
+		Headers:   e.Headers,
+		Delivered: e.Delivered,
+	})
+	outcome := metrics.OutcomeSent
+	if err != nil {
+		outcome = metrics.OutcomeError
 	}
-	metrics.OutboxPending.Set(float64(n))
-	return nil
+	metrics.OutboxPublishDurationSeconds.WithLabelValues(e.EventType, outcome).Observe(time.Since(start).Seconds())
+	metrics.OutboxEventsTotal.WithLabelValues(e.EventType, outcome).Inc()
+	if err == nil {
+		metrics.OutboxEnqueueToPublishSeconds.WithLabelValues(e.EventType).Observe(time.Since(e.CreatedAt).Seconds())
+	}
+	return err
+}
+
+// Delivered returns the sinks that have e after a failed Deliver: those a
+// partial fan-out reached, or the ones that had it before.
+func Delivered(e EventRow, err error) []string {
+	var pe *sink.PartialError
+	if errors.As(err, &pe) {
+		return pe.Delivered
+	}
+	if e.Delivered == nil {
+		return []string{}
+	}
+	return e.Delivered
 }
 
-func backoff(attempt int, max time.Duration) time.Duration {
-	sec := math.Pow(2, float64(attempt))
-	d := time.Duration(sec) * time.Second
-	if d > max {
-		return max
+// RunStats only refreshes the backlog gauges; used when another relay publishes.
+func (r *Runner) RunStats(ctx context.Context) {
+	t := time.NewTicker(r.Config().PollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			_ = r.updateStats(ctx)
+		}
 	}
-	if d < time.Second {
-		return time.Second
+}
+
+func (r *Runner) updateStats(ctx context.Context) error {
+	ctx2, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	s, err := QueryStats(ctx2, r.DB)
+	if err != nil {
+		return err
 	}
-	return d
+	metrics.OutboxPending.Set(float64(s.Pending))
+	metrics.OutboxOldestPendingAgeSeconds.Set(s.OldestAgeSeconds)
+	metrics.OutboxDead.Set(float64(s.Dead))
+	return nil
 }
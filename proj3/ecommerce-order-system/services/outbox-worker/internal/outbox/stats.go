@@ -0,0 +1,79 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pendingExactLimit bounds the exact pending count. Above it the planner's row
+// estimate is used so a large backlog does not turn every tick into a long scan.
+const pendingExactLimit = 10000
+
+type Stats struct {
+	Pending          int     `json:"pending"`
+	PendingEstimated bool    `json:"pending_estimated"`
+	OldestAgeSeconds float64 `json:"oldest_pending_age_seconds"`
+	Dead             int     `json:"dead"`
+}
+
+// QueryStats reads backlog figures using only the partial indexes on outbox_events.
+func QueryStats(ctx context.Context, db *pgxpool.Pool) (Stats, error) {
+	var s Stats
+
+	if err := db.QueryRow(ctx, `
+		select count(*) from (
+			select 1 from outbox_events
+			where sent_at is null and dead_at is null
+			limit $1
+		) t
+	`, pendingExactLimit).Scan(&s.Pending); err != nil {
+		return Stats{}, err
+	}
+	if s.Pending >= pendingExactLimit {
+		est, err := plannerEstimate(ctx, db)
+		if err != nil {
+			return Stats{}, err
+		}
+		if est > s.Pending {
+			s.Pending = est
+		}
+		s.PendingEstimated = true
+	}
+
+	if err := db.QueryRow(ctx, `
+		select coalesce(extract(epoch from now() - min(created_at)), 0)::float8
+		from outbox_events
+		where sent_at is null and dead_at is null
+	`).Scan(&s.OldestAgeSeconds); err != nil {
+		return Stats{}, err
+	}
+
+	if err := db.QueryRow(ctx, `select count(*) from outbox_events where dead_at is not null`).Scan(&s.Dead); err != nil {
+		return Stats{}, err
+	}
+	return s, nil
+}
+
+func plannerEstimate(ctx context.Context, db *pgxpool.Pool) (int, error) {
+	var raw string
+	if err := db.QueryRow(ctx, `
+		explain (format json)
+		select 1 from outbox_events where sent_at is null and dead_at is null
+	`).Scan(&raw); err != nil {
+		return 0, err
+	}
+	var plan []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal([]byte(raw), &plan); err != nil {
+		return 0, err
+	}
+	if len(plan) == 0 {
+		return 0, nil
+	}
+	return int(plan[0].Plan.Rows), nil
+}
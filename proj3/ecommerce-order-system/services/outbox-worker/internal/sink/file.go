@@ -0,0 +1,68 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"ecommerce-order-system/shared/pkg/config"
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+// File appends every message as one NDJSON line. Intended for local
+// development and as an audit trail; each write is fsynced.
+type File struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+type fileRecord struct {
+	ID        string               `json:"id"`
+	EventType string               `json:"event_type"`
+	Attempts  int                  `json:"attempts"`
+	CreatedAt time.Time            `json:"created_at"`
+	SentAt    time.Time            `json:"sent_at"`
+	Headers   models.OutboxHeaders `json:"headers"`
+	Payload   json.RawMessage      `json:"payload"`
+}
+
+func NewFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &File{f: f}, nil
+}
+
+func (s *File) Name() string { return config.SinkFile }
+
+func (s *File) Send(_ context.Context, m Message) error {
+	b, err := json.Marshal(fileRecord{
+		ID:        m.ID,
+		EventType: m.EventType,
+		Attempts:  m.Attempts,
+		CreatedAt: m.CreatedAt,
+		SentAt:    time.Now(),
+		Headers:   m.Headers,
+		Payload:   json.RawMessage(m.Payload),
+	})
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.f.Write(b); err != nil {
+		return err
+	}
+	return s.f.Sync()
+}
+
+func (s *File) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
@@ -0,0 +1,43 @@
+package sink
+
+import (
+	"context"
+	"sync"
+
+	"ecommerce-order-system/shared/pkg/config"
+)
+
+// Memory keeps the last Limit sent messages in memory (all of them if Limit
+// is 0). Fail, when set, is returned from Send instead of recording the
+// message, which lets tests exercise the retry path.
+type Memory struct {
+	mu    sync.Mutex
+	msgs  []Message
+	Limit int
+	Fail  error
+}
+
+func (s *Memory) Name() string { return config.SinkMemory }
+
+func (s *Memory) Send(_ context.Context, m Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Fail != nil {
+		return s.Fail
+	}
+	m.Payload = append([]byte(nil), m.Payload...)
+	s.msgs = append(s.msgs, m)
+	if s.Limit > 0 && len(s.msgs) > s.Limit {
+		s.msgs = append(s.msgs[:0], s.msgs[len(s.msgs)-s.Limit:]...)
+	}
+	return nil
+}
+
+// Messages returns a copy of the messages kept so far.
+func (s *Memory) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.msgs...)
+}
+
+func (s *Memory) Close() error { return nil }
@@ -0,0 +1,74 @@
+package sink
+
+import (
+	"context"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"ecommerce-order-system/shared/pkg/config"
+	"ecommerce-order-system/shared/pkg/rabbit"
+)
+
+// Rabbit publishes to the events exchange with the event type as routing key,
+// mapping the row's headers onto AMQP properties. Rows enqueued before headers
+// existed fall back to the outbox ID, event type and created_at.
+type Rabbit struct {
+	Pub *rabbit.Publisher
+}
+
+func (s *Rabbit) Name() string { return config.SinkRabbit }
+
+func (s *Rabbit) Send(ctx context.Context, m Message) error {
+	pubCtx, cancel := rabbit.WithTimeout(ctx)
+	defer cancel()
+	return s.Pub.PublishMessage(pubCtx, m.EventType, publishing(m))
+}
+
+func publishing(m Message) amqp.Publishing {
+	h := m.Headers
+	msg := amqp.Publishing{
+		ContentType:   h.ContentType,
+		MessageId:     h.MessageID,
+		Type:          h.Type,
+		AppId:         h.Source,
+		CorrelationId: h.CorrelationID,
+		Timestamp:     h.CreatedAt,
+		DeliveryMode:  amqp.Persistent,
+		Body:          m.Payload,
+		Headers: amqp.Table{
+			"x-outbox-id": m.ID,
+			"x-attempts":  int32(m.Attempts),
+		},
+	}
+	if msg.MessageId == "" {
+		msg.MessageId = m.ID
+	}
+	if msg.Type == "" {
+		msg.Type = m.EventType
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = m.CreatedAt
+	}
+	if h.SchemaVersion > 0 {
+		msg.Headers["x-schema-version"] = int32(h.SchemaVersion)
+	}
+	if h.CausationID != "" {
+		msg.Headers["x-causation-id"] = h.CausationID
+	}
+	if h.CorrelationID != "" {
+		msg.Headers["x-correlation-id"] = h.CorrelationID
+	}
+	if h.TraceParent != "" {
+		msg.Headers["traceparent"] = h.TraceParent
+	}
+	if h.TraceState != "" {
+		msg.Headers["tracestate"] = h.TraceState
+	}
+	// only rows that carry headers can opt out of persistence
+	if !h.Persistent && h.MessageID != "" {
+		msg.DeliveryMode = amqp.Transient
+	}
+	return msg
+}
+
+func (s *Rabbit) Close() error { return nil }
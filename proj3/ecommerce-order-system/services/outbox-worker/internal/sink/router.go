@@ -0,0 +1,144 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"ecommerce-order-system/services/outbox-worker/internal/metrics"
+	"ecommerce-order-system/shared/pkg/config"
+	"ecommerce-order-system/shared/pkg/rabbit"
+)
+
+type route struct {
+	pattern string
+	sinks   []Sink
+}
+
+// Router fans a message out to every sink whose route pattern matches the
+// event type, or to the default sinks when nothing matches. Sinks named in
+// Message.Delivered are skipped. Send fails with a *PartialError if any
+// target fails, so the row is retried on the failed targets only.
+type Router struct {
+	routes   []route
+	defaults []Sink
+	all      []Sink
+}
+
+func (r *Router) Name() string { return "router" }
+
+// Targets returns the sinks an event type is delivered to.
+func (r *Router) Targets(eventType string) []Sink {
+	var out []Sink
+	seen := map[Sink]bool{}
+	for _, rt := range r.routes {
+		if ok, _ := path.Match(rt.pattern, eventType); !ok {
+			continue
+		}
+		for _, s := range rt.sinks {
+			if !seen[s] {
+				seen[s] = true
+				out = append(out, s)
+			}
+		}
+	}
+	if len(out) == 0 {
+		return r.defaults
+	}
+	return out
+}
+
+// PartialError reports a fan-out in which some targets failed. Delivered
+// names the sinks that have the message now, earlier tries included.
+type PartialError struct {
+	Delivered []string
+	Err       error
+}
+
+func (e *PartialError) Error() string { return e.Err.Error() }
+func (e *PartialError) Unwrap() error { return e.Err }
+
+func (r *Router) Send(ctx context.Context, m Message) error {
+	var errs []error
+	delivered := slices.Clone(m.Delivered)
+	for _, s := range r.Targets(m.EventType) {
+		if slices.Contains(m.Delivered, s.Name()) {
+			continue
+		}
+		if err := s.Send(ctx, m); err != nil {
+			metrics.OutboxSinkSendsTotal.WithLabelValues(s.Name(), metrics.OutcomeError).Inc()
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+			continue
+		}
+		metrics.OutboxSinkSendsTotal.WithLabelValues(s.Name(), metrics.OutcomeSent).Inc()
+		delivered = append(delivered, s.Name())
+	}
+	if len(errs) > 0 {
+		return &PartialError{Delivered: delivered, Err: errors.Join(errs...)}
+	}
+	return nil
+}
+
+func (r *Router) Close() error {
+	var errs []error
+	for _, s := range r.all {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// FromConfig builds the sinks referenced by cfg and the router over them.
+func FromConfig(cfg config.OutboxSinksConfig, pub *rabbit.Publisher) (*Router, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	r := &Router{}
+	byName := map[string]Sink{}
+	for _, name := range cfg.SinkNames() {
+		var s Sink
+		switch name {
+		case config.SinkRabbit:
+			s = &Rabbit{Pub: pub}
+		case config.SinkWebhook:
+			s = &Webhook{URL: cfg.WebhookURL, Secret: cfg.WebhookSecret, Client: &http.Client{Timeout: cfg.WebhookTimeout}}
+		case config.SinkFile:
+			f, err := NewFile(cfg.FilePath)
+			if err != nil {
+				_ = r.Close()
+				return nil, err
+			}
+			s = f
+		case config.SinkMemory:
+			s = &Memory{Limit: cfg.MemoryLimit}
+		}
+		byName[name] = s
+		r.all = append(r.all, s)
+	}
+
+	lookup := func(names []string) []Sink {
+		var out []Sink
+		for _, n := range names {
+			if s, ok := byName[strings.TrimSpace(n)]; ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+
+	r.defaults = lookup(cfg.Default)
+	patterns := make([]string, 0, len(cfg.Routes))
+	for p := range cfg.Routes {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+	for _, p := range patterns {
+		r.routes = append(r.routes, route{pattern: p, sinks: lookup(strings.Split(cfg.Routes[p], "+"))})
+	}
+	return r, nil
+}
@@ -0,0 +1,52 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// named wraps Memory under another name, so one router can hold several.
+type named struct {
+	Memory
+	name string
+}
+
+func (s *named) Name() string { return s.name }
+
+func TestRouterRetriesOnlyFailedSinks(t *testing.T) {
+	rabbit := &named{name: "rabbit"}
+	webhook := &named{name: "webhook", Memory: Memory{Fail: errors.New("503")}}
+	r := &Router{defaults: []Sink{rabbit, webhook}}
+	m := Message{ID: "e1", EventType: "order.cancelled"}
+
+	err := r.Send(context.Background(), m)
+	var pe *PartialError
+	if !errors.As(err, &pe) || !slices.Equal(pe.Delivered, []string{"rabbit"}) {
+		t.Fatalf("first Send = %v, want PartialError with rabbit delivered", err)
+	}
+
+	webhook.Fail = nil
+	m.Delivered = pe.Delivered
+	if err := r.Send(context.Background(), m); err != nil {
+		t.Fatalf("retry Send = %v", err)
+	}
+	if n := len(rabbit.Messages()); n != 1 {
+		t.Fatalf("rabbit got %d messages, want 1", n)
+	}
+	if n := len(webhook.Messages()); n != 1 {
+		t.Fatalf("webhook got %d messages, want 1", n)
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	s := &Memory{Limit: 2}
+	for _, id := range []string{"a", "b", "c"} {
+		_ = s.Send(context.Background(), Message{ID: id})
+	}
+	got := s.Messages()
+	if len(got) != 2 || got[0].ID != "b" || got[1].ID != "c" {
+		t.Fatalf("Messages = %+v, want b, c", got)
+	}
+}
@@ -0,0 +1,28 @@
+package sink
+
+import (
+	"context"
+	"time"
+
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+// Message is one outbox row on its way out.
+type Message struct {
+	ID        string
+	EventType string
+	Payload   []byte
+	Attempts  int
+	CreatedAt time.Time
+	Headers   models.OutboxHeaders
+	// Delivered names the sinks that took the message on an earlier try.
+	Delivered []string
+}
+
+// Sink delivers outbox messages somewhere. Send must be safe to retry: a
+// row is re-sent to a sink until it succeeds.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, m Message) error
+	Close() error
+}
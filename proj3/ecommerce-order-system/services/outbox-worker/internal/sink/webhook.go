@@ -0,0 +1,64 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"ecommerce-order-system/shared/pkg/config"
+)
+
+// Webhook POSTs the raw event payload to URL. When Secret is set the body is
+// signed with HMAC-SHA256 and sent as X-Outbox-Signature: sha256=<hex>.
+type Webhook struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+func (s *Webhook) Name() string { return config.SinkWebhook }
+
+func (s *Webhook) Send(ctx context.Context, m Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(m.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Outbox-Id", m.ID)
+	req.Header.Set("X-Event-Type", m.EventType)
+	req.Header.Set("X-Outbox-Attempts", strconv.Itoa(m.Attempts))
+	if m.Headers.CorrelationID != "" {
+		req.Header.Set("X-Correlation-Id", m.Headers.CorrelationID)
+	}
+	if m.Headers.TraceParent != "" {
+		req.Header.Set("traceparent", m.Headers.TraceParent)
+	}
+	if s.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(s.Secret))
+		mac.Write(m.Payload)
+		req.Header.Set("X-Outbox-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: unexpected status %d", s.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *Webhook) Close() error {
+	s.Client.CloseIdleConnections()
+	return nil
+}
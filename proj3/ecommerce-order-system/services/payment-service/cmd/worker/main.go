@@ -2,22 +2,46 @@ package main
 
 import (
 	"context"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	httpx "ecommerce-order-system/services/payment-service/internal/http"
+	"ecommerce-order-system/services/payment-service/internal/provider"
+	"ecommerce-order-system/services/payment-service/internal/repo"
 	"ecommerce-order-system/services/payment-service/internal/worker"
 	"ecommerce-order-system/shared/pkg/config"
+	"ecommerce-order-system/shared/pkg/httpauth"
 	"ecommerce-order-system/shared/pkg/logger"
+	"ecommerce-order-system/shared/pkg/models"
 	"ecommerce-order-system/shared/pkg/rabbit"
+
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// maxAttempts is how many times a failed message goes through the retry
+// queue before the DLQ; a provider call that failed is tried again this way.
+const maxAttempts = 5
+
 func main() {
 	cfg, err := config.Load()
 	if err != nil {
 		panic(err)
 	}
 	log := logger.New("payment-service", cfg.Common.LogLevel)
+	if cfg.Payment.ProviderMaxTries > maxAttempts {
+		log.Fatal().Int("max_tries", cfg.Payment.ProviderMaxTries).Int("max_attempts", maxAttempts).Msg("PAYMENT_PROVIDER_MAX_TRIES exceeds the message retry attempts")
+	}
+
+	ctxDB, cancelDB := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelDB()
+	db, err := pgxpool.New(ctxDB, cfg.Postgres.DSN)
+	if err != nil {
+		log.Fatal().Err(err).Msg("pg connect failed")
+	}
+	defer db.Close()
 
 	rc, err := rabbit.Connect(cfg.Rabbit.URL)
 	if err != nil {
@@ -29,16 +53,26 @@ func main() {
 		log.Fatal().Err(err).Msg("declare base failed")
 	}
 
+	// Refund and retry requests come from api-gateway in both modes.
+	keys, other := cfg.Saga.Bindings(
+		[]string{models.TypeFraudPassed, models.TypeShippingScheduled, models.TypeOrderCancelled},
+		[]string{models.CmdPaymentAuthorize, models.CmdPaymentCapture, models.CmdPaymentVoid},
+		models.TypePaymentRefundRequested, models.TypePaymentRetryRequested,
+	)
+
 	if err := rabbit.DeclareQueueWithDLQ(rc.Ch, rabbit.QueueSpec{
-		Name:     "payment.q",
-		BindKeys: []string{"inventory.reserved"},
-		DLQKey:   "payment.dlq",
-		Prefetch: 20,
+		Name:       "payment.q",
+		BindKeys:   keys,
+		UnbindKeys: other,
+		DLQKey:     "payment.dlq",
+		Prefetch:   20,
 	}); err != nil {
 		log.Fatal().Err(err).Msg("declare payment topology failed")
 	}
 
-	_ = rabbit.DeclareRetryQueue(rc.Ch, "payment.retry.inventory.reserved.5s", "payment.inventory.reserved", "inventory.reserved", 5000)
+	for _, k := range keys {
+		_ = rabbit.DeclareRetryQueue(rc.Ch, "payment.retry."+k+".5s", "payment."+k, k, 5000)
+	}
 
 	deliveries, err := rabbit.NewConsumer(rc.Ch).Consume("payment.q", 20)
 	if err != nil {
@@ -46,19 +80,73 @@ func main() {
 	}
 
 	w := &worker.Consumer{
-		Log:         log,
-		EventsPub:   rabbit.NewPublisher(rc.Ch, rabbit.ExchangeEvents),
-		RetryPub:    rabbit.NewPublisher(rc.Ch, rabbit.ExchangeRetry),
-		DLQPub:      rabbit.NewPublisher(rc.Ch, rabbit.ExchangeDLX),
-		Service:     "payment",
-		MaxAttempts: 5,
-		DLQKey:      "payment.dlq",
-		FailRate:    cfg.Payment.FailRate,
+		Log:       log,
+		EventsPub: rabbit.NewPublisher(rc.Ch, rabbit.ExchangeEvents).UseFormat(cfg.Events.Format, "payment-service").UseEncoding(cfg.Events.Encoding),
+		RetryPub:  rabbit.NewPublisher(rc.Ch, rabbit.ExchangeRetry),
+		DLQPub:    rabbit.NewPublisher(rc.Ch, rabbit.ExchangeDLX),
+		Payments: &repo.PaymentsPG{
+			DB:   db,
+			Fees: repo.FeeSchedule{BasisPoints: cfg.Payment.FeeBasisPoints, FixedCents: cfg.Payment.FeeFixedCents},
+		},
+		Balances: &repo.BalancesPG{DB: db},
+		Provider: &provider.Fake{
+			FailRate:        cfg.Payment.FailRate,
+			SoftDeclineRate: cfg.Payment.FakeSoftDeclineRate,
+			TransientRate:   cfg.Payment.FakeTransientRate,
+			TimeoutRate:     cfg.Payment.FakeTimeoutRate,
+			ActionRate:      cfg.Payment.FakeActionRate,
+			PendingRate:     cfg.Payment.FakePendingRate,
+			ConfirmDelay:    cfg.Payment.FakeConfirmDelay,
+			WebhookURL:      cfg.Payment.FakeWebhookURL,
+			Secret:          cfg.Payment.WebhookSecret,
+			Log:             log,
+		},
+		Retry: worker.RetryPolicy{
+			Timeout:  cfg.Payment.ProviderTimeout,
+			MaxTries: cfg.Payment.ProviderMaxTries,
+		},
+		Service:      "payment",
+		MaxAttempts:  maxAttempts,
+		DLQKey:       "payment.dlq",
+		AuthTTL:      cfg.Payment.AuthTTL,
+		PendingTTL:   cfg.Payment.PendingTTL,
+		RetryWindow:  cfg.Payment.RetryWindow,
+		Choreography: cfg.Saga.Mode == config.SagaChoreography,
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go w.Run(ctx, deliveries)
+	go w.RunExpiry(ctx, cfg.Payment.AuthSweepInterval)
+
+	ledger := &httpx.LedgerHandlers{Ledger: &repo.LedgerPG{DB: db}, Log: log}
+	payments := &httpx.PaymentHandlers{Payments: w.Payments, Log: log}
+	balances := &httpx.BalanceHandlers{Balances: w.Balances, Log: log}
+	webhooks := &httpx.WebhookHandlers{Applier: w, Secret: cfg.Payment.WebhookSecret, Tolerance: cfg.Payment.WebhookTolerance, Log: log}
+	srv := &http.Server{
+		Addr: cfg.Payment.HTTPAddr,
+		Handler: httpx.NewRouter(&httpx.Handlers{
+			Health:            httpx.Health,
+			OrderBalance:      ledger.OrderBalance,
+			OrderStatement:    ledger.OrderStatement,
+			CustomerBalance:   ledger.CustomerBalance,
+			CustomerStatement: ledger.CustomerStatement,
+			Reconciliation:    ledger.Reconciliation,
+			ProviderWebhook:   webhooks.Provider,
+			GetPayment:        payments.Get,
+			GetBalance:        balances.Get,
+			IssueBalance:      balances.Issue,
+			AdminAuth:         httpauth.Single(cfg.Payment.AdminToken),
+		}),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		log.Info().Str("addr", srv.Addr).Msg("http started")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal().Err(err).Msg("http failed")
+		}
+	}()
 
 	log.Info().Msg("payment worker started")
 
@@ -68,4 +156,7 @@ func main() {
 
 	log.Info().Msg("shutdown")
 	cancel()
+	shCtx, shCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shCancel()
+	_ = srv.Shutdown(shCtx)
 }
@@ -0,0 +1,112 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"ecommerce-order-system/services/payment-service/internal/repo"
+)
+
+type BalanceHandlers struct {
+	Balances *repo.BalancesPG
+	Log      zerolog.Logger
+}
+
+// issueReq adds AmountCents to a gift card (Code, generated if empty) or to
+// a customer's store credit (Code is the user id). IdempotencyKey makes a
+// repeated request return the first result.
+type issueReq struct {
+	IdempotencyKey string `json:"idempotency_key"`
+	Kind           string `json:"kind"`
+	Code           string `json:"code"`
+	AmountCents    int64  `json:"amount_cents"`
+	Currency       string `json:"currency"`
+	Reason         string `json:"reason"`
+}
+
+type storedBalanceResp struct {
+	Account      repo.BalanceAccount `json:"account"`
+	Transaction  *repo.BalanceTx     `json:"transaction,omitempty"`
+	Transactions []repo.BalanceTx    `json:"transactions,omitempty"`
+}
+
+// Issue loads a gift card or grants store credit.
+func (h *BalanceHandlers) Issue(w http.ResponseWriter, r *http.Request) {
+	var req issueReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	req.Currency = strings.ToUpper(req.Currency)
+	switch {
+	case req.IdempotencyKey == "":
+		http.Error(w, "idempotency_key is required", http.StatusBadRequest)
+		return
+	case req.Kind != repo.BalanceGiftCard && req.Kind != repo.BalanceStoreCredit:
+		http.Error(w, "kind must be gift_card or store_credit", http.StatusBadRequest)
+		return
+	case req.Kind == repo.BalanceStoreCredit && req.Code == "":
+		http.Error(w, "code (the user id) is required for store credit", http.StatusBadRequest)
+		return
+	case req.AmountCents <= 0:
+		http.Error(w, "amount_cents must be > 0", http.StatusBadRequest)
+		return
+	case len(req.Currency) != 3:
+		http.Error(w, "invalid currency", http.StatusBadRequest)
+		return
+	}
+	if req.Code == "" {
+		req.Code = "GC-" + strings.ToUpper(strings.ReplaceAll(uuid.NewString(), "-", "")[:16])
+	}
+
+	a, t, err := h.Balances.Issue(r.Context(), repo.BalanceOp{
+		Key:         "issue:" + req.IdempotencyKey,
+		Kind:        req.Kind,
+		Code:        req.Code,
+		Currency:    req.Currency,
+		AmountCents: req.AmountCents,
+		Reason:      req.Reason,
+	})
+	if errors.Is(err, repo.ErrBalanceCurrency) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		h.Log.Error().Err(err).Str("kind", req.Kind).Msg("issue balance failed")
+		http.Error(w, "failed to issue balance", http.StatusInternalServerError)
+		return
+	}
+	h.Log.Info().Str("kind", a.Kind).Int64("amount_cents", t.AmountCents).Str("currency", a.Currency).Msg("balance issued")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(storedBalanceResp{Account: a, Transaction: &t})
+}
+
+// Get returns a gift card or a customer's store credit with its latest 100
+// changes.
+func (h *BalanceHandlers) Get(w http.ResponseWriter, r *http.Request) {
+	kind, code := chi.URLParam(r, "kind"), chi.URLParam(r, "code")
+	a, err := h.Balances.Account(r.Context(), kind, code)
+	if errors.Is(err, repo.ErrBalanceNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Log.Error().Err(err).Str("kind", kind).Msg("get balance failed")
+		http.Error(w, "failed to read balance", http.StatusInternalServerError)
+		return
+	}
+	txs, err := h.Balances.Transactions(r.Context(), a.ID, 100)
+	if err != nil {
+		h.Log.Error().Err(err).Str("kind", kind).Msg("list balance transactions failed")
+		http.Error(w, "failed to read balance", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, storedBalanceResp{Account: a, Transactions: txs})
+}
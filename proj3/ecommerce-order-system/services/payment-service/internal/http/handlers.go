@@ -0,0 +1,139 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"ecommerce-order-system/services/payment-service/internal/repo"
+)
+
+func Health(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+type LedgerHandlers struct {
+	Ledger *repo.LedgerPG
+	Log    zerolog.Logger
+}
+
+type balanceResp struct {
+	OrderID    string         `json:"order_id,omitempty"`
+	CustomerID string         `json:"customer_id,omitempty"`
+	Balances   []repo.Balance `json:"balances"`
+}
+
+type statementResp struct {
+	OrderID    string       `json:"order_id,omitempty"`
+	CustomerID string       `json:"customer_id,omitempty"`
+	Entries    []repo.Entry `json:"entries"`
+}
+
+type reconciliationResp struct {
+	Date   string             `json:"date"`
+	Totals []repo.DailyTotals `json:"totals"`
+	// Unbalanced lists entries breaking the double-entry invariant; it
+	// should always be empty.
+	Unbalanced []string `json:"unbalanced_entries"`
+}
+
+func (h *LedgerHandlers) OrderBalance(w http.ResponseWriter, r *http.Request) {
+	if s, ok := orderScope(w, r); ok {
+		h.balance(w, r, s)
+	}
+}
+
+func (h *LedgerHandlers) OrderStatement(w http.ResponseWriter, r *http.Request) {
+	if s, ok := orderScope(w, r); ok {
+		h.statement(w, r, s)
+	}
+}
+
+func (h *LedgerHandlers) CustomerBalance(w http.ResponseWriter, r *http.Request) {
+	h.balance(w, r, repo.Scope{CustomerID: chi.URLParam(r, "id")})
+}
+
+func (h *LedgerHandlers) CustomerStatement(w http.ResponseWriter, r *http.Request) {
+	h.statement(w, r, repo.Scope{CustomerID: chi.URLParam(r, "id")})
+}
+
+// balance returns the net of every account touched by the scope's entries.
+func (h *LedgerHandlers) balance(w http.ResponseWriter, r *http.Request, s repo.Scope) {
+	balances, err := h.Ledger.Balances(r.Context(), s)
+	if err != nil {
+		h.Log.Error().Err(err).Str("order_id", s.OrderID).Str("customer_id", s.CustomerID).Msg("read ledger balances failed")
+		http.Error(w, "failed to read ledger", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, balanceResp{OrderID: s.OrderID, CustomerID: s.CustomerID, Balances: balances})
+}
+
+// statement returns the scope's entries oldest first, ?limit=N (max 1000).
+func (h *LedgerHandlers) statement(w http.ResponseWriter, r *http.Request, s repo.Scope) {
+	limit := 200
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	entries, err := h.Ledger.Statement(r.Context(), s, limit)
+	if err != nil {
+		h.Log.Error().Err(err).Str("order_id", s.OrderID).Str("customer_id", s.CustomerID).Msg("read ledger statement failed")
+		http.Error(w, "failed to read ledger", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, statementResp{OrderID: s.OrderID, CustomerID: s.CustomerID, Entries: entries})
+}
+
+// Reconciliation returns the day's totals per currency to compare with the
+// provider's settlement report: ?date=YYYY-MM-DD (UTC), today by default.
+func (h *LedgerHandlers) Reconciliation(w http.ResponseWriter, r *http.Request) {
+	day := time.Now().UTC()
+	if v := r.URL.Query().Get("date"); v != "" {
+		d, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			http.Error(w, "invalid date", http.StatusBadRequest)
+			return
+		}
+		day = d
+	}
+	totals, err := h.Ledger.Daily(r.Context(), day)
+	if err != nil {
+		h.Log.Error().Err(err).Msg("read daily ledger totals failed")
+		http.Error(w, "failed to read ledger", http.StatusInternalServerError)
+		return
+	}
+	unbalanced, err := h.Ledger.Unbalanced(r.Context())
+	if err != nil {
+		h.Log.Error().Err(err).Msg("ledger balance check failed")
+		http.Error(w, "failed to read ledger", http.StatusInternalServerError)
+		return
+	}
+	if len(unbalanced) > 0 {
+		h.Log.Error().Strs("entries", unbalanced).Msg("unbalanced ledger entries")
+	}
+	writeJSON(w, reconciliationResp{Date: day.Format(time.DateOnly), Totals: totals, Unbalanced: unbalanced})
+}
+
+func orderScope(w http.ResponseWriter, r *http.Request) (repo.Scope, bool) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return repo.Scope{}, false
+	}
+	return repo.Scope{OrderID: id}, true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,74 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+
+	"ecommerce-order-system/services/payment-service/internal/repo"
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+type PaymentHandlers struct {
+	Payments *repo.PaymentsPG
+	Log      zerolog.Logger
+}
+
+type paymentResp struct {
+	OrderID         string         `json:"order_id"`
+	Status          string         `json:"status"`
+	AuthorizationID string         `json:"authorization_id,omitempty"`
+	AmountCents     int            `json:"amount_cents"`
+	Currency        string         `json:"currency"`
+	ExpiresAt       time.Time      `json:"expires_at"`
+	ActionURL       string         `json:"action_url,omitempty"`
+	FailureReason   string         `json:"failure_reason,omitempty"`
+	Attempts        []repo.Attempt `json:"attempts"`
+	// Order is the order charged, as carried by the events.
+	Order *models.OrderSnapshotPayload `json:"order,omitempty"`
+}
+
+// Get returns an order's payment and every provider call made for it.
+// expires_at is the end of the retry window while the payment is declined.
+func (h *PaymentHandlers) Get(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	p, err := h.Payments.Get(r.Context(), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Log.Error().Err(err).Str("order_id", id).Msg("get payment failed")
+		http.Error(w, "failed to read payment", http.StatusInternalServerError)
+		return
+	}
+	attempts, err := h.Payments.Attempts(r.Context(), id)
+	if err != nil {
+		h.Log.Error().Err(err).Str("order_id", id).Msg("list payment attempts failed")
+		http.Error(w, "failed to read payment", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(paymentResp{
+		OrderID:         p.OrderID,
+		Status:          p.Status,
+		AuthorizationID: p.AuthorizationID,
+		AmountCents:     p.AmountCents,
+		Currency:        p.Currency,
+		ExpiresAt:       p.ExpiresAt,
+		ActionURL:       p.ActionURL,
+		FailureReason:   p.FailureReason,
+		Attempts:        attempts,
+		Order:           p.Order,
+	})
+}
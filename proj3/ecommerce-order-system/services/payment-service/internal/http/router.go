@@ -0,0 +1,48 @@
+package httpx
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"ecommerce-order-system/shared/pkg/metrics"
+)
+
+type Handlers struct {
+	Health            http.HandlerFunc
+	OrderBalance      http.HandlerFunc
+	OrderStatement    http.HandlerFunc
+	CustomerBalance   http.HandlerFunc
+	CustomerStatement http.HandlerFunc
+	Reconciliation    http.HandlerFunc
+	ProviderWebhook   http.HandlerFunc
+	GetPayment        http.HandlerFunc
+	GetBalance        http.HandlerFunc
+	IssueBalance      http.HandlerFunc
+	AdminAuth         func(http.Handler) http.Handler
+}
+
+func NewRouter(h *Handlers) http.Handler {
+	r := chi.NewRouter()
+	r.Use(metrics.Middleware("payment-service"))
+	r.Handle("/metrics", promhttp.Handler())
+
+	r.Get("/health", h.Health)
+	r.Route("/api/v1/ledger", func(r chi.Router) {
+		r.Use(h.AdminAuth)
+		r.Get("/orders/{id}/balance", h.OrderBalance)
+		r.Get("/orders/{id}/statement", h.OrderStatement)
+		r.Get("/customers/{id}/balance", h.CustomerBalance)
+		r.Get("/customers/{id}/statement", h.CustomerStatement)
+		r.Get("/reconciliation", h.Reconciliation)
+	})
+	r.Get("/api/v1/payments/{id}", h.GetPayment)
+	r.With(h.AdminAuth).Get("/api/v1/balances/{kind}/{code}", h.GetBalance)
+	r.Route("/admin/balances", func(r chi.Router) {
+		r.Use(h.AdminAuth)
+		r.Post("/", h.IssueBalance)
+	})
+	r.Post("/webhooks/psp", h.ProviderWebhook)
+	return r
+}
@@ -0,0 +1,71 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+
+	"ecommerce-order-system/services/payment-service/internal/provider"
+)
+
+// WebhookApplier applies a verified provider webhook; the worker publishes
+// the outcome.
+type WebhookApplier interface {
+	ApplyWebhook(ctx context.Context, wh provider.Webhook) (string, error)
+}
+
+type WebhookHandlers struct {
+	Applier   WebhookApplier
+	Secret    string
+	Tolerance time.Duration
+	Log       zerolog.Logger
+}
+
+// Provider receives the PSP's authorization webhooks. Anything but 2xx makes
+// the provider deliver again, so a payment not stored yet gets 404 and a
+// webhook already applied gets 200.
+func (h *WebhookHandlers) Provider(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "bad body", http.StatusBadRequest)
+		return
+	}
+	if err := provider.Verify(h.Secret, r.Header.Get(provider.SignatureHeader), body, h.Tolerance, time.Now()); err != nil {
+		h.Log.Warn().Err(err).Msg("provider webhook rejected")
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var wh provider.Webhook
+	if err := json.Unmarshal(body, &wh); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if wh.ID == "" || wh.Data.Reference == "" {
+		http.Error(w, "id and data.reference are required", http.StatusBadRequest)
+		return
+	}
+	if wh.Type != provider.WebhookAuthorizationSucceeded && wh.Type != provider.WebhookAuthorizationFailed {
+		http.Error(w, "unsupported webhook type", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.Applier.ApplyWebhook(r.Context(), wh)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "unknown reference", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Log.Error().Err(err).Str("webhook_id", wh.ID).Msg("apply provider webhook failed")
+		http.Error(w, "failed to apply webhook", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"id": wh.ID, "result": result})
+}
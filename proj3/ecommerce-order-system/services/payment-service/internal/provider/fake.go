@@ -0,0 +1,195 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// Test payment methods the fake answers deterministically, whatever the
+// rates.
+const (
+	FakeMethodApproved    = "pm_approved"
+	FakeMethodHardDecline = "pm_hard_decline"
+	FakeMethodSoftDecline = "pm_soft_decline"
+	FakeMethodTransient   = "pm_transient"
+	FakeMethodTimeout     = "pm_timeout"
+)
+
+// Fake is a local PSP. By percent of authorizations it hard-declines
+// FailRate, soft-declines SoftDeclineRate, fails TransientRate with a
+// transient error and never answers TimeoutRate (until ctx is done); of the
+// rest it asks for 3-D Secure on ActionRate and leaves PendingRate pending,
+// and approves the others at once. Asynchronous ones are decided after
+// ConfirmDelay (hard-declined again with FailRate) and reported to
+// WebhookURL, retried until the webhook answers 2xx. Answers are kept in
+// memory by idempotency key and repeated for the same key; transient errors
+// and timeouts are not, so a call repeated after one is decided afresh.
+type Fake struct {
+	FailRate        int // 0..100
+	SoftDeclineRate int
+	TransientRate   int
+	TimeoutRate     int
+	ActionRate      int
+	PendingRate     int
+	ConfirmDelay    time.Duration
+
+	WebhookURL string
+	Secret     string
+	Client     *http.Client
+	Log        zerolog.Logger
+
+	mu      sync.Mutex
+	rng     *rand.Rand
+	answers map[string]Authorization // by idempotency key
+}
+
+func (f *Fake) Authorize(ctx context.Context, req AuthorizeRequest) (Authorization, error) {
+	if a, ok := f.answer(req.IdempotencyKey); ok {
+		return a, nil
+	}
+	a := Authorization{Reference: "AUTH-" + uuid.NewString()}
+
+	outcome := f.pick(req.PaymentMethod)
+	switch outcome {
+	case OutcomeHardDecline:
+		a.Status, a.Decline, a.Reason = StatusDeclined, DeclineHard, "simulated hard decline: card reported stolen"
+	case OutcomeSoftDecline:
+		a.Status, a.Decline, a.Reason = StatusDeclined, DeclineSoft, "simulated soft decline: insufficient funds"
+	case OutcomeTransient:
+		return Authorization{}, fmt.Errorf("%w: simulated 503", ErrTransient)
+	case OutcomeTimeout:
+		<-ctx.Done()
+		return Authorization{}, ctx.Err()
+	case OutcomeRequiresAction:
+		a.Status, a.ActionURL = StatusRequiresAction, "https://psp.local/3ds/"+a.Reference
+	case OutcomePending:
+		a.Status = StatusPending
+	default:
+		a.Status = StatusApproved
+	}
+	if a.Status == StatusRequiresAction || a.Status == StatusPending {
+		go f.confirmLater(a.Reference)
+	}
+	f.remember(req.IdempotencyKey, a)
+	return a, nil
+}
+
+func (f *Fake) answer(key string) (Authorization, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	a, ok := f.answers[key]
+	return a, ok && key != ""
+}
+
+func (f *Fake) remember(key string, a Authorization) {
+	if key == "" {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.answers == nil {
+		f.answers = map[string]Authorization{}
+	}
+	f.answers[key] = a
+}
+
+// pick decides the outcome of one call.
+func (f *Fake) pick(method string) string {
+	switch method {
+	case FakeMethodApproved:
+		return OutcomeApproved
+	case FakeMethodHardDecline:
+		return OutcomeHardDecline
+	case FakeMethodSoftDecline:
+		return OutcomeSoftDecline
+	case FakeMethodTransient:
+		return OutcomeTransient
+	case FakeMethodTimeout:
+		return OutcomeTimeout
+	}
+	roll := f.roll()
+	for _, r := range []struct {
+		rate    int
+		outcome string
+	}{
+		{f.FailRate, OutcomeHardDecline},
+		{f.SoftDeclineRate, OutcomeSoftDecline},
+		{f.TransientRate, OutcomeTransient},
+		{f.TimeoutRate, OutcomeTimeout},
+		{f.ActionRate, OutcomeRequiresAction},
+		{f.PendingRate, OutcomePending},
+	} {
+		if roll < r.rate {
+			return r.outcome
+		}
+		roll -= r.rate
+	}
+	return OutcomeApproved
+}
+
+func (f *Fake) confirmLater(reference string) {
+	time.Sleep(f.ConfirmDelay)
+
+	wh := Webhook{ID: "evt_" + uuid.NewString(), Type: WebhookAuthorizationSucceeded, Created: time.Now().Unix()}
+	wh.Data.Reference = reference
+	if f.roll() < f.FailRate {
+		wh.Type, wh.Data.Reason = WebhookAuthorizationFailed, "simulated 3-D Secure failure"
+	}
+	body, err := json.Marshal(wh)
+	if err != nil {
+		f.Log.Error().Err(err).Msg("fake psp: marshal webhook failed")
+		return
+	}
+
+	backoff := time.Second
+	for attempt := 1; attempt <= 10; attempt++ {
+		err := f.post(body)
+		if err == nil {
+			return
+		}
+		f.Log.Warn().Err(err).Str("reference", reference).Int("attempt", attempt).Msg("fake psp: webhook delivery failed")
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	f.Log.Error().Str("reference", reference).Msg("fake psp: webhook given up")
+}
+
+func (f *Fake) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, f.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(f.Secret, time.Now(), body))
+	client := f.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+func (f *Fake) roll() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rng == nil {
+		f.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return f.rng.Intn(100)
+}
@@ -0,0 +1,173 @@
+// Package provider talks to the payment service provider (PSP). Authorizations
+// may be confirmed later: the PSP then calls the webhook with a signed
+// Webhook once the customer completed 3-D Secure or the transfer arrived.
+package provider
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Authorization outcomes.
+const (
+	// StatusApproved: the amount is held now.
+	StatusApproved = "approved"
+	// StatusDeclined: the authorization failed; Decline says whether another
+	// attempt may succeed and Reason why.
+	StatusDeclined = "declined"
+	// StatusRequiresAction: the customer must confirm (3-D Secure) at
+	// ActionURL; the result comes by webhook.
+	StatusRequiresAction = "requires_action"
+	// StatusPending: the PSP confirms on its own later (bank transfer); the
+	// result comes by webhook.
+	StatusPending = "pending"
+)
+
+// Decline kinds. A hard decline (stolen card, closed account) is final; a
+// soft one (insufficient funds, issuer unavailable, limit reached) may pass
+// with another payment method or later.
+const (
+	DeclineHard = "hard"
+	DeclineSoft = "soft"
+)
+
+// Outcomes of one authorization attempt, as recorded and as the retry
+// policy sees them.
+const (
+	OutcomeApproved       = "approved"
+	OutcomeRequiresAction = "requires_action"
+	OutcomePending        = "pending"
+	OutcomeHardDecline    = "hard_decline"
+	OutcomeSoftDecline    = "soft_decline"
+	OutcomeTransient      = "transient_error"
+	OutcomeTimeout        = "timeout"
+)
+
+// ErrTransient marks provider errors worth retrying at once (connection
+// reset, 5xx); wrap it to add detail.
+var ErrTransient = errors.New("provider temporarily unavailable")
+
+// Classify maps the result of Authorize to an outcome. Errors other than a
+// timeout are treated as transient.
+func Classify(a Authorization, err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return OutcomeTimeout
+	case err != nil:
+		return OutcomeTransient
+	case a.Status == StatusApproved:
+		return OutcomeApproved
+	case a.Status == StatusRequiresAction:
+		return OutcomeRequiresAction
+	case a.Status == StatusPending:
+		return OutcomePending
+	case a.Decline == DeclineSoft:
+		return OutcomeSoftDecline
+	default:
+		return OutcomeHardDecline
+	}
+}
+
+// Webhook types.
+const (
+	WebhookAuthorizationSucceeded = "authorization.succeeded"
+	WebhookAuthorizationFailed    = "authorization.failed"
+)
+
+// SignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256>" of
+// "<t>.<body>" under the shared webhook secret.
+const SignatureHeader = "Provider-Signature"
+
+var ErrBadSignature = errors.New("invalid webhook signature")
+
+type Provider interface {
+	Authorize(ctx context.Context, req AuthorizeRequest) (Authorization, error)
+}
+
+// AuthorizeRequest asks for AmountCents from PaymentMethod (a provider
+// token; empty means the one the customer checked out with).
+// IdempotencyKey is the same on every retry of one attempt, so a request
+// that timed out but went through is not authorized twice.
+type AuthorizeRequest struct {
+	OrderID        string
+	AmountCents    int
+	Currency       string
+	PaymentMethod  string
+	IdempotencyKey string
+}
+
+type Authorization struct {
+	// Reference identifies the authorization at the PSP; webhooks refer to it.
+	Reference string
+	Status    string
+	Decline   string
+	Reason    string
+	ActionURL string
+}
+
+// Webhook is what the PSP posts when an authorization is decided. Every
+// delivery attempt of one notification carries the same ID.
+type Webhook struct {
+	ID      string      `json:"id"`
+	Type    string      `json:"type"`
+	Created int64       `json:"created"`
+	Data    WebhookData `json:"data"`
+}
+
+type WebhookData struct {
+	Reference string `json:"reference"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Sign returns the SignatureHeader value for body signed at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + mac(secret, ts, body)
+}
+
+// Verify checks header against body. Signatures older (or newer) than
+// tolerance are rejected so a captured request cannot be replayed later.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts string
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return fmt.Errorf("%w: malformed header", ErrBadSignature)
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrBadSignature)
+	}
+	want := mac(secret, ts, body)
+	for _, s := range sigs {
+		if hmac.Equal([]byte(s), []byte(want)) {
+			return nil
+		}
+	}
+	return ErrBadSignature
+}
+
+func mac(secret, ts string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(ts))
+	m.Write([]byte("."))
+	m.Write(body)
+	return hex.EncodeToString(m.Sum(nil))
+}
@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"id":"evt_1"}`)
+	now := time.Unix(1_700_000_000, 0)
+	valid := Sign(secret, now, body)
+	tests := []struct {
+		name   string
+		header string
+		body   []byte
+		now    time.Time
+		ok     bool
+	}{
+		{"valid", valid, body, now, true},
+		{"valid at tolerance", valid, body, now.Add(5 * time.Minute), true},
+		{"second signature matches", "t=1700000000,v1=deadbeef," + valid[len("t=1700000000,"):], body, now, true},
+		{"spaces around parts", "t=1700000000, " + valid[len("t=1700000000,"):], body, now, true},
+		{"too old", valid, body, now.Add(5*time.Minute + time.Second), false},
+		{"from the future", valid, body, now.Add(-5*time.Minute - time.Second), false},
+		{"body changed", valid, []byte(`{"id":"evt_2"}`), now, false},
+		{"other secret", Sign("whsec_other", now, body), body, now, false},
+		{"timestamp changed", "t=1700000001," + valid[len("t=1700000000,"):], body, now, false},
+		{"empty", "", body, now, false},
+		{"no signature", "t=1700000000", body, now, false},
+		{"no timestamp", valid[len("t=1700000000,"):], body, now, false},
+		{"bad timestamp", "t=soon," + valid[len("t=1700000000,"):], body, now, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(secret, tt.header, tt.body, 5*time.Minute, tt.now)
+			if tt.ok && err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrBadSignature) {
+				t.Fatalf("Verify = %v, want ErrBadSignature", err)
+			}
+		})
+	}
+}
+
+type netTimeout struct{}
+
+func (netTimeout) Error() string   { return "i/o timeout" }
+func (netTimeout) Timeout() bool   { return true }
+func (netTimeout) Temporary() bool { return true }
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		a    Authorization
+		err  error
+		want string
+	}{
+		{"approved", Authorization{Status: StatusApproved}, nil, OutcomeApproved},
+		{"requires action", Authorization{Status: StatusRequiresAction}, nil, OutcomeRequiresAction},
+		{"pending", Authorization{Status: StatusPending}, nil, OutcomePending},
+		{"soft decline", Authorization{Status: StatusDeclined, Decline: DeclineSoft}, nil, OutcomeSoftDecline},
+		{"hard decline", Authorization{Status: StatusDeclined, Decline: DeclineHard}, nil, OutcomeHardDecline},
+		{"decline without kind", Authorization{Status: StatusDeclined}, nil, OutcomeHardDecline},
+		{"deadline exceeded", Authorization{}, context.DeadlineExceeded, OutcomeTimeout},
+		{"wrapped deadline", Authorization{}, fmt.Errorf("call: %w", context.DeadlineExceeded), OutcomeTimeout},
+		{"network timeout", Authorization{}, netTimeout{}, OutcomeTimeout},
+		{"transient", Authorization{}, fmt.Errorf("%w: 503", ErrTransient), OutcomeTransient},
+		{"other error", Authorization{Status: StatusApproved}, errors.New("connection reset"), OutcomeTransient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.a, tt.err); got != tt.want {
+				t.Fatalf("Classify = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// AttemptStarted is the outcome of a call not answered yet.
+const AttemptStarted = "started"
+
+// Attempt is one call to the provider.
+type Attempt struct {
+	ID             int64     `json:"-"`
+	OrderID        string    `json:"-"`
+	Attempt        int       `json:"attempt"`
+	Try            int       `json:"try"`
+	RequestID      string    `json:"request_id,omitempty"`
+	PaymentMethod  string    `json:"payment_method,omitempty"`
+	IdempotencyKey string    `json:"idempotency_key,omitempty"`
+	Outcome        string    `json:"outcome"`
+	Reason         string    `json:"reason,omitempty"`
+	Reference      string    `json:"reference,omitempty"`
+	DurationMS     int64     `json:"duration_ms"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+const attemptColumns = `
+	id, order_id::text, attempt, try, coalesce(request_id, ''), payment_method, coalesce(idempotency_key, ''), outcome,
+	coalesce(reason, ''), coalesce(reference, ''), duration_ms, created_at`
+
+// StartAttempt records a call about to be made with a.IdempotencyKey and
+// returns it numbered: a key used before continues its attempt with the
+// next try, any other starts the order's next attempt.
+func (r *PaymentsPG) StartAttempt(ctx context.Context, a Attempt) (Attempt, error) {
+	return scanAttempt(r.DB.QueryRow(ctx, `
+		with prev as (
+			select attempt, max(try) as try
+			from payment_attempts
+			where order_id = $1::uuid and idempotency_key = $2
+			group by attempt
+			order by attempt desc
+			limit 1
+		)
+		insert into payment_attempts (order_id, attempt, try, request_id, payment_method, idempotency_key, outcome, duration_ms)
+		select $1::uuid,
+		       coalesce((select attempt from prev),
+		                (select coalesce(max(attempt), 0) + 1 from payment_attempts where order_id = $1::uuid)),
+		       coalesce((select try from prev), 0) + 1,
+		       nullif($3, ''), $4, $2, $5, 0
+		returning `+attemptColumns,
+		a.OrderID, a.IdempotencyKey, a.RequestID, a.PaymentMethod, AttemptStarted))
+}
+
+// FinishAttempt stores the answer to a started call.
+func (r *PaymentsPG) FinishAttempt(ctx context.Context, a Attempt) error {
+	_, err := r.DB.Exec(ctx, `
+		update payment_attempts
+		set outcome = $2, reason = nullif($3, ''), reference = nullif($4, ''), duration_ms = $5
+		where id = $1
+	`, a.ID, a.Outcome, a.Reason, a.Reference, a.DurationMS)
+	return err
+}
+
+// LastRequestAttempt returns the latest provider call made for a retry
+// request; pgx.ErrNoRows if there was none.
+func (r *PaymentsPG) LastRequestAttempt(ctx context.Context, requestID string) (Attempt, error) {
+	return scanAttempt(r.DB.QueryRow(ctx, `
+		select `+attemptColumns+`
+		from payment_attempts
+		where request_id = $1
+		order by id desc
+		limit 1
+	`, requestID))
+}
+
+// Attempts lists an order's provider calls, oldest first.
+func (r *PaymentsPG) Attempts(ctx context.Context, orderID string) ([]Attempt, error) {
+	rows, err := r.DB.Query(ctx, `
+		select `+attemptColumns+`
+		from payment_attempts
+		where order_id = $1::uuid
+		order by id
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Attempt, error) { return scanAttempt(row) })
+}
+
+// Reattempt replaces a declined payment with the result of a retry: next's
+// AuthorizationID, Status, ActionURL and FailureReason. An authorization
+// books the hold and runs for authTTL; an unconfirmed one gets pendingTTL; a
+// new soft decline keeps the original window. Seq moves on by one for the
+// event about to be published. ErrNotRetryable is returned with the payment
+// if it is no longer declined or its window has closed.
+func (r *PaymentsPG) Reattempt(ctx context.Context, next Payment, authTTL, pendingTTL time.Duration) (Payment, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return Payment{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	p, err := scanPayment(tx.QueryRow(ctx, `select `+paymentColumns+` from payments where order_id = $1::uuid for update`, next.OrderID))
+	if err != nil {
+		return Payment{}, err
+	}
+	now := time.Now()
+	if p.Status != StatusDeclined || !p.ExpiresAt.After(now) {
+		return p, ErrNotRetryable
+	}
+
+	switch {
+	case next.Status == StatusAuthorized:
+		p.ExpiresAt, p.AuthorizedAt = now.Add(authTTL), now
+	case next.Unconfirmed():
+		p.ExpiresAt = now.Add(pendingTTL)
+	case next.Status != StatusDeclined && next.Status != StatusFailed:
+		return Payment{}, errors.New("reattempt: unexpected status " + next.Status)
+	}
+	if next.AuthorizationID != "" {
+		p.AuthorizationID = next.AuthorizationID
+	}
+	p.Status, p.ActionURL, p.FailureReason, p.Seq = next.Status, next.ActionURL, next.FailureReason, p.Seq+1
+
+	if _, err := tx.Exec(ctx, `
+		update payments
+		set authorization_id = nullif($2, ''), status = $3, expires_at = $4, authorized_at = $5,
+		    action_url = nullif($6, ''), failure_reason = nullif($7, ''), seq = $8, updated_at = now()
+		where order_id = $1::uuid
+	`, p.OrderID, p.AuthorizationID, p.Status, p.ExpiresAt, p.AuthorizedAt, p.ActionURL, p.FailureReason, p.Seq); err != nil {
+		return Payment{}, err
+	}
+	switch p.Status {
+	case StatusAuthorized:
+		if err := postTx(ctx, tx, authorizationEntry(p)); err != nil {
+			return Payment{}, err
+		}
+	case StatusFailed:
+		if _, err := reverseOrderTx(ctx, tx, p.OrderID, p.FailureReason); err != nil {
+			return Payment{}, err
+		}
+	}
+	return p, tx.Commit(ctx)
+}
+
+// LastAttempt returns an order's latest provider call.
+func (r *PaymentsPG) LastAttempt(ctx context.Context, orderID string) (Attempt, error) {
+	return scanAttempt(r.DB.QueryRow(ctx, `
+		select `+attemptColumns+`
+		from payment_attempts
+		where order_id = $1::uuid
+		order by id desc
+		limit 1
+	`, orderID))
+}
+
+func scanAttempt(row pgx.Row) (Attempt, error) {
+	var a Attempt
+	err := row.Scan(&a.ID, &a.OrderID, &a.Attempt, &a.Try, &a.RequestID, &a.PaymentMethod, &a.IdempotencyKey, &a.Outcome,
+		&a.Reason, &a.Reference, &a.DurationMS, &a.CreatedAt)
+	return a, err
+}
@@ -0,0 +1,286 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+// Balance kinds (balance_accounts.kind), named like the payment methods that
+// spend them.
+const (
+	BalanceGiftCard    = models.PaymentMethodGiftCard
+	BalanceStoreCredit = models.PaymentMethodStoreCredit
+)
+
+// Balance transaction kinds.
+const (
+	BalanceIssue   = "issue"
+	BalanceRedeem  = "redeem"
+	BalanceReverse = "reverse"
+)
+
+var (
+	// ErrBalanceNotFound: no gift card with that code, or the customer has
+	// never been given store credit.
+	ErrBalanceNotFound = errors.New("balance not found")
+	// ErrBalanceCurrency: the balance is held in another currency.
+	ErrBalanceCurrency = errors.New("balance is in another currency")
+)
+
+// BalanceAccount is a gift card (Code is its code) or a customer's store
+// credit (Code is the user id).
+type BalanceAccount struct {
+	ID           int64     `json:"-"`
+	Kind         string    `json:"kind"`
+	Code         string    `json:"code"`
+	Currency     string    `json:"currency"`
+	BalanceCents int64     `json:"balance_cents"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// BalanceTx is one change of a balance. RedemptionID is set on reversals.
+type BalanceTx struct {
+	ID             int64     `json:"id"`
+	AccountID      int64     `json:"-"`
+	Kind           string    `json:"kind"`
+	AmountCents    int64     `json:"amount_cents"`
+	OrderID        string    `json:"order_id,omitempty"`
+	RedemptionID   *int64    `json:"redemption_id,omitempty"`
+	IdempotencyKey string    `json:"idempotency_key"`
+	Reason         string    `json:"reason,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// BalanceOp asks to issue or redeem AmountCents. Key makes it idempotent:
+// an operation with a key already used returns the stored transaction.
+type BalanceOp struct {
+	Key         string
+	Kind        string
+	Code        string
+	Currency    string
+	AmountCents int64
+	OrderID     string
+	Reason      string
+}
+
+// BalancesPG stores gift cards and store credit. Issuing and redeeming are
+// done here; reversals happen in PaymentsPG, in the transaction that fails,
+// voids or refunds the payment they belong to.
+type BalancesPG struct{ DB *pgxpool.Pool }
+
+const balanceAccountColumns = `id, kind, code, currency, balance_cents, created_at`
+
+const balanceTxColumns = `
+	id, account_id, kind, amount_cents, coalesce(order_id::text, ''), redemption_id, idempotency_key, coalesce(reason, ''), created_at`
+
+// Issue adds value to a balance, opening it on first use. Store credit keeps
+// the currency it was first issued in.
+func (r *BalancesPG) Issue(ctx context.Context, op BalanceOp) (BalanceAccount, BalanceTx, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return BalanceAccount{}, BalanceTx{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if t, a, err := balanceTxByKey(ctx, tx, op.Key); err == nil {
+		return a, t, nil
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return BalanceAccount{}, BalanceTx{}, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		insert into balance_accounts (kind, code, currency) values ($1, $2, $3)
+		on conflict (kind, code) do nothing
+	`, op.Kind, op.Code, op.Currency); err != nil {
+		return BalanceAccount{}, BalanceTx{}, err
+	}
+	a, err := lockBalance(ctx, tx, op.Kind, op.Code, op.Currency)
+	if err != nil {
+		return BalanceAccount{}, BalanceTx{}, err
+	}
+	t, err := changeBalanceTx(ctx, tx, &a, BalanceIssue, op.AmountCents, op.OrderID, nil, op.Key, op.Reason)
+	if err != nil {
+		return BalanceAccount{}, BalanceTx{}, err
+	}
+	return a, t, tx.Commit(ctx)
+}
+
+// Redeem takes up to op.AmountCents from a balance, as much as it holds. A
+// balance that holds nothing redeems zero and records nothing.
+func (r *BalancesPG) Redeem(ctx context.Context, op BalanceOp) (BalanceTx, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return BalanceTx{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if t, _, err := balanceTxByKey(ctx, tx, op.Key); err == nil {
+		return t, nil
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return BalanceTx{}, err
+	}
+
+	a, err := lockBalance(ctx, tx, op.Kind, op.Code, op.Currency)
+	if err != nil {
+		return BalanceTx{}, err
+	}
+	amount := min(op.AmountCents, a.BalanceCents)
+	if amount <= 0 {
+		return BalanceTx{Kind: BalanceRedeem, OrderID: op.OrderID, IdempotencyKey: op.Key}, nil
+	}
+	t, err := changeBalanceTx(ctx, tx, &a, BalanceRedeem, -amount, op.OrderID, nil, op.Key, op.Reason)
+	if err != nil {
+		return BalanceTx{}, err
+	}
+	return t, tx.Commit(ctx)
+}
+
+// Account returns a balance; ErrBalanceNotFound if there is none.
+func (r *BalancesPG) Account(ctx context.Context, kind, code string) (BalanceAccount, error) {
+	a, err := scanBalanceAccount(r.DB.QueryRow(ctx, `
+		select `+balanceAccountColumns+` from balance_accounts where kind = $1 and code = $2
+	`, kind, code))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return a, ErrBalanceNotFound
+	}
+	return a, err
+}
+
+// Transactions lists a balance's changes, newest first.
+func (r *BalancesPG) Transactions(ctx context.Context, accountID int64, limit int) ([]BalanceTx, error) {
+	rows, err := r.DB.Query(ctx, `
+		select `+balanceTxColumns+`
+		from balance_transactions
+		where account_id = $1
+		order by id desc
+		limit $2
+	`, accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (BalanceTx, error) { return scanBalanceTx(row) })
+}
+
+// reverseOrderTx returns to their balances whatever is left of the order's
+// redemptions, within tx. It reports the amount returned.
+func reverseOrderTx(ctx context.Context, tx pgx.Tx, orderID, reason string) (int, error) {
+	return reverseTx(ctx, tx, orderID, -1, "reverse:%d", reason)
+}
+
+// refundBalancesTx returns up to amount of the order's redemptions, newest
+// first, for refund refundID. It reports the amount returned.
+func refundBalancesTx(ctx context.Context, tx pgx.Tx, orderID, refundID string, amount int) (int, error) {
+	return reverseTx(ctx, tx, orderID, amount, "refund:"+refundID+":%d", "refund "+refundID)
+}
+
+// reverseTx reverses the order's redemptions, newest first, until amount is
+// returned (all of them if amount < 0). keyFormat names each reversal from
+// the redemption's id.
+func reverseTx(ctx context.Context, tx pgx.Tx, orderID string, amount int, keyFormat, reason string) (int, error) {
+	type open struct {
+		id, accountID, left int64
+	}
+	rows, err := tx.Query(ctx, `
+		select r.id, r.account_id,
+		       r.amount_cents - coalesce((select sum(v.amount_cents) from balance_transactions v where v.redemption_id = r.id), 0)::bigint
+		from balance_transactions r
+		where r.order_id = $1::uuid and r.kind = $2
+		order by r.id desc
+	`, orderID, BalanceRedeem)
+	if err != nil {
+		return 0, err
+	}
+	redemptions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (open, error) {
+		var o open
+		err := row.Scan(&o.id, &o.accountID, &o.left)
+		return o, err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	returned := 0
+	for _, o := range redemptions {
+		n := o.left
+		if amount >= 0 {
+			n = min(n, int64(amount-returned))
+		}
+		if n <= 0 {
+			continue
+		}
+		a, err := scanBalanceAccount(tx.QueryRow(ctx, `
+			select `+balanceAccountColumns+` from balance_accounts where id = $1 for update
+		`, o.accountID))
+		if err != nil {
+			return 0, err
+		}
+		if _, err := changeBalanceTx(ctx, tx, &a, BalanceReverse, n, orderID, &o.id, fmt.Sprintf(keyFormat, o.id), reason); err != nil {
+			return 0, err
+		}
+		returned += int(n)
+	}
+	return returned, nil
+}
+
+// lockBalance locks the balance an operation applies to.
+func lockBalance(ctx context.Context, tx pgx.Tx, kind, code, currency string) (BalanceAccount, error) {
+	a, err := scanBalanceAccount(tx.QueryRow(ctx, `
+		select `+balanceAccountColumns+` from balance_accounts where kind = $1 and code = $2 for update
+	`, kind, code))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return a, fmt.Errorf("%w: %s %s", ErrBalanceNotFound, kind, code)
+	}
+	if err != nil {
+		return a, err
+	}
+	if a.Currency != currency {
+		return a, fmt.Errorf("%w: %s %s holds %s, not %s", ErrBalanceCurrency, kind, code, a.Currency, currency)
+	}
+	return a, nil
+}
+
+// changeBalanceTx moves a locked balance by delta and records it as a
+// transaction of kind; the stored amount is always positive.
+func changeBalanceTx(ctx context.Context, tx pgx.Tx, a *BalanceAccount, kind string, delta int64, orderID string, redemptionID *int64, key, reason string) (BalanceTx, error) {
+	if err := tx.QueryRow(ctx, `
+		update balance_accounts set balance_cents = balance_cents + $2, updated_at = now() where id = $1
+		returning balance_cents
+	`, a.ID, delta).Scan(&a.BalanceCents); err != nil {
+		return BalanceTx{}, err
+	}
+	return scanBalanceTx(tx.QueryRow(ctx, `
+		insert into balance_transactions (account_id, kind, amount_cents, order_id, redemption_id, idempotency_key, reason)
+		values ($1, $2, $3, nullif($4, '')::uuid, $5, $6, nullif($7, ''))
+		returning `+balanceTxColumns,
+		a.ID, kind, max(delta, -delta), orderID, redemptionID, key, reason))
+}
+
+// balanceTxByKey returns the transaction made under key and its balance;
+// pgx.ErrNoRows if the key is unused.
+func balanceTxByKey(ctx context.Context, tx pgx.Tx, key string) (BalanceTx, BalanceAccount, error) {
+	t, err := scanBalanceTx(tx.QueryRow(ctx, `select `+balanceTxColumns+` from balance_transactions where idempotency_key = $1`, key))
+	if err != nil {
+		return BalanceTx{}, BalanceAccount{}, err
+	}
+	a, err := scanBalanceAccount(tx.QueryRow(ctx, `select `+balanceAccountColumns+` from balance_accounts where id = $1`, t.AccountID))
+	return t, a, err
+}
+
+func scanBalanceAccount(row pgx.Row) (BalanceAccount, error) {
+	var a BalanceAccount
+	err := row.Scan(&a.ID, &a.Kind, &a.Code, &a.Currency, &a.BalanceCents, &a.CreatedAt)
+	return a, err
+}
+
+func scanBalanceTx(row pgx.Row) (BalanceTx, error) {
+	var t BalanceTx
+	err := row.Scan(&t.ID, &t.AccountID, &t.Kind, &t.AmountCents, &t.OrderID, &t.RedemptionID, &t.IdempotencyKey, &t.Reason, &t.CreatedAt)
+	return t, err
+}
@@ -0,0 +1,330 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Ledger accounts (ledger_accounts). Authorization holds are memo accounts:
+// they net to zero for an order once its authorization is captured, voided
+// or expired. psp_receivable is what the provider owes us and is what daily
+// settlements are reconciled against. balance_liability moves when gift cards
+// and store credit pay for a sale or get a refund back; issuing them is not
+// booked here.
+const (
+	AccountAuthHolds       = "authorization_holds"
+	AccountAuthCommitments = "authorization_commitments"
+	AccountPSPReceivable   = "psp_receivable"
+	AccountSales           = "sales"
+	AccountRefunds         = "refunds"
+	AccountPSPFees         = "psp_fees"
+	AccountBalances        = "balance_liability"
+)
+
+// Journal entry kinds.
+const (
+	EntryAuthorization = "authorization"
+	EntryCapture       = "capture"
+	EntryFee           = "fee"
+	EntryRefund        = "refund"
+	EntryVoid          = "void"
+	EntryExpiry        = "expiry"
+)
+
+var accounts = map[string]bool{
+	AccountAuthHolds:       true,
+	AccountAuthCommitments: true,
+	AccountPSPReceivable:   true,
+	AccountSales:           true,
+	AccountRefunds:         true,
+	AccountPSPFees:         true,
+	AccountBalances:        true,
+}
+
+var ErrUnbalanced = errors.New("ledger entry does not balance")
+
+// Posting moves AmountCents on one account: debits are positive, credits
+// negative.
+type Posting struct {
+	Account     string `json:"account"`
+	AmountCents int64  `json:"amount_cents"`
+}
+
+// Entry is one journal entry. Kind and Ref identify what it records (e.g. the
+// capture of an authorization), so it is posted at most once.
+type Entry struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"`
+	Ref        string    `json:"ref"`
+	OrderID    string    `json:"order_id"`
+	CustomerID string    `json:"customer_id"`
+	Currency   string    `json:"currency"`
+	CreatedAt  time.Time `json:"created_at"`
+	Postings   []Posting `json:"postings"`
+}
+
+// Validate checks the double-entry invariant: at least two postings on known
+// accounts, none of them zero, summing to zero.
+func (e Entry) Validate() error {
+	if len(e.Postings) < 2 {
+		return fmt.Errorf("%w: %s %s has %d postings", ErrUnbalanced, e.Kind, e.Ref, len(e.Postings))
+	}
+	var sum int64
+	for _, p := range e.Postings {
+		if !accounts[p.Account] {
+			return fmt.Errorf("ledger entry %s %s: unknown account %q", e.Kind, e.Ref, p.Account)
+		}
+		if p.AmountCents == 0 {
+			return fmt.Errorf("ledger entry %s %s: zero posting on %s", e.Kind, e.Ref, p.Account)
+		}
+		sum += p.AmountCents
+	}
+	if sum != 0 {
+		return fmt.Errorf("%w: %s %s is off by %d", ErrUnbalanced, e.Kind, e.Ref, sum)
+	}
+	return nil
+}
+
+// entry is the entry of p with the given postings, zero ones left out.
+func entry(kind, ref string, p Payment, postings ...Posting) Entry {
+	e := Entry{Kind: kind, Ref: ref, OrderID: p.OrderID, Currency: p.Currency}
+	for _, ps := range postings {
+		if ps.AmountCents != 0 {
+			e.Postings = append(e.Postings, ps)
+		}
+	}
+	return e
+}
+
+// transfer is the entry debiting from and crediting to by amount.
+func transfer(kind, ref string, p Payment, amount int, debit, credit string) Entry {
+	return entry(kind, ref, p,
+		Posting{Account: debit, AmountCents: int64(amount)},
+		Posting{Account: credit, AmountCents: -int64(amount)})
+}
+
+func authorizationEntry(p Payment) Entry {
+	return transfer(EntryAuthorization, p.AuthorizationID, p, p.AmountCents, AccountAuthHolds, AccountAuthCommitments)
+}
+
+// captureEntry releases the hold and books the sale: the card's share against
+// the provider, the redeemed balances against the balance liability.
+func captureEntry(p Payment) Entry {
+	card, balances := int64(p.AmountCents), int64(p.BalanceCents)
+	return entry(EntryCapture, p.AuthorizationID, p,
+		Posting{Account: AccountAuthCommitments, AmountCents: card},
+		Posting{Account: AccountAuthHolds, AmountCents: -card},
+		Posting{Account: AccountPSPReceivable, AmountCents: card},
+		Posting{Account: AccountBalances, AmountCents: balances},
+		Posting{Account: AccountSales, AmountCents: -(card + balances)})
+}
+
+func feeEntry(p Payment, fee int) Entry {
+	return transfer(EntryFee, p.AuthorizationID, p, fee, AccountPSPFees, AccountPSPReceivable)
+}
+
+// releaseEntry drops the hold of a voided or expired authorization.
+func releaseEntry(kind string, p Payment) Entry {
+	return transfer(kind, p.AuthorizationID, p, p.AmountCents, AccountAuthCommitments, AccountAuthHolds)
+}
+
+// refundEntry books a refund: the card's share against the provider, the
+// share returned to gift cards and store credit against the balance
+// liability.
+func refundEntry(p Payment, f Refund) Entry {
+	return entry(EntryRefund, f.RefundID, p,
+		Posting{Account: AccountRefunds, AmountCents: int64(f.AmountCents)},
+		Posting{Account: AccountPSPReceivable, AmountCents: -int64(f.AmountCents - f.BalanceCents)},
+		Posting{Account: AccountBalances, AmountCents: -int64(f.BalanceCents)})
+}
+
+// FeeSchedule is the provider's fee on a capture: BasisPoints of the amount
+// (rounded half up) plus FixedCents, never more than the amount itself.
+type FeeSchedule struct {
+	BasisPoints int
+	FixedCents  int
+}
+
+func (f FeeSchedule) For(amountCents int) int {
+	if amountCents <= 0 {
+		return 0
+	}
+	fee := (amountCents*f.BasisPoints+5000)/10000 + f.FixedCents
+	return min(fee, amountCents)
+}
+
+// postTx writes e within tx unless an entry with the same kind and ref exists.
+// Entries that move no money (no postings left) are skipped. The customer is
+// taken from the order. A deferred trigger re-checks the balance at commit.
+func postTx(ctx context.Context, tx pgx.Tx, e Entry) error {
+	if len(e.Postings) == 0 {
+		return nil
+	}
+	if err := e.Validate(); err != nil {
+		return err
+	}
+
+	e.ID = uuid.NewString()
+	ct, err := tx.Exec(ctx, `
+		insert into ledger_entries (id, kind, ref, order_id, customer_id, currency)
+		values ($1::uuid, $2, $3, $4::uuid, coalesce((select user_id from orders where id = $4::uuid), ''), $5)
+		on conflict (kind, ref) do nothing
+	`, e.ID, e.Kind, e.Ref, e.OrderID, e.Currency)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return nil
+	}
+	for _, p := range e.Postings {
+		if _, err := tx.Exec(ctx, `
+			insert into ledger_postings (entry_id, account_code, amount_cents) values ($1::uuid, $2, $3)
+		`, e.ID, p.Account, p.AmountCents); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LedgerPG reads the ledger; entries are written by PaymentsPG together with
+// the payment change they record.
+type LedgerPG struct{ DB *pgxpool.Pool }
+
+// Scope selects the entries of one order or of one customer.
+type Scope struct {
+	OrderID    string
+	CustomerID string
+}
+
+func (s Scope) where() (string, any) {
+	if s.OrderID != "" {
+		return `e.order_id = $1::uuid`, s.OrderID
+	}
+	return `e.customer_id = $1`, s.CustomerID
+}
+
+// Balance is the net of an account's postings in one currency.
+type Balance struct {
+	Account     string `json:"account"`
+	Currency    string `json:"currency"`
+	AmountCents int64  `json:"amount_cents"`
+}
+
+func (r *LedgerPG) Balances(ctx context.Context, s Scope) ([]Balance, error) {
+	cond, arg := s.where()
+	rows, err := r.DB.Query(ctx, `
+		select p.account_code, e.currency, sum(p.amount_cents)::bigint
+		from ledger_postings p
+		join ledger_entries e on e.id = p.entry_id
+		where `+cond+`
+		group by p.account_code, e.currency
+		order by p.account_code, e.currency
+	`, arg)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Balance, error) {
+		var b Balance
+		err := row.Scan(&b.Account, &b.Currency, &b.AmountCents)
+		return b, err
+	})
+}
+
+// Statement lists entries with their postings, oldest first.
+func (r *LedgerPG) Statement(ctx context.Context, s Scope, limit int) ([]Entry, error) {
+	cond, arg := s.where()
+	rows, err := r.DB.Query(ctx, `
+		select e.id::text, e.kind, e.ref, e.order_id::text, e.customer_id, e.currency, e.created_at,
+		       p.account_code, p.amount_cents
+		from (select * from ledger_entries e where `+cond+` order by e.created_at, e.id limit $2) e
+		join ledger_postings p on p.entry_id = e.id
+		order by e.created_at, e.id, p.id
+	`, arg, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Entry{}
+	for rows.Next() {
+		var (
+			e Entry
+			p Posting
+		)
+		if err := rows.Scan(&e.ID, &e.Kind, &e.Ref, &e.OrderID, &e.CustomerID, &e.Currency, &e.CreatedAt,
+			&p.Account, &p.AmountCents); err != nil {
+			return nil, err
+		}
+		if n := len(out); n > 0 && out[n-1].ID == e.ID {
+			out[n-1].Postings = append(out[n-1].Postings, p)
+			continue
+		}
+		e.Postings = []Posting{p}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// DailyTotals are one currency's money movements over a UTC day, signed so
+// that all amounts are positive in the normal case. NetSettlementCents is the
+// change of psp_receivable: what the provider should pay out for the day.
+type DailyTotals struct {
+	Currency           string `json:"currency"`
+	Entries            int    `json:"entries"`
+	AuthorizedCents    int64  `json:"authorized_cents"`
+	CapturedCents      int64  `json:"captured_cents"`
+	RefundedCents      int64  `json:"refunded_cents"`
+	FeesCents          int64  `json:"fees_cents"`
+	NetSettlementCents int64  `json:"net_settlement_cents"`
+}
+
+// Daily sums the entries created on day (UTC) per currency.
+func (r *LedgerPG) Daily(ctx context.Context, day time.Time) ([]DailyTotals, error) {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	rows, err := r.DB.Query(ctx, `
+		select e.currency,
+		       count(distinct e.id)::int,
+		       coalesce(sum(p.amount_cents) filter (where e.kind = 'authorization' and p.account_code = $3), 0)::bigint,
+		       coalesce(-sum(p.amount_cents) filter (where p.account_code = $4), 0)::bigint,
+		       coalesce(sum(p.amount_cents) filter (where p.account_code = $5), 0)::bigint,
+		       coalesce(sum(p.amount_cents) filter (where p.account_code = $6), 0)::bigint,
+		       coalesce(sum(p.amount_cents) filter (where p.account_code = $7), 0)::bigint
+		from ledger_entries e
+		join ledger_postings p on p.entry_id = e.id
+		where e.created_at >= $1 and e.created_at < $2
+		group by e.currency
+		order by e.currency
+	`, from, from.AddDate(0, 0, 1), AccountAuthHolds, AccountSales, AccountRefunds, AccountPSPFees, AccountPSPReceivable)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (DailyTotals, error) {
+		var t DailyTotals
+		err := row.Scan(&t.Currency, &t.Entries, &t.AuthorizedCents, &t.CapturedCents, &t.RefundedCents, &t.FeesCents, &t.NetSettlementCents)
+		return t, err
+	})
+}
+
+// Unbalanced returns the IDs of entries whose postings do not sum to zero or
+// that have fewer than two postings. It is empty unless the ledger was
+// written around postTx.
+func (r *LedgerPG) Unbalanced(ctx context.Context) ([]string, error) {
+	rows, err := r.DB.Query(ctx, `
+		select e.id::text
+		from ledger_entries e
+		left join ledger_postings p on p.entry_id = e.id
+		group by e.id
+		having coalesce(sum(p.amount_cents), 0) <> 0 or count(p.id) < 2
+		order by e.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
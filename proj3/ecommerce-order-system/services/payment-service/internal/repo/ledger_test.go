@@ -0,0 +1,116 @@
+package repo
+
+import (
+	"errors"
+	"maps"
+	"testing"
+)
+
+func TestFeeScheduleFor(t *testing.T) {
+	tests := []struct {
+		name   string
+		fees   FeeSchedule
+		amount int
+		want   int
+	}{
+		{"percent and fixed", FeeSchedule{BasisPoints: 290, FixedCents: 30}, 10000, 320},
+		{"rounds half up", FeeSchedule{BasisPoints: 290}, 50, 1},
+		{"rounds down below half", FeeSchedule{BasisPoints: 290}, 17, 0},
+		{"fixed only", FeeSchedule{FixedCents: 30}, 1000, 30},
+		{"capped at amount", FeeSchedule{BasisPoints: 290, FixedCents: 30}, 20, 20},
+		{"no fees", FeeSchedule{}, 1000, 0},
+		{"zero amount", FeeSchedule{BasisPoints: 290, FixedCents: 30}, 0, 0},
+		{"negative amount", FeeSchedule{BasisPoints: 290, FixedCents: 30}, -500, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fees.For(tt.amount); got != tt.want {
+				t.Fatalf("For(%d) = %d, want %d", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntryValidate(t *testing.T) {
+	p := Payment{OrderID: "o1", AuthorizationID: "AUTH-1", AmountCents: 1000, Currency: "USD"}
+	split := Payment{OrderID: "o2", AuthorizationID: "AUTH-2", AmountCents: 700, BalanceCents: 300, Currency: "USD"}
+	balancesOnly := Payment{OrderID: "o3", AuthorizationID: "BAL-o3", BalanceCents: 1000, Currency: "USD"}
+	tests := []struct {
+		name       string
+		entry      Entry
+		ok         bool
+		unbalanced bool
+	}{
+		{"authorization", authorizationEntry(p), true, false},
+		{"capture", captureEntry(p), true, false},
+		{"fee", feeEntry(p, 59), true, false},
+		{"void", releaseEntry(EntryVoid, p), true, false},
+		{"refund card share", refundEntry(p, Refund{RefundID: "r1", AmountCents: 700, BalanceCents: 200}), true, false},
+		{"capture with balances", captureEntry(split), true, false},
+		{"capture paid by balances", captureEntry(balancesOnly), true, false},
+		{"refund to balances only", refundEntry(split, Refund{RefundID: "r2", AmountCents: 300, BalanceCents: 300}), true, false},
+		{"single posting", Entry{Postings: []Posting{{Account: AccountSales, AmountCents: 100}}}, false, true},
+		{"no postings", Entry{}, false, true},
+		{"off by one", Entry{Postings: []Posting{
+			{Account: AccountPSPReceivable, AmountCents: 100},
+			{Account: AccountSales, AmountCents: -99},
+		}}, false, true},
+		{"unknown account", Entry{Postings: []Posting{
+			{Account: AccountPSPReceivable, AmountCents: 100},
+			{Account: "cash", AmountCents: -100},
+		}}, false, false},
+		{"zero posting", Entry{Postings: []Posting{
+			{Account: AccountPSPReceivable, AmountCents: 100},
+			{Account: AccountSales, AmountCents: -100},
+			{Account: AccountRefunds, AmountCents: 0},
+		}}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.entry.Validate()
+			if tt.ok != (err == nil) {
+				t.Fatalf("Validate = %v, want ok %v", err, tt.ok)
+			}
+			if errors.Is(err, ErrUnbalanced) != tt.unbalanced {
+				t.Fatalf("Validate = %v, want ErrUnbalanced %v", err, tt.unbalanced)
+			}
+		})
+	}
+}
+
+func TestEntryPostings(t *testing.T) {
+	split := Payment{OrderID: "o2", AuthorizationID: "AUTH-2", AmountCents: 700, BalanceCents: 300, Currency: "USD"}
+	tests := []struct {
+		name  string
+		entry Entry
+		want  map[string]int64
+	}{
+		{"capture with balances", captureEntry(split), map[string]int64{
+			AccountAuthCommitments: 700, AccountAuthHolds: -700, AccountPSPReceivable: 700, AccountBalances: 300, AccountSales: -1000,
+		}},
+		{"capture paid by balances", captureEntry(Payment{AuthorizationID: "BAL-o3", BalanceCents: 1000}), map[string]int64{
+			AccountBalances: 1000, AccountSales: -1000,
+		}},
+		{"capture by card", captureEntry(Payment{AuthorizationID: "AUTH-1", AmountCents: 1000}), map[string]int64{
+			AccountAuthCommitments: 1000, AccountAuthHolds: -1000, AccountPSPReceivable: 1000, AccountSales: -1000,
+		}},
+		{"refund split", refundEntry(split, Refund{RefundID: "r1", AmountCents: 500, BalanceCents: 200}), map[string]int64{
+			AccountRefunds: 500, AccountPSPReceivable: -300, AccountBalances: -200,
+		}},
+		{"refund to card", refundEntry(split, Refund{RefundID: "r2", AmountCents: 500}), map[string]int64{
+			AccountRefunds: 500, AccountPSPReceivable: -500,
+		}},
+		{"nothing to refund", refundEntry(split, Refund{RefundID: "r3"}), map[string]int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[string]int64{}
+			for _, p := range tt.entry.Postings {
+				got[p.Account] += p.AmountCents
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Fatalf("postings = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
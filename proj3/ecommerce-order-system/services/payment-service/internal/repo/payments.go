@@ -0,0 +1,341 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+const (
+	// StatusPending and StatusRequiresAction wait for the provider's webhook
+	// (bank transfer, 3-D Secure); nothing is held until it confirms.
+	StatusPending        = "pending"
+	StatusRequiresAction = "requires_action"
+	StatusAuthorized     = "authorized"
+	StatusCaptured       = "captured"
+	StatusVoided         = "voided"
+	StatusExpired        = "expired"
+	// StatusDeclined: soft-declined (or the provider was unreachable); the
+	// customer may retry with another payment method until ExpiresAt.
+	StatusDeclined = "declined"
+	// StatusFailed: hard-declined, not confirmed in time, or not retried
+	// within the window; FailureReason says which.
+	StatusFailed = "failed"
+)
+
+var (
+	// ErrNotAuthorized: there is no live authorization to capture (none was
+	// made, or it was already voided or expired).
+	ErrNotAuthorized = errors.New("payment not authorized")
+	// ErrAuthorizationExpired: the authorization lapsed before capture; the
+	// payment is marked expired by the failed capture, and capturing it again
+	// returns the same error.
+	ErrAuthorizationExpired = errors.New("payment authorization expired")
+	// ErrNotRetryable: the payment is not declined, or its retry window
+	// has closed.
+	ErrNotRetryable = errors.New("payment cannot be retried")
+)
+
+// Payment is one row of the payments table. CorrelationID and Seq are the
+// links of the last event published for it (payment.pending or
+// payment.authorized), so events published later without a causing event
+// (webhook, expiry) stay in the order's chain. Until the provider confirms,
+// ExpiresAt is the confirmation deadline; while declined, the end of the
+// retry window.
+type Payment struct {
+	OrderID         string
+	AuthorizationID string
+	Status          string
+	AmountCents     int
+	Currency        string
+	ExpiresAt       time.Time
+	CorrelationID   string
+	Seq             int64
+	AuthorizedAt    time.Time
+	CapturedAt      *time.Time
+	VoidedAt        *time.Time
+	FailureReason   string
+	// ActionURL is where the customer confirms a requires_action payment.
+	ActionURL string
+	// Order is the order charged, passed on with payment.authorized; nil for
+	// payments made before it was kept.
+	Order *models.OrderSnapshotPayload
+	// BalanceCents was redeemed from gift cards and store credit; AmountCents
+	// is what the card pays. Redemptions are reversed when the payment fails,
+	// is voided or expires.
+	BalanceCents int
+}
+
+// PaymentsPG stores payments and books every change in the ledger in the
+// same transaction.
+type PaymentsPG struct {
+	DB   *pgxpool.Pool
+	Fees FeeSchedule
+}
+
+const paymentColumns = `
+	order_id::text, coalesce(authorization_id, ''), status, amount_cents, currency, expires_at,
+	coalesce(correlation_id, ''), seq, authorized_at, captured_at, voided_at, coalesce(failure_reason, ''),
+	coalesce(action_url, ''), order_snapshot, balance_cents`
+
+// Authorize records p, authorized unless p.Status says otherwise (waiting
+// for the provider, declined or failed). An order has at most one payment:
+// if it already has one (redelivery), that payment is returned unchanged.
+func (r *PaymentsPG) Authorize(ctx context.Context, p Payment) (Payment, error) {
+	if p.Status == "" {
+		p.Status = StatusAuthorized
+	}
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return Payment{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	ct, err := tx.Exec(ctx, `
+		insert into payments (order_id, authorization_id, status, amount_cents, currency, expires_at, correlation_id, seq,
+		                      action_url, failure_reason, order_snapshot, balance_cents)
+		values ($1::uuid, nullif($2, ''), $3, $4, $5, $6, nullif($7, ''), $8, nullif($9, ''), nullif($10, ''), $11, $12)
+		on conflict (order_id) do nothing
+	`, p.OrderID, p.AuthorizationID, p.Status, p.AmountCents, p.Currency, p.ExpiresAt, p.CorrelationID, p.Seq,
+		p.ActionURL, p.FailureReason, p.Order, p.BalanceCents)
+	if err != nil {
+		return Payment{}, err
+	}
+	stored, err := scanPayment(tx.QueryRow(ctx, `select `+paymentColumns+` from payments where order_id = $1::uuid`, p.OrderID))
+	if err != nil {
+		return Payment{}, err
+	}
+	if ct.RowsAffected() == 1 && stored.Status == StatusAuthorized {
+		if err := postTx(ctx, tx, authorizationEntry(stored)); err != nil {
+			return Payment{}, err
+		}
+	}
+	if ct.RowsAffected() == 1 && stored.Status == StatusFailed {
+		if _, err := reverseOrderTx(ctx, tx, stored.OrderID, stored.FailureReason); err != nil {
+			return Payment{}, err
+		}
+	}
+	return stored, tx.Commit(ctx)
+}
+
+// Get returns the payment of an order; pgx.ErrNoRows if it has none.
+func (r *PaymentsPG) Get(ctx context.Context, orderID string) (Payment, error) {
+	return scanPayment(r.DB.QueryRow(ctx, `select `+paymentColumns+` from payments where order_id = $1::uuid`, orderID))
+}
+
+// OrderTotal reads an order's total as stored with the order; pgx.ErrNoRows
+// if there is no such order.
+func (r *PaymentsPG) OrderTotal(ctx context.Context, orderID string) (totalCents int, currency string, err error) {
+	err = r.DB.QueryRow(ctx, `select total_cents, currency from orders where id = $1::uuid`, orderID).Scan(&totalCents, &currency)
+	return totalCents, currency, err
+}
+
+// reasonExpiredAtCapture marks a payment expired by Capture rather than by
+// the sweep, which has announced the expiry itself.
+const reasonExpiredAtCapture = "authorization expired at capture"
+
+// Capture takes the authorized amount. Capturing a captured payment returns
+// it unchanged, and one that expired when it was captured returns
+// ErrAuthorizationExpired again, so a redelivered capture reports the
+// failure even if it was not published the first time.
+func (r *PaymentsPG) Capture(ctx context.Context, orderID string) (Payment, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return Payment{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	p, err := scanPayment(tx.QueryRow(ctx, `select `+paymentColumns+` from payments where order_id = $1::uuid for update`, orderID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Payment{}, ErrNotAuthorized
+	}
+	if err != nil {
+		return Payment{}, err
+	}
+
+	switch {
+	case p.Status == StatusCaptured:
+		return p, nil
+	case p.Status == StatusExpired && p.FailureReason == reasonExpiredAtCapture:
+		return p, ErrAuthorizationExpired
+	case p.Status != StatusAuthorized:
+		return p, ErrNotAuthorized
+	}
+
+	if !p.ExpiresAt.After(time.Now()) {
+		if _, err := tx.Exec(ctx, `
+			update payments set status = $2, failure_reason = $3, updated_at = now() where order_id = $1::uuid
+		`, orderID, StatusExpired, reasonExpiredAtCapture); err != nil {
+			return Payment{}, err
+		}
+		p.Status, p.FailureReason = StatusExpired, reasonExpiredAtCapture
+		if err := expireTx(ctx, tx, p); err != nil {
+			return Payment{}, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return Payment{}, err
+		}
+		return p, ErrAuthorizationExpired
+	}
+
+	now := time.Now()
+	if _, err := tx.Exec(ctx, `
+		update payments set status = $2, captured_at = $3, updated_at = now() where order_id = $1::uuid
+	`, orderID, StatusCaptured, now); err != nil {
+		return Payment{}, err
+	}
+	p.Status, p.CapturedAt = StatusCaptured, &now
+	if err := postTx(ctx, tx, captureEntry(p)); err != nil {
+		return Payment{}, err
+	}
+	if err := postTx(ctx, tx, feeEntry(p, r.Fees.For(p.AmountCents))); err != nil {
+		return Payment{}, err
+	}
+	return p, tx.Commit(ctx)
+}
+
+// Void releases an authorization, or drops one still waiting for the
+// provider so a late confirmation is ignored, and returns the balances
+// redeemed for the order; reversed is how much. Payments in any other status
+// are returned unchanged; pgx.ErrNoRows means the order has no payment
+// (balances redeemed before the payment was stored are returned all the same).
+func (r *PaymentsPG) Void(ctx context.Context, orderID string) (p Payment, reversed int, err error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return Payment{}, 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	p, err = scanPayment(tx.QueryRow(ctx, `select `+paymentColumns+` from payments where order_id = $1::uuid for update`, orderID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		if reversed, err = reverseOrderTx(ctx, tx, orderID, "order cancelled"); err != nil {
+			return Payment{}, 0, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return Payment{}, 0, err
+		}
+		return Payment{}, reversed, pgx.ErrNoRows
+	}
+	if err != nil {
+		return Payment{}, 0, err
+	}
+	held := p.Status == StatusAuthorized
+	if !held && !p.Open() {
+		return p, 0, nil
+	}
+
+	now := time.Now()
+	if _, err := tx.Exec(ctx, `
+		update payments set status = $2, voided_at = $3, updated_at = now() where order_id = $1::uuid
+	`, orderID, StatusVoided, now); err != nil {
+		return Payment{}, 0, err
+	}
+	p.Status, p.VoidedAt = StatusVoided, &now
+	if held {
+		if err := postTx(ctx, tx, releaseEntry(EntryVoid, p)); err != nil {
+			return Payment{}, 0, err
+		}
+	}
+	if reversed, err = reverseOrderTx(ctx, tx, orderID, "order cancelled"); err != nil {
+		return Payment{}, 0, err
+	}
+	return p, reversed, tx.Commit(ctx)
+}
+
+// ExpireOne marks one authorization past its expiry as expired, or one the
+// provider did not confirm in time or the customer did not retry in time as
+// failed, and calls fn before committing, so a failed publish leaves it for
+// the next sweep. Rows locked by another replica are skipped. Reports
+// whether one was found.
+func (r *PaymentsPG) ExpireOne(ctx context.Context, fn func(Payment) error) (bool, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	p, err := scanPayment(tx.QueryRow(ctx, `
+		select `+paymentColumns+`
+		from payments
+		where status in ('authorized', 'pending', 'requires_action', 'declined')
+		  and expires_at <= now()
+		order by expires_at
+		limit 1
+		for update skip locked
+	`))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case p.Status == StatusDeclined:
+		if err := failTx(ctx, tx, &p, "not retried in time after: "+p.FailureReason); err != nil {
+			return false, err
+		}
+	case p.Unconfirmed():
+		if err := failTx(ctx, tx, &p, "provider did not confirm the payment in time"); err != nil {
+			return false, err
+		}
+	default:
+		if _, err := tx.Exec(ctx, `update payments set status = $2, updated_at = now() where order_id = $1::uuid`, p.OrderID, StatusExpired); err != nil {
+			return false, err
+		}
+		p.Status = StatusExpired
+		if err := expireTx(ctx, tx, p); err != nil {
+			return false, err
+		}
+	}
+	if err := fn(p); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+// Unconfirmed reports whether p still waits for the provider's webhook.
+func (p Payment) Unconfirmed() bool {
+	return p.Status == StatusPending || p.Status == StatusRequiresAction
+}
+
+// Open reports whether p holds nothing yet but may still: unconfirmed, or
+// declined within its retry window.
+func (p Payment) Open() bool {
+	return p.Unconfirmed() || p.Status == StatusDeclined
+}
+
+// failTx marks p failed and returns the balances redeemed for it.
+func failTx(ctx context.Context, tx pgx.Tx, p *Payment, reason string) error {
+	if _, err := tx.Exec(ctx, `
+		update payments set status = $2, failure_reason = $3, updated_at = now() where order_id = $1::uuid
+	`, p.OrderID, StatusFailed, reason); err != nil {
+		return err
+	}
+	p.Status, p.FailureReason = StatusFailed, reason
+	_, err := reverseOrderTx(ctx, tx, p.OrderID, reason)
+	return err
+}
+
+// expireTx books the release of an expired authorization and returns the
+// balances redeemed for it; the caller has set the status.
+func expireTx(ctx context.Context, tx pgx.Tx, p Payment) error {
+	if err := postTx(ctx, tx, releaseEntry(EntryExpiry, p)); err != nil {
+		return err
+	}
+	_, err := reverseOrderTx(ctx, tx, p.OrderID, "authorization expired")
+	return err
+}
+
+func scanPayment(row pgx.Row) (Payment, error) {
+	var p Payment
+	err := row.Scan(&p.OrderID, &p.AuthorizationID, &p.Status, &p.AmountCents, &p.Currency, &p.ExpiresAt,
+		&p.CorrelationID, &p.Seq, &p.AuthorizedAt, &p.CapturedAt, &p.VoidedAt, &p.FailureReason,
+		&p.ActionURL, &p.Order, &p.BalanceCents)
+	return p, err
+}
@@ -0,0 +1,162 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	RefundStatusRefunded = "refunded"
+	RefundStatusFailed   = "failed"
+)
+
+// Refund is one row of the refunds table. AmountCents 0 in a request means
+// the whole remaining paid amount; the stored row has the actual amount.
+// BalanceCents of it went back to gift cards and store credit.
+type Refund struct {
+	RefundID      string
+	OrderID       string
+	Status        string
+	AmountCents   int
+	BalanceCents  int
+	Reason        string
+	FailureReason string
+	Items         json.RawMessage
+	CreatedAt     time.Time
+
+	// RefundedTotalCents and Currency describe the payment after this refund.
+	RefundedTotalCents int
+	Currency           string
+}
+
+// Refund records a refund of a captured payment. Requests for the same order
+// are serialized on the payment row lock, so the refunded total never exceeds
+// what was paid (card and balances) however many arrive at once. The card is
+// refunded first; what it did not pay goes back to the gift cards and store
+// credit redeemed, newest first. A refund that cannot be made is stored as
+// failed with the reason; the card part of one that is made is booked in the
+// ledger. Refund IDs are idempotent: a redelivered request returns the
+// stored result.
+func (r *PaymentsPG) Refund(ctx context.Context, req Refund) (Refund, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return Refund{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	p, err := scanPayment(tx.QueryRow(ctx, `select `+paymentColumns+` from payments where order_id = $1::uuid for update`, req.OrderID))
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return Refund{}, err
+	}
+	captured := err == nil && p.Status == StatusCaptured
+
+	if existing, err := getRefund(ctx, tx, req.RefundID); err == nil {
+		return withTotals(ctx, tx, existing, p)
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return Refund{}, err
+	}
+
+	refunded, err := refundedTotal(ctx, tx, req.OrderID)
+	if err != nil {
+		return Refund{}, err
+	}
+	remaining := p.AmountCents + p.BalanceCents - refunded
+
+	req.Status = RefundStatusRefunded
+	switch {
+	case !captured:
+		req.Status, req.FailureReason = RefundStatusFailed, "payment not captured"
+	case remaining <= 0:
+		req.Status, req.FailureReason = RefundStatusFailed, "payment already fully refunded"
+	case req.AmountCents > remaining:
+		req.Status, req.FailureReason = RefundStatusFailed, "refund exceeds remaining paid amount"
+	case req.AmountCents == 0:
+		req.AmountCents = remaining
+	}
+	if len(req.Items) == 0 {
+		req.Items = json.RawMessage(`[]`)
+	}
+	if req.Status == RefundStatusRefunded {
+		card, err := refundedCardTotal(ctx, tx, req.OrderID)
+		if err != nil {
+			return Refund{}, err
+		}
+		if _, rest := splitRefund(req.AmountCents, p.AmountCents, card); rest > 0 {
+			if req.BalanceCents, err = refundBalancesTx(ctx, tx, req.OrderID, req.RefundID, rest); err != nil {
+				return Refund{}, err
+			}
+			if req.BalanceCents != rest {
+				return Refund{}, fmt.Errorf("refund %s: %d of %d left in redeemed balances", req.RefundID, req.BalanceCents, rest)
+			}
+		}
+	}
+
+	if err := tx.QueryRow(ctx, `
+		insert into refunds (refund_id, order_id, status, amount_cents, balance_cents, reason, failure_reason, items)
+		values ($1::uuid, $2::uuid, $3, $4, $5, nullif($6, ''), nullif($7, ''), $8)
+		returning created_at
+	`, req.RefundID, req.OrderID, req.Status, req.AmountCents, req.BalanceCents, req.Reason, req.FailureReason, req.Items).Scan(&req.CreatedAt); err != nil {
+		return Refund{}, err
+	}
+	if req.Status == RefundStatusRefunded {
+		if err := postTx(ctx, tx, refundEntry(p, req)); err != nil {
+			return Refund{}, err
+		}
+	}
+
+	out, err := withTotals(ctx, tx, req, p)
+	if err != nil {
+		return Refund{}, err
+	}
+	return out, tx.Commit(ctx)
+}
+
+// splitRefund splits a refund of amount between the card, which paid
+// cardPaid and got cardRefunded of it back already, and the balances: the
+// card first, up to what it has left.
+func splitRefund(amount, cardPaid, cardRefunded int) (card, balances int) {
+	card = min(amount, max(cardPaid-cardRefunded, 0))
+	return card, amount - card
+}
+
+func withTotals(ctx context.Context, tx pgx.Tx, f Refund, p Payment) (Refund, error) {
+	total, err := refundedTotal(ctx, tx, f.OrderID)
+	if err != nil {
+		return Refund{}, err
+	}
+	f.RefundedTotalCents, f.Currency = total, p.Currency
+	return f, nil
+}
+
+func refundedTotal(ctx context.Context, tx pgx.Tx, orderID string) (int, error) {
+	var total int
+	err := tx.QueryRow(ctx, `
+		select coalesce(sum(amount_cents), 0) from refunds where order_id = $1::uuid and status = $2
+	`, orderID, RefundStatusRefunded).Scan(&total)
+	return total, err
+}
+
+// refundedCardTotal is the part of the order's refunds paid back to the card.
+func refundedCardTotal(ctx context.Context, tx pgx.Tx, orderID string) (int, error) {
+	var total int
+	err := tx.QueryRow(ctx, `
+		select coalesce(sum(amount_cents - balance_cents), 0) from refunds where order_id = $1::uuid and status = $2
+	`, orderID, RefundStatusRefunded).Scan(&total)
+	return total, err
+}
+
+func getRefund(ctx context.Context, tx pgx.Tx, refundID string) (Refund, error) {
+	var f Refund
+	err := tx.QueryRow(ctx, `
+		select refund_id::text, order_id::text, status, amount_cents, balance_cents, coalesce(reason, ''),
+		       coalesce(failure_reason, ''), items, created_at
+		from refunds
+		where refund_id = $1::uuid
+	`, refundID).Scan(&f.RefundID, &f.OrderID, &f.Status, &f.AmountCents, &f.BalanceCents, &f.Reason, &f.FailureReason, &f.Items, &f.CreatedAt)
+	return f, err
+}
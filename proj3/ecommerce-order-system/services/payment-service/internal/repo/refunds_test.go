@@ -0,0 +1,28 @@
+package repo
+
+import "testing"
+
+func TestSplitRefund(t *testing.T) {
+	tests := []struct {
+		name                           string
+		amount, cardPaid, cardRefunded int
+		wantCard, wantBalances         int
+	}{
+		{"card only", 500, 1000, 0, 500, 0},
+		{"whole card", 1000, 1000, 0, 1000, 0},
+		{"card then balances", 1200, 1000, 0, 1000, 200},
+		{"card partly refunded", 800, 1000, 600, 400, 400},
+		{"card fully refunded", 300, 1000, 1000, 0, 300},
+		{"balances only", 700, 0, 0, 0, 700},
+		{"nothing", 0, 1000, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card, balances := splitRefund(tt.amount, tt.cardPaid, tt.cardRefunded)
+			if card != tt.wantCard || balances != tt.wantBalances {
+				t.Fatalf("splitRefund(%d, %d, %d) = %d, %d, want %d, %d",
+					tt.amount, tt.cardPaid, tt.cardRefunded, card, balances, tt.wantCard, tt.wantBalances)
+			}
+		})
+	}
+}
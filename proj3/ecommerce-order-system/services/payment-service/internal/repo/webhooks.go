@@ -0,0 +1,97 @@
+package repo
+
+import (
+	"context"
+	"time"
+)
+
+// Webhook results, stored with each applied webhook.
+const (
+	WebhookAuthorized = "authorized"
+	WebhookFailed     = "failed"
+	// WebhookIgnored: the payment no longer waited for the provider (voided,
+	// failed by the confirmation deadline, or already confirmed).
+	WebhookIgnored = "ignored"
+	// WebhookDuplicate: this webhook id was applied before.
+	WebhookDuplicate = "duplicate"
+)
+
+// Confirmation is a provider's decision on an authorization it left pending.
+type Confirmation struct {
+	EventID   string
+	Type      string
+	Reference string
+	Succeeded bool
+	Reason    string
+}
+
+// Confirm applies c to the payment with authorization id c.Reference. A
+// success authorizes it for authTTL from now and books the hold; a failure
+// marks it failed. fn is called with the changed payment before committing,
+// so a failed publish makes the provider retry the webhook. Every webhook id
+// is applied once; pgx.ErrNoRows means no payment has that reference (yet).
+func (r *PaymentsPG) Confirm(ctx context.Context, c Confirmation, authTTL time.Duration, fn func(Payment) error) (Payment, string, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return Payment{}, "", err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var seen bool
+	if err := tx.QueryRow(ctx, `select exists(select 1 from psp_webhook_events where event_id = $1)`, c.EventID).Scan(&seen); err != nil {
+		return Payment{}, "", err
+	}
+	if seen {
+		return Payment{}, WebhookDuplicate, nil
+	}
+
+	p, err := scanPayment(tx.QueryRow(ctx, `select `+paymentColumns+` from payments where authorization_id = $1 for update`, c.Reference))
+	if err != nil {
+		return Payment{}, "", err
+	}
+
+	result := WebhookIgnored
+	switch {
+	case !p.Unconfirmed():
+	case c.Succeeded:
+		now := time.Now()
+		p.Status, p.AuthorizedAt, p.ExpiresAt, p.Seq = StatusAuthorized, now, now.Add(authTTL), p.Seq+1
+		if _, err := tx.Exec(ctx, `
+			update payments set status = $2, authorized_at = $3, expires_at = $4, seq = $5, updated_at = now()
+			where order_id = $1::uuid
+		`, p.OrderID, p.Status, p.AuthorizedAt, p.ExpiresAt, p.Seq); err != nil {
+			return Payment{}, "", err
+		}
+		if err := postTx(ctx, tx, authorizationEntry(p)); err != nil {
+			return Payment{}, "", err
+		}
+		result = WebhookAuthorized
+	default:
+		reason := c.Reason
+		if reason == "" {
+			reason = "declined by provider"
+		}
+		if err := failTx(ctx, tx, &p, reason); err != nil {
+			return Payment{}, "", err
+		}
+		result = WebhookFailed
+	}
+
+	ct, err := tx.Exec(ctx, `
+		insert into psp_webhook_events (event_id, type, reference, result) values ($1, $2, $3, $4)
+		on conflict (event_id) do nothing
+	`, c.EventID, c.Type, c.Reference, result)
+	if err != nil {
+		return Payment{}, "", err
+	}
+	if ct.RowsAffected() == 0 {
+		// applied concurrently by another delivery
+		return Payment{}, WebhookDuplicate, nil
+	}
+	if result != WebhookIgnored {
+		if err := fn(p); err != nil {
+			return Payment{}, "", err
+		}
+	}
+	return p, result, tx.Commit(ctx)
+}
@@ -0,0 +1,175 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"ecommerce-order-system/services/payment-service/internal/provider"
+	"ecommerce-order-system/services/payment-service/internal/repo"
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+// RetryPolicy governs the calls within one attempt. Each call gets Timeout;
+// a transient error or timeout sends the message back through the retry
+// queue and the redelivery calls again, up to MaxTries calls.
+type RetryPolicy struct {
+	Timeout  time.Duration
+	MaxTries int
+}
+
+// errProviderUnavailable is returned for a call worth trying again; the
+// message goes back through the retry queue.
+var errProviderUnavailable = errors.New("provider unavailable")
+
+// attemptResult is the last call of an attempt.
+type attemptResult struct {
+	auth    provider.Authorization
+	outcome string
+	reason  string
+}
+
+// payment is the state an attempt leaves the payment in. A provider still
+// failing after every try counts as a soft decline: the customer may retry.
+func (r attemptResult) payment(authTTL, pendingTTL time.Duration) repo.Payment {
+	p := repo.Payment{AuthorizationID: r.auth.Reference, FailureReason: r.reason}
+	now := time.Now()
+	switch r.outcome {
+	case provider.OutcomeApproved:
+		p.Status, p.ExpiresAt, p.FailureReason = repo.StatusAuthorized, now.Add(authTTL), ""
+	case provider.OutcomeRequiresAction, provider.OutcomePending:
+		p.Status, p.ExpiresAt, p.ActionURL, p.FailureReason = r.auth.Status, now.Add(pendingTTL), r.auth.ActionURL, ""
+	case provider.OutcomeHardDecline:
+		p.Status, p.ExpiresAt = repo.StatusFailed, now
+	default:
+		p.Status = repo.StatusDeclined
+	}
+	return p
+}
+
+// attempt makes one call of a payment attempt. The call is recorded as
+// started before it goes out and sends key, so a call whose answer was lost
+// (a timeout, or a crash before the payment was stored) is repeated under
+// the same key on redelivery and the provider does not authorize twice. A
+// transient error or timeout returns errProviderUnavailable until the
+// attempt has had MaxTries calls. requestID is the retry request behind it,
+// empty for the first one.
+func (c *Consumer) attempt(ctx context.Context, orderID, requestID, key string, req provider.AuthorizeRequest) (attemptResult, error) {
+	req.IdempotencyKey = key
+	call, err := c.Payments.StartAttempt(ctx, repo.Attempt{
+		OrderID:        orderID,
+		RequestID:      requestID,
+		PaymentMethod:  req.PaymentMethod,
+		IdempotencyKey: key,
+	})
+	if err != nil {
+		return attemptResult{}, err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, c.Retry.Timeout)
+	start := time.Now()
+	a, err := c.Provider.Authorize(callCtx, req)
+	cancel()
+
+	res := attemptResult{auth: a, outcome: provider.Classify(a, err), reason: a.Reason}
+	if err != nil {
+		res.reason = err.Error()
+	}
+	call.Outcome, call.Reason, call.Reference = res.outcome, res.reason, a.Reference
+	call.DurationMS = time.Since(start).Milliseconds()
+	if err := c.Payments.FinishAttempt(ctx, call); err != nil {
+		return attemptResult{}, err
+	}
+
+	retryable := res.outcome == provider.OutcomeTransient || res.outcome == provider.OutcomeTimeout
+	if retryable && call.Try < c.Retry.MaxTries {
+		return attemptResult{}, fmt.Errorf("%w: attempt %d, try %d: %s", errProviderUnavailable, call.Attempt, call.Try, res.reason)
+	}
+	return res, nil
+}
+
+// authorizationKey is the idempotency key of an order's first authorization.
+func authorizationKey(orderID string) string {
+	return "auth:" + orderID
+}
+
+// retryKey is the idempotency key of the attempt a retry request starts.
+func retryKey(requestID string) string {
+	return "retry:" + requestID
+}
+
+// retry authorizes a declined payment again with the payment method the
+// customer chose. It is ignored once the payment went on or its window
+// closed. A redelivered request goes by what its calls left: a soft decline
+// changed nothing and an answer the payment already took is announced
+// again; otherwise (no answer yet, or one the payment did not get to store)
+// the call is repeated under the request's key.
+func (c *Consumer) retry(ctx context.Context, evt models.Event[json.RawMessage]) (string, error) {
+	var req models.PaymentRetryRequestedPayload
+	if err := json.Unmarshal(evt.Payload, &req); err != nil {
+		return "", fmt.Errorf("payment.retry_requested payload: %w", err)
+	}
+
+	p, err := c.Payments.Get(ctx, evt.OrderID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "no payment to retry", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	last, err := c.Payments.LastRequestAttempt(ctx, req.RequestID)
+	seen := err == nil
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", err
+	}
+	open := p.Status == repo.StatusDeclined && p.ExpiresAt.After(time.Now())
+	switch {
+	case seen && (c.softDeclined(last) || !open):
+		return c.announce(c.chain(ctx, p), p)
+	case !open:
+		return "payment " + p.Status + ", retry ignored", nil
+	}
+
+	res, err := c.attempt(ctx, evt.OrderID, req.RequestID, retryKey(req.RequestID), provider.AuthorizeRequest{
+		OrderID:       evt.OrderID,
+		AmountCents:   p.AmountCents,
+		Currency:      p.Currency,
+		PaymentMethod: req.PaymentMethod,
+	})
+	if err != nil {
+		return "", err
+	}
+	next := res.payment(c.AuthTTL, c.PendingTTL)
+	next.OrderID = evt.OrderID
+	p, err = c.Payments.Reattempt(ctx, next, c.AuthTTL, c.PendingTTL)
+	if errors.Is(err, repo.ErrNotRetryable) {
+		return "payment " + p.Status + ", retry ignored", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return c.announce(c.chain(ctx, p), p)
+}
+
+// softDeclined reports whether a recorded call ended its attempt in a soft
+// decline: declined softly, or the provider still failing on the last try.
+func (c *Consumer) softDeclined(a repo.Attempt) bool {
+	switch a.Outcome {
+	case provider.OutcomeSoftDecline:
+		return true
+	case provider.OutcomeTransient, provider.OutcomeTimeout:
+		return a.Try >= c.Retry.MaxTries
+	}
+	return false
+}
+
+// chain puts events published for p after a retry in the order's chain:
+// Reattempt moved p.Seq on to the event about to go out, caused by evt.
+func (c *Consumer) chain(ctx context.Context, p repo.Payment) context.Context {
+	links, _ := models.LinksFromContext(ctx)
+	return models.ContextWithLinks(ctx, models.Links{CorrelationID: p.CorrelationID, CausationID: links.CausationID, Seq: p.Seq - 1})
+}
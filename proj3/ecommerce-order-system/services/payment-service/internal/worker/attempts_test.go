@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"ecommerce-order-system/services/payment-service/internal/provider"
+	"ecommerce-order-system/services/payment-service/internal/repo"
+)
+
+func TestSoftDeclined(t *testing.T) {
+	c := &Consumer{Retry: RetryPolicy{MaxTries: 3}}
+	tests := []struct {
+		outcome string
+		try     int
+		want    bool
+	}{
+		{provider.OutcomeSoftDecline, 1, true},
+		{provider.OutcomeTransient, 2, false},
+		{provider.OutcomeTransient, 3, true},
+		{provider.OutcomeTimeout, 1, false},
+		{provider.OutcomeTimeout, 3, true},
+		{provider.OutcomeApproved, 3, false},
+		{provider.OutcomeHardDecline, 1, false},
+		{repo.AttemptStarted, 3, false},
+	}
+	for _, tt := range tests {
+		if got := c.softDeclined(repo.Attempt{Outcome: tt.outcome, Try: tt.try}); got != tt.want {
+			t.Errorf("softDeclined(%s, try %d) = %v, want %v", tt.outcome, tt.try, got, tt.want)
+		}
+	}
+}
+
+func TestAttemptResultPayment(t *testing.T) {
+	tests := []struct {
+		outcome    string
+		auth       provider.Authorization
+		wantStatus string
+	}{
+		{provider.OutcomeApproved, provider.Authorization{Status: provider.StatusApproved}, repo.StatusAuthorized},
+		{provider.OutcomeRequiresAction, provider.Authorization{Status: provider.StatusRequiresAction}, provider.StatusRequiresAction},
+		{provider.OutcomePending, provider.Authorization{Status: provider.StatusPending}, provider.StatusPending},
+		{provider.OutcomeHardDecline, provider.Authorization{Status: provider.StatusDeclined}, repo.StatusFailed},
+		{provider.OutcomeSoftDecline, provider.Authorization{Status: provider.StatusDeclined}, repo.StatusDeclined},
+		{provider.OutcomeTransient, provider.Authorization{}, repo.StatusDeclined},
+		{provider.OutcomeTimeout, provider.Authorization{}, repo.StatusDeclined},
+	}
+	for _, tt := range tests {
+		p := attemptResult{auth: tt.auth, outcome: tt.outcome, reason: "r"}.payment(time.Hour, time.Minute)
+		if p.Status != tt.wantStatus {
+			t.Errorf("%s: status %q, want %q", tt.outcome, p.Status, tt.wantStatus)
+		}
+	}
+}
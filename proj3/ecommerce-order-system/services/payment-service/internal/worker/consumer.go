@@ -3,16 +3,19 @@ package worker
 import (
 	"context"
 	"encoding/json"
-	"math/rand"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/rs/zerolog"
 
+	"ecommerce-order-system/services/payment-service/internal/provider"
+	"ecommerce-order-system/services/payment-service/internal/repo"
+	"ecommerce-order-system/shared/pkg/logger"
 	"ecommerce-order-system/shared/pkg/models"
 	"ecommerce-order-system/shared/pkg/rabbit"
-
-	"github.com/google/uuid"
 )
 
 type Consumer struct {
@@ -22,33 +25,31 @@ type Consumer struct {
 	RetryPub  *rabbit.Publisher
 	DLQPub    *rabbit.Publisher
 
+	// Events declares the payload versions this consumer understands.
+	Events models.Decoder
+
+	Payments *repo.PaymentsPG
+	Balances *repo.BalancesPG
+	Provider provider.Provider
+	Retry    RetryPolicy
+
 	Service     string
 	MaxAttempts int
 	DLQKey      string
 
-	FailRate int // 0..100
-}
-
-type PaymentProcessedPayload struct {
-	Note string `json:"note,omitempty"`
-}
-
-type PaymentFailedPayload struct {
-	Reason string `json:"reason"`
-}
-
-type InventoryReleaseRequestedPayload struct {
-	Reason string `json:"reason"`
-}
-
-type OrderCancelledPayload struct {
-	Reason string `json:"reason"`
+	// AuthTTL is how long an authorization stays capturable; PendingTTL how
+	// long the provider has to confirm one it left pending; RetryWindow how
+	// long the customer has to retry a declined one.
+	AuthTTL     time.Duration
+	PendingTTL  time.Duration
+	RetryWindow time.Duration
+	// Choreography says whether failures must release stock and cancel the
+	// order themselves (no saga).
+	Choreography bool
 }
 
 func (c *Consumer) Run(ctx context.Context, deliveries <-chan amqp.Delivery) {
 	c.Log.Info().Msg("payment consumer started")
-	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
-
 	for {
 		select {
 		case <-ctx.Done():
@@ -59,15 +60,20 @@ func (c *Consumer) Run(ctx context.Context, deliveries <-chan amqp.Delivery) {
 				c.Log.Info().Msg("deliveries closed")
 				return
 			}
-			c.handle(ctx, rng, d)
+			c.handle(ctx, d)
 		}
 	}
 }
 
-func (c *Consumer) handle(ctx context.Context, rng *rand.Rand, d amqp.Delivery) {
-	var evt models.Event[json.RawMessage]
-	if err := json.Unmarshal(d.Body, &evt); err != nil {
-		c.Log.Error().Err(err).Str("rk", d.RoutingKey).Msg("bad json -> dlq")
+func (c *Consumer) handle(ctx context.Context, d amqp.Delivery) {
+	evt, err := rabbit.DecodeDelivery(c.Events, d)
+	if errors.Is(err, models.ErrSkipped) {
+		_ = d.Ack(false)
+		c.Log.Debug().Str("rk", d.RoutingKey).Int("version", evt.Version).Msg("dual-published copy skipped")
+		return
+	}
+	if err != nil {
+		c.Log.Error().Err(err).Str("rk", d.RoutingKey).Msg("invalid event -> dlq")
 		_ = rabbit.RetryOrDLQ(ctx, d, c.Service, 0, c.RetryPub, c.DLQPub, c.DLQKey)
 		return
 	}
@@ -77,91 +83,308 @@ func (c *Consumer) handle(ctx context.Context, rng *rand.Rand, d amqp.Delivery)
 		return
 	}
 
-	if d.RoutingKey != "inventory.reserved" {
-		c.Log.Warn().Str("rk", d.RoutingKey).Str("order_id", evt.OrderID).Msg("unexpected routing key -> ack")
+	ctx = models.ContextWithEvent(ctx, evt)
+	log := logger.ForEvent(c.Log, evt)
+
+	var msg string
+	switch d.RoutingKey {
+	case models.TypeFraudPassed, models.CmdPaymentAuthorize:
+		msg, err = c.authorize(ctx, d.RoutingKey == models.CmdPaymentAuthorize, evt)
+	case models.TypeShippingScheduled, models.CmdPaymentCapture:
+		msg, err = c.capture(ctx, d.RoutingKey == models.CmdPaymentCapture, evt)
+	case models.TypeOrderCancelled, models.CmdPaymentVoid:
+		msg, err = c.void(ctx, d.RoutingKey == models.CmdPaymentVoid, evt)
+	case models.TypePaymentRetryRequested:
+		msg, err = c.retry(ctx, evt)
+	case models.TypePaymentRefundRequested:
+		msg, err = c.refund(ctx, evt)
+	default:
+		log.Warn().Str("rk", d.RoutingKey).Msg("unexpected routing key -> ack")
 		_ = d.Ack(false)
 		return
 	}
+	if err != nil {
+		log.Error().Err(err).Msg("payment step failed -> retry/dlq")
+		_ = rabbit.RetryOrDLQ(ctx, d, c.Service, int32(c.MaxAttempts), c.RetryPub, c.DLQPub, c.DLQKey)
+		return
+	}
+	_ = d.Ack(false)
+	if msg != "" {
+		log.Info().Msg(msg)
+	}
+}
 
-	// simulate processing
-	fail := rng.Intn(100) < c.FailRate
+// authorize redeems the order's gift cards and store credit and asks the
+// provider to hold the rest of the order total, taken from the command or
+// from the order carried by fraud.passed (or stored, if it carries none).
+// Balances covering the whole total authorize without the provider. It may
+// answer at once or later by webhook (payment.pending until then). A soft
+// decline leaves the customer time to retry (payment.declined); only a hard
+// decline (or balances that cannot pay as asked) fails the payment, returns
+// the balances, and in choreography releases stock and cancels the order.
+func (c *Consumer) authorize(ctx context.Context, command bool, evt models.Event[json.RawMessage]) (string, error) {
+	var amount models.PaymentAuthorizePayload
+	if command {
+		if err := json.Unmarshal(evt.Payload, &amount); err != nil {
+			return "", fmt.Errorf("payment.authorize payload: %w", err)
+		}
+	} else {
+		var passed models.FraudPassedPayload
+		if err := json.Unmarshal(evt.Payload, &passed); err != nil {
+			return "", fmt.Errorf("fraud.passed payload: %w", err)
+		}
+		amount.Order = passed.Order
+		if passed.Order != nil {
+			amount.TotalCents, amount.Currency = passed.Order.TotalCents, passed.Order.Currency
+		} else {
+			// events from before the snapshot was carried: charge the
+			// stored total, all of it by card
+			total, currency, err := c.Payments.OrderTotal(ctx, evt.OrderID)
+			if err != nil {
+				return "", fmt.Errorf("fraud.passed carries no order, load its total: %w", err)
+			}
+			amount.TotalCents, amount.Currency = total, currency
+		}
+	}
+	if amount.TotalCents <= 0 || amount.Currency == "" {
+		return "", fmt.Errorf("%s: no amount to authorize (%d %q)", evt.Type, amount.TotalCents, amount.Currency)
+	}
 
-	if fail {
-		c.Log.Warn().Str("order_id", evt.OrderID).Int("fail_rate", c.FailRate).Msg("payment failed")
+	// a redelivery announces the stored payment; one that finds none repeats
+	// the provider call under the same idempotency key
+	p, err := c.Payments.Get(ctx, evt.OrderID)
+	if err == nil {
+		return c.announce(ctx, p)
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", err
+	}
 
-		pf := models.Event[PaymentFailedPayload]{
-			ID:      uuid.NewString(),
-			Type:    "payment.failed",
-			Version: 1,
-			Time:    time.Now(),
-			OrderID: evt.OrderID,
-			Payload: PaymentFailedPayload{Reason: "simulated failure"},
+	t, err := c.redeem(ctx, evt.OrderID, amount)
+	if err != nil {
+		return "", err
+	}
+	var next repo.Payment
+	switch {
+	case t.failure != "":
+		next = repo.Payment{Status: repo.StatusFailed, FailureReason: t.failure, ExpiresAt: time.Now()}
+	case t.cardCents == 0:
+		next = repo.Payment{
+			Status:          repo.StatusAuthorized,
+			AuthorizationID: balanceAuthorization(evt.OrderID),
+			ExpiresAt:       time.Now().Add(c.AuthTTL),
+		}
+	default:
+		res, err := c.attempt(ctx, evt.OrderID, "", authorizationKey(evt.OrderID), provider.AuthorizeRequest{
+			OrderID:       evt.OrderID,
+			AmountCents:   t.cardCents,
+			Currency:      amount.Currency,
+			PaymentMethod: t.card.Token,
+		})
+		if err != nil {
+			return "", err
 		}
+		next = res.payment(c.AuthTTL, c.PendingTTL)
+	}
 
-		releaseReq := models.Event[InventoryReleaseRequestedPayload]{
-			ID:      uuid.NewString(),
-			Type:    "inventory.release_requested",
-			Version: 1,
-			Time:    time.Now(),
-			OrderID: evt.OrderID,
-			Payload: InventoryReleaseRequestedPayload{Reason: "payment failed"},
+	links, _ := models.LinksFromContext(ctx)
+	next.OrderID, next.AmountCents, next.Currency = evt.OrderID, t.cardCents, amount.Currency
+	next.BalanceCents, next.Order = t.balanceCents, amount.Order
+	next.CorrelationID, next.Seq = links.CorrelationID, links.Seq+1
+	if next.Status == repo.StatusDeclined {
+		next.ExpiresAt = time.Now().Add(c.RetryWindow)
+	}
+	p, err = c.Payments.Authorize(ctx, next)
+	if err != nil {
+		return "", err
+	}
+	return c.announce(ctx, p)
+}
+
+// announce publishes where an authorization stands: payment.authorized,
+// payment.pending while the provider has not confirmed it, payment.declined
+// while the customer may retry, or payment.failed.
+func (c *Consumer) announce(ctx context.Context, p repo.Payment) (string, error) {
+	var e models.Event[any]
+	switch {
+	case p.Status == repo.StatusFailed:
+		if err := c.publish(ctx, paymentFailed(p.OrderID, p.FailureReason, c.Choreography)...); err != nil {
+			return "", err
+		}
+		return "payment failed: " + p.FailureReason, nil
+	case p.Status == repo.StatusDeclined:
+		last, err := c.Payments.LastAttempt(ctx, p.OrderID)
+		if err != nil {
+			return "", err
 		}
+		e = models.NewEvent[any](models.TypePaymentDeclined, p.OrderID, models.PaymentDeclinedPayload{
+			Outcome:  last.Outcome,
+			Reason:   p.FailureReason,
+			Attempt:  last.Attempt,
+			Deadline: p.ExpiresAt.UTC().Format(time.RFC3339),
+		})
+	case p.Status == repo.StatusAuthorized:
+		e = models.NewEvent[any](models.TypePaymentAuthorized, p.OrderID, models.PaymentAuthorizedPayload{
+			AuthorizationID: p.AuthorizationID,
+			AmountCents:     p.AmountCents,
+			Currency:        p.Currency,
+			ExpiresAt:       p.ExpiresAt.UTC().Format(time.RFC3339),
+			Order:           p.Order,
+			BalanceCents:    p.BalanceCents,
+		})
+	case p.Unconfirmed():
+		e = models.NewEvent[any](models.TypePaymentPending, p.OrderID, models.PaymentPendingPayload{
+			AuthorizationID: p.AuthorizationID,
+			Status:          p.Status,
+			ActionURL:       p.ActionURL,
+			Deadline:        p.ExpiresAt.UTC().Format(time.RFC3339),
+		})
+	default:
+		return "payment already " + p.Status + ", authorization skipped", nil
+	}
+	if err := c.publish(ctx, e); err != nil {
+		return "", err
+	}
+	return "payment " + p.Status, nil
+}
 
-		cancelEvt := models.Event[OrderCancelledPayload]{
-			ID:      uuid.NewString(),
-			Type:    "order.cancelled",
-			Version: 1,
-			Time:    time.Now(),
-			OrderID: evt.OrderID,
-			Payload: OrderCancelledPayload{Reason: "payment failed"},
+// capture takes the authorized amount once shipping is scheduled.
+func (c *Consumer) capture(ctx context.Context, command bool, evt models.Event[json.RawMessage]) (string, error) {
+	p, err := c.Payments.Capture(ctx, evt.OrderID)
+	switch {
+	case err == nil:
+		captured := models.NewEvent[any](models.TypePaymentCaptured, evt.OrderID, models.PaymentCapturedPayload{
+			AuthorizationID: p.AuthorizationID,
+			AmountCents:     p.AmountCents,
+			Currency:        p.Currency,
+		})
+		if err := c.publish(ctx, captured); err != nil {
+			return "", err
 		}
+		return "payment captured", nil
 
-		pubCtx, cancel := rabbit.WithTimeout(ctx)
-		err1 := c.EventsPub.PublishJSON(pubCtx, pf.Type, pf, amqp.Table{"x-correlation-id": evt.ID})
-		err2 := c.EventsPub.PublishJSON(pubCtx, releaseReq.Type, releaseReq, amqp.Table{"x-correlation-id": evt.ID})
-		err3 := c.EventsPub.PublishJSON(pubCtx, cancelEvt.Type, cancelEvt, amqp.Table{"x-correlation-id": evt.ID})
-		cancel()
-
-		if err1 != nil || err2 != nil || err3 != nil {
-			c.Log.Error().
-				Err(firstErr(err1, err2, err3)).
-				Str("order_id", evt.OrderID).
-				Msg("publish failure events failed -> retry/dlq")
-			_ = rabbit.RetryOrDLQ(ctx, d, c.Service, int32(c.MaxAttempts), c.RetryPub, c.DLQPub, c.DLQKey)
-			return
+	case errors.Is(err, repo.ErrNotAuthorized) && !command:
+		// cancelled meanwhile, or authorized before two-phase payments
+		return "nothing to capture", nil
+
+	case errors.Is(err, repo.ErrNotAuthorized), errors.Is(err, repo.ErrAuthorizationExpired):
+		failed := []models.Event[any]{
+			models.NewEvent[any](models.TypePaymentCaptureFailed, evt.OrderID, models.PaymentCaptureFailedPayload{Reason: err.Error()}),
+		}
+		if !command {
+			failed = append(failed,
+				models.NewEvent[any](models.TypeInventoryReleaseRequested, evt.OrderID, models.InventoryReleaseRequestedPayload{Reason: err.Error()}),
+				models.NewEvent[any](models.TypeOrderCancelled, evt.OrderID, models.OrderCancelledPayload{Reason: err.Error()}))
+		}
+		if err := c.publish(ctx, failed...); err != nil {
+			return "", err
 		}
+		return "payment capture failed", nil
+	}
+	return "", err
+}
 
-		_ = d.Ack(false)
-		c.Log.Warn().Str("order_id", evt.OrderID).Msg("payment failed -> requested inventory release + cancelled order")
-		return
+// void releases the authorization and returns redeemed balances. A command
+// is always answered so the saga can go on compensating; a cancellation only
+// reports an actual void or reversal.
+func (c *Consumer) void(ctx context.Context, command bool, evt models.Event[json.RawMessage]) (string, error) {
+	p, reversed, err := c.Payments.Void(ctx, evt.OrderID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		p.Status = ""
+	} else if err != nil {
+		return "", err
+	}
+	if p.Status != repo.StatusVoided && reversed == 0 && !command {
+		return "", nil
+	}
+
+	note := "voided"
+	switch {
+	case p.Status == repo.StatusVoided:
+	case reversed > 0:
+		note = "balances returned"
+	default:
+		note = "nothing to void"
+	}
+	voided := models.NewEvent[any](models.TypePaymentVoided, evt.OrderID, models.PaymentVoidedPayload{Note: note, ReversedCents: reversed})
+	if err := c.publish(ctx, voided); err != nil {
+		return "", err
 	}
+	return "payment " + note, nil
+}
 
-	pp := models.Event[PaymentProcessedPayload]{
-		ID:      uuid.NewString(),
-		Type:    "payment.processed",
-		Version: 1,
-		Time:    time.Now(),
-		OrderID: evt.OrderID,
-		Payload: PaymentProcessedPayload{Note: "paid"},
+// refund returns money from a captured payment. The outcome is stored before
+// it is published, so a redelivered request publishes the same result again.
+func (c *Consumer) refund(ctx context.Context, evt models.Event[json.RawMessage]) (string, error) {
+	var req models.PaymentRefundRequestedPayload
+	if err := json.Unmarshal(evt.Payload, &req); err != nil {
+		return "", fmt.Errorf("payment.refund_requested payload: %w", err)
+	}
+	var items json.RawMessage
+	if len(req.Items) > 0 {
+		b, err := json.Marshal(req.Items)
+		if err != nil {
+			return "", err
+		}
+		items = b
 	}
 
-	pubCtx, cancel := rabbit.WithTimeout(ctx)
-	err := c.EventsPub.PublishJSON(pubCtx, pp.Type, pp, amqp.Table{"x-correlation-id": evt.ID})
-	cancel()
+	f, err := c.Payments.Refund(ctx, repo.Refund{
+		RefundID:    req.RefundID,
+		OrderID:     evt.OrderID,
+		AmountCents: req.AmountCents,
+		Reason:      req.Reason,
+		Items:       items,
+	})
 	if err != nil {
-		c.Log.Error().Err(err).Str("order_id", evt.OrderID).Msg("publish payment.processed failed -> retry/dlq")
-		_ = rabbit.RetryOrDLQ(ctx, d, c.Service, int32(c.MaxAttempts), c.RetryPub, c.DLQPub, c.DLQKey)
-		return
+		return "", err
 	}
 
-	_ = d.Ack(false)
-	c.Log.Info().Str("order_id", evt.OrderID).Msg("payment processed")
+	if f.Status != repo.RefundStatusRefunded {
+		failed := models.NewEvent[any](models.TypePaymentRefundFailed, evt.OrderID, models.PaymentRefundFailedPayload{
+			RefundID: f.RefundID,
+			Reason:   f.FailureReason,
+		})
+		if err := c.publish(ctx, failed); err != nil {
+			return "", err
+		}
+		return "refund failed: " + f.FailureReason, nil
+	}
+
+	refunded := models.NewEvent[any](models.TypePaymentRefunded, evt.OrderID, models.PaymentRefundedPayload{
+		RefundID:           f.RefundID,
+		AmountCents:        f.AmountCents,
+		RefundedTotalCents: f.RefundedTotalCents,
+		Currency:           f.Currency,
+		BalanceCents:       f.BalanceCents,
+	})
+	if err := c.publish(ctx, refunded); err != nil {
+		return "", err
+	}
+	return "payment refunded", nil
 }
 
-func firstErr(errs ...error) error {
-	for _, e := range errs {
-		if e != nil {
-			return e
+// paymentFailed is what a failed authorization publishes; in choreography it
+// also releases the stock and cancels the order.
+func paymentFailed(orderID, reason string, choreography bool) []models.Event[any] {
+	events := []models.Event[any]{
+		models.NewEvent[any](models.TypePaymentFailed, orderID, models.PaymentFailedPayload{Reason: reason}),
+	}
+	if choreography {
+		events = append(events,
+			models.NewEvent[any](models.TypeInventoryReleaseRequested, orderID, models.InventoryReleaseRequestedPayload{Reason: "payment failed"}),
+			models.NewEvent[any](models.TypeOrderCancelled, orderID, models.OrderCancelledPayload{Reason: "payment failed"}))
+	}
+	return events
+}
+
+// publish sends events in order and stops at the first failure.
+func (c *Consumer) publish(ctx context.Context, events ...models.Event[any]) error {
+	pubCtx, cancel := rabbit.WithTimeout(ctx)
+	defer cancel()
+	for _, e := range events {
+		if err := c.EventsPub.PublishJSON(pubCtx, e.Type, e, nil); err != nil {
+			return fmt.Errorf("publish %s: %w", e.Type, err)
 		}
 	}
 	return nil
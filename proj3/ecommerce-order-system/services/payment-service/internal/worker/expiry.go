@@ -0,0 +1,65 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"ecommerce-order-system/services/payment-service/internal/repo"
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+// RunExpiry expires uncaptured authorizations, and fails those the provider
+// did not confirm in time, every interval until ctx is done. In choreography
+// either also releases the stock and cancels the order; an orchestrated saga
+// learns of an expiry when its capture fails.
+func (c *Consumer) RunExpiry(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			for {
+				expired, err := c.Payments.ExpireOne(ctx, func(p repo.Payment) error {
+					return c.expired(ctx, p)
+				})
+				if err != nil {
+					c.Log.Error().Err(err).Msg("expire authorization failed")
+				}
+				if err != nil || !expired {
+					break
+				}
+			}
+		}
+	}
+}
+
+func (c *Consumer) expired(ctx context.Context, p repo.Payment) error {
+	ctx = models.ContextWithLinks(ctx, models.Links{CorrelationID: p.CorrelationID, Seq: p.Seq})
+
+	if p.Status == repo.StatusFailed {
+		if err := c.publish(ctx, paymentFailed(p.OrderID, p.FailureReason, c.Choreography)...); err != nil {
+			return err
+		}
+		c.Log.Warn().Str("order_id", p.OrderID).Str("authorization_id", p.AuthorizationID).Msg("payment not confirmed by provider in time")
+		return nil
+	}
+
+	events := []models.Event[any]{
+		models.NewEvent[any](models.TypePaymentAuthorizationExpired, p.OrderID, models.PaymentAuthorizationExpiredPayload{
+			AuthorizationID: p.AuthorizationID,
+			ExpiredAt:       p.ExpiresAt.UTC().Format(time.RFC3339),
+		}),
+	}
+	if c.Choreography {
+		events = append(events,
+			models.NewEvent[any](models.TypeInventoryReleaseRequested, p.OrderID, models.InventoryReleaseRequestedPayload{Reason: "payment authorization expired"}),
+			models.NewEvent[any](models.TypeOrderCancelled, p.OrderID, models.OrderCancelledPayload{Reason: "payment authorization expired"}))
+	}
+	if err := c.publish(ctx, events...); err != nil {
+		return err
+	}
+	c.Log.Warn().Str("order_id", p.OrderID).Str("authorization_id", p.AuthorizationID).Msg("payment authorization expired")
+	return nil
+}
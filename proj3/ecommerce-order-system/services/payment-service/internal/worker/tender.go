@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"ecommerce-order-system/services/payment-service/internal/repo"
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+// tender is how an order is split between balances and the card.
+type tender struct {
+	balanceCents int
+	cardCents    int
+	// card is the card to charge cardCents; nil if the order named none.
+	card *models.PaymentMethodPayload
+	// failure says why the order cannot be paid as asked.
+	failure string
+}
+
+// redeem takes the order's gift cards and store credit in the order listed,
+// each up to its cap, and leaves the rest to the card. Every redemption is
+// keyed by order and position, so a redelivered authorization redeems
+// nothing twice. An order without payment methods is paid by card.
+func (c *Consumer) redeem(ctx context.Context, orderID string, amount models.PaymentAuthorizePayload) (tender, error) {
+	t := tender{cardCents: amount.TotalCents}
+	if amount.Order == nil || len(amount.Order.PaymentMethods) == 0 {
+		t.card = &models.PaymentMethodPayload{Type: models.PaymentMethodCard}
+		return t, nil
+	}
+
+	for i, m := range amount.Order.PaymentMethods {
+		code := m.Code
+		switch m.Type {
+		case models.PaymentMethodCard:
+			t.card = &amount.Order.PaymentMethods[i]
+			continue
+		case models.PaymentMethodStoreCredit:
+			code = amount.Order.UserID
+		case models.PaymentMethodGiftCard:
+		default:
+			t.failure = "unknown payment method " + m.Type
+			return t, nil
+		}
+
+		want := t.cardCents
+		if m.AmountCents > 0 {
+			want = min(want, m.AmountCents)
+		}
+		if want == 0 {
+			continue
+		}
+		r, err := c.Balances.Redeem(ctx, repo.BalanceOp{
+			Key:         fmt.Sprintf("redeem:%s:%d", orderID, i),
+			Kind:        m.Type,
+			Code:        code,
+			Currency:    amount.Currency,
+			AmountCents: int64(want),
+			OrderID:     orderID,
+			Reason:      "order " + orderID,
+		})
+		if errors.Is(err, repo.ErrBalanceNotFound) || errors.Is(err, repo.ErrBalanceCurrency) {
+			t.failure = err.Error()
+			return t, nil
+		}
+		if err != nil {
+			return t, err
+		}
+		t.balanceCents += int(r.AmountCents)
+		t.cardCents -= int(r.AmountCents)
+	}
+	if t.cardCents > 0 && t.card == nil {
+		t.failure = "gift cards and store credit do not cover the order"
+	}
+	return t, nil
+}
+
+// balanceAuthorization stands in for the provider's reference when balances
+// pay the whole order and the provider is never called.
+func balanceAuthorization(orderID string) string {
+	return "BAL-" + orderID
+}
@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"context"
+
+	"ecommerce-order-system/services/payment-service/internal/provider"
+	"ecommerce-order-system/services/payment-service/internal/repo"
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+// ApplyWebhook applies a verified provider webhook and publishes the outcome
+// in the order's chain: payment.authorized, or payment.failed (in
+// choreography with the stock released and the order cancelled). It returns
+// one of the repo.Webhook* results.
+func (c *Consumer) ApplyWebhook(ctx context.Context, wh provider.Webhook) (string, error) {
+	_, result, err := c.Payments.Confirm(ctx, repo.Confirmation{
+		EventID:   wh.ID,
+		Type:      wh.Type,
+		Reference: wh.Data.Reference,
+		Succeeded: wh.Type == provider.WebhookAuthorizationSucceeded,
+		Reason:    wh.Data.Reason,
+	}, c.AuthTTL, func(p repo.Payment) error {
+		if p.Status == repo.StatusAuthorized {
+			// Confirm already moved Seq to the payment.authorized about to go out
+			ctx := models.ContextWithLinks(ctx, models.Links{CorrelationID: p.CorrelationID, Seq: p.Seq - 1})
+			_, err := c.announce(ctx, p)
+			return err
+		}
+		ctx := models.ContextWithLinks(ctx, models.Links{CorrelationID: p.CorrelationID, Seq: p.Seq})
+		return c.publish(ctx, paymentFailed(p.OrderID, p.FailureReason, c.Choreography)...)
+	})
+	if err != nil {
+		return "", err
+	}
+	c.Log.Info().Str("webhook_id", wh.ID).Str("type", wh.Type).Str("reference", wh.Data.Reference).Str("result", result).Msg("provider webhook applied")
+	return result, nil
+}
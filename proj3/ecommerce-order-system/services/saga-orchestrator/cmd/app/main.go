@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	httpx "ecommerce-order-system/services/saga-orchestrator/internal/http"
+	"ecommerce-order-system/services/saga-orchestrator/internal/saga"
+	"ecommerce-order-system/services/saga-orchestrator/internal/worker"
+	"ecommerce-order-system/shared/pkg/config"
+	"ecommerce-order-system/shared/pkg/logger"
+	"ecommerce-order-system/shared/pkg/models"
+	"ecommerce-order-system/shared/pkg/rabbit"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		panic(err)
+	}
+	log := logger.New("saga-orchestrator", cfg.Common.LogLevel)
+
+	if cfg.Saga.Mode != config.SagaOrchestration {
+		log.Fatal().Str("mode", cfg.Saga.Mode).Msg("SAGA_MODE must be orchestration: the workers would otherwise react to events twice")
+	}
+
+	ctxDB, cancelDB := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelDB()
+	db, err := pgxpool.New(ctxDB, cfg.Postgres.DSN)
+	if err != nil {
+		log.Fatal().Err(err).Msg("pg connect failed")
+	}
+	defer db.Close()
+
+	rc, err := rabbit.Connect(cfg.Rabbit.URL)
+	if err != nil {
+		log.Fatal().Err(err).Msg("rabbit connect failed")
+	}
+	defer func() { _ = rc.Close() }()
+
+	if err := rabbit.DeclareBase(rc.Ch); err != nil {
+		log.Fatal().Err(err).Msg("declare base failed")
+	}
+
+	def := saga.OrderSaga
+	keys := def.Keys()
+	for name := range cfg.Saga.StepTimeouts {
+		if !slices.Contains(def.StepNames(), name) {
+			log.Fatal().Str("step", name).Strs("steps", def.StepNames()).Msg("SAGA_STEP_TIMEOUTS names an unknown step")
+		}
+	}
+
+	if err := rabbit.DeclareQueueWithDLQ(rc.Ch, rabbit.QueueSpec{
+		Name:     "saga.q",
+		BindKeys: keys,
+		DLQKey:   "saga.dlq",
+		Prefetch: 20,
+	}); err != nil {
+		log.Fatal().Err(err).Msg("declare saga topology failed")
+	}
+
+	// retry per routing key
+	for _, k := range keys {
+		_ = rabbit.DeclareRetryQueue(rc.Ch, "saga.retry."+k+".5s", "saga."+k, k, 5000)
+	}
+
+	deliveries, err := rabbit.NewConsumer(rc.Ch).Consume("saga.q", 20)
+	if err != nil {
+		log.Fatal().Err(err).Msg("consume failed")
+	}
+
+	sagas := &saga.SagasPG{DB: db}
+	orch := &saga.Orchestrator{
+		Log:          log,
+		DB:           db,
+		Sagas:        sagas,
+		Def:          def,
+		StepTimeout:  cfg.Saga.StepTimeout,
+		StepTimeouts: cfg.Saga.StepTimeouts,
+
+		MaxCompensationAttempts: cfg.Saga.MaxCompensationAttempts,
+		TimeoutRetryDelay:       cfg.Saga.TimeoutRetryDelay,
+	}
+
+	w := &worker.Consumer{
+		Log:      log,
+		Saga:     orch,
+		RetryPub: rabbit.NewPublisher(rc.Ch, rabbit.ExchangeRetry),
+		DLQPub:   rabbit.NewPublisher(rc.Ch, rabbit.ExchangeDLX),
+		// the saga keeps the current orders.created payload; older ones are upcast
+		Events:      models.Decoder{},
+		Service:     "saga",
+		MaxAttempts: 5,
+		DLQKey:      "saga.dlq",
+	}
+
+	appCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(appCtx, deliveries)
+	go orch.RunTimeouts(appCtx, cfg.Saga.TimeoutPollInterval)
+
+	h := &httpx.SagaHandlers{Sagas: sagas, Def: def, Log: log}
+	srv := &http.Server{
+		Addr:              cfg.Saga.HTTPAddr,
+		Handler:           httpx.NewRouter(&httpx.Handlers{Health: httpx.Health, GetSaga: h.Get, ListSagas: h.List}),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		log.Info().Str("addr", srv.Addr).Msg("http started")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal().Err(err).Msg("http failed")
+		}
+	}()
+
+	log.Info().Msg("saga-orchestrator started")
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+	log.Info().Msg("shutdown...")
+
+	cancel()
+	shCtx, shCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shCancel()
+	_ = srv.Shutdown(shCtx)
+}
@@ -0,0 +1,72 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+
+	"ecommerce-order-system/services/saga-orchestrator/internal/saga"
+)
+
+func Health(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+type SagaHandlers struct {
+	Sagas *saga.SagasPG
+	Def   saga.Definition
+	Log   zerolog.Logger
+}
+
+type sagaResp struct {
+	*saga.Saga
+	Steps []string `json:"steps"`
+}
+
+// Get returns the saga of an order together with the definition's step list.
+func (h *SagaHandlers) Get(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		http.Error(w, "missing id", http.StatusBadRequest)
+		return
+	}
+	s, err := h.Sagas.Get(r.Context(), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Log.Error().Err(err).Str("order_id", id).Msg("get saga failed")
+		http.Error(w, "failed to read saga", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(sagaResp{Saga: s, Steps: h.Def.StepNames()})
+}
+
+// List returns recent sagas: ?status=running|compensating|completed|aborted, ?limit=N (max 500).
+func (h *SagaHandlers) List(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > 500 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	sagas, err := h.Sagas.List(r.Context(), r.URL.Query().Get("status"), limit)
+	if err != nil {
+		h.Log.Error().Err(err).Msg("list sagas failed")
+		http.Error(w, "failed to list sagas", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(sagas)
+}
@@ -0,0 +1,29 @@
+package httpx
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"ecommerce-order-system/shared/pkg/metrics"
+)
+
+type Handlers struct {
+	Health    http.HandlerFunc
+	GetSaga   http.HandlerFunc
+	ListSagas http.HandlerFunc
+}
+
+func NewRouter(h *Handlers) http.Handler {
+	r := chi.NewRouter()
+	r.Use(metrics.Middleware("saga-orchestrator"))
+	r.Handle("/metrics", promhttp.Handler())
+
+	r.Get("/health", h.Health)
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Get("/sagas", h.ListSagas)
+		r.Get("/sagas/{id}", h.GetSaga)
+	})
+	return r
+}
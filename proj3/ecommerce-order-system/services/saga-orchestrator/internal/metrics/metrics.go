@@ -0,0 +1,26 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	SagaStartedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "saga_started_total",
+		Help: "Sagas started, by definition",
+	}, []string{"definition"})
+	SagaFinishedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "saga_finished_total",
+		Help: "Sagas finished, by definition and status (completed, aborted, failed)",
+	}, []string{"definition", "status"})
+	SagaMessagesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "saga_messages_total",
+		Help: "Commands and events published by the orchestrator, by type",
+	}, []string{"type"})
+	SagaStepTimeoutsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "saga_step_timeouts_total",
+		Help: "Expired step deadlines, by definition, step and saga status (running, compensating)",
+	}, []string{"definition", "step", "status"})
+)
+
+func init() {
+	prometheus.MustRegister(SagaStartedTotal, SagaFinishedTotal, SagaMessagesTotal, SagaStepTimeoutsTotal)
+}
@@ -0,0 +1,151 @@
+package saga
+
+import (
+	"slices"
+	"time"
+
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+// Step is one unit of work in a saga. Command starts it; OnSuccess or one of
+// OnFailure ends it. A completed step with a Compensate command is undone,
+// in reverse step order, when a later step fails. A step that times out may
+// still have taken effect, so it is compensated as well.
+type Step struct {
+	Name string
+
+	Command   string
+	Build     func(order models.OrderCreatedPayload) any
+	OnSuccess string
+	OnFailure []string
+	// OnHold events keep the step waiting past its deadline: their payload
+	// carries a new "deadline" (RFC 3339) and the step timeout starts over
+	// from there.
+	OnHold []string
+
+	Compensate      string
+	BuildCompensate func(reason string) any
+	Compensated     string
+
+	// Timeout overrides the default step deadline (SAGA_STEP_TIMEOUT).
+	Timeout time.Duration
+}
+
+// Definition declares a saga: the event that starts it, its steps in order and
+// the events published when it completes or is aborted after compensation.
+type Definition struct {
+	Name  string
+	Start string
+	Steps []Step
+
+	Completed        string
+	CompletedPayload func() any
+	Aborted          string
+	AbortedPayload   func(reason string) any
+}
+
+// Keys lists every event type the definition reacts to.
+func (d Definition) Keys() []string {
+	keys := []string{d.Start}
+	add := func(k string) {
+		if k != "" && !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+	for _, s := range d.Steps {
+		add(s.OnSuccess)
+		for _, k := range s.OnHold {
+			add(k)
+		}
+		for _, k := range s.OnFailure {
+			add(k)
+		}
+		add(s.Compensated)
+	}
+	return keys
+}
+
+// StepNames lists the step names in execution order.
+func (d Definition) StepNames() []string {
+	names := make([]string, 0, len(d.Steps))
+	for _, s := range d.Steps {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// OrderSaga is the order flow: reserve stock, screen for fraud, authorize the
+// payment, schedule shipping and only then capture the payment. An order sent
+// to manual review waits in screen_fraud until its step deadline. The reservation is released and
+// the authorization voided when a later step fails.
+var OrderSaga = Definition{
+	Name:  "order",
+	Start: models.TypeOrderCreated,
+	Steps: []Step{
+		{
+			Name:    "reserve_inventory",
+			Command: models.CmdInventoryReserve,
+			Build: func(o models.OrderCreatedPayload) any {
+				return models.InventoryReservePayload{Items: o.Items}
+			},
+			OnSuccess:  models.TypeInventoryReserved,
+			OnFailure:  []string{models.TypeInventoryFailed},
+			Compensate: models.CmdInventoryRelease,
+			BuildCompensate: func(reason string) any {
+				return models.InventoryReleasePayload{Reason: reason}
+			},
+			Compensated: models.TypeInventoryReleased,
+		},
+		{
+			Name:    "screen_fraud",
+			Command: models.CmdFraudScreen,
+			Build: func(models.OrderCreatedPayload) any {
+				return models.FraudScreenPayload{}
+			},
+			OnSuccess: models.TypeFraudPassed,
+			OnFailure: []string{models.TypeOrderRejectedFraud},
+			OnHold:    []string{models.TypeOrderHeld},
+		},
+		{
+			Name:    "authorize_payment",
+			Command: models.CmdPaymentAuthorize,
+			Build: func(o models.OrderCreatedPayload) any {
+				return models.PaymentAuthorizePayload{TotalCents: o.TotalCents, Currency: o.Currency, Order: o.Snapshot()}
+			},
+			OnSuccess:  models.TypePaymentAuthorized,
+			OnFailure:  []string{models.TypePaymentFailed},
+			OnHold:     []string{models.TypePaymentPending, models.TypePaymentDeclined},
+			Compensate: models.CmdPaymentVoid,
+			BuildCompensate: func(reason string) any {
+				return models.PaymentVoidPayload{Reason: reason}
+			},
+			Compensated: models.TypePaymentVoided,
+		},
+		{
+			Name:    "schedule_shipping",
+			Command: models.CmdShippingSchedule,
+			Build: func(o models.OrderCreatedPayload) any {
+				return models.ShippingSchedulePayload{Order: o.Snapshot()}
+			},
+			OnSuccess: models.TypeShippingScheduled,
+			OnFailure: []string{models.TypeShippingFailed},
+		},
+		{
+			Name:    "capture_payment",
+			Command: models.CmdPaymentCapture,
+			Build: func(models.OrderCreatedPayload) any {
+				return models.PaymentCapturePayload{}
+			},
+			OnSuccess: models.TypePaymentCaptured,
+			OnFailure: []string{models.TypePaymentCaptureFailed},
+		},
+	},
+	Completed: models.TypeOrderCompleted,
+	CompletedPayload: func() any {
+		return models.OrderCompletedPayload{Note: "done"}
+	},
+	Aborted: models.TypeOrderCancelled,
+	AbortedPayload: func(reason string) any {
+		return models.OrderCancelledPayload{Reason: reason}
+	},
+}
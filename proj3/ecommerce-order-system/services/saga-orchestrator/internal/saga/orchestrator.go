@@ -0,0 +1,244 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+
+	"ecommerce-order-system/services/saga-orchestrator/internal/metrics"
+	"ecommerce-order-system/shared/pkg/models"
+	"ecommerce-order-system/shared/pkg/outbox"
+)
+
+// Orchestrator drives sagas of one Definition. Every reply is handled in a
+// transaction holding the saga row lock; the resulting command or event is
+// written to the outbox in that transaction, so it goes out only once the
+// saga row is committed and a reply can never arrive before its saga.
+type Orchestrator struct {
+	Log   zerolog.Logger
+	DB    *pgxpool.Pool
+	Sagas *SagasPG
+	Def   Definition
+	// StepTimeout is the deadline for a step without its own Timeout;
+	// StepTimeouts overrides both per step name.
+	StepTimeout  time.Duration
+	StepTimeouts map[string]time.Duration
+	// A compensation command is sent at most MaxCompensationAttempts times
+	// after it timed out; then the saga fails. A timeout that cannot be
+	// handled is tried again after TimeoutRetryDelay.
+	MaxCompensationAttempts int
+	TimeoutRetryDelay       time.Duration
+}
+
+// Handle applies one event. Events that do not match the saga's current step
+// (redeliveries, late replies, orders started before orchestration) are ignored.
+// ctx should carry evt's links (models.ContextWithEvent).
+func (o *Orchestrator) Handle(ctx context.Context, evt models.Event[json.RawMessage]) error {
+	tx, err := o.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if evt.Type == o.Def.Start {
+		started, err := o.start(ctx, tx, evt)
+		if err != nil || !started {
+			return err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return err
+		}
+		metrics.SagaStartedTotal.WithLabelValues(o.Def.Name).Inc()
+		return nil
+	}
+
+	s, err := o.Sagas.Lock(ctx, tx, evt.OrderID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		o.Log.Debug().Str("order_id", evt.OrderID).Str("type", evt.Type).Msg("no saga for order, ignored")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	status := s.Status
+	changed, err := o.advance(ctx, tx, s, evt)
+	if err != nil || !changed {
+		return err
+	}
+	s.LastSeq = evt.Seq + 1
+	if err := o.Sagas.Save(ctx, tx, s); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	o.countFinished(status, s)
+	return nil
+}
+
+// start creates the saga for evt and sends its first command. It reports
+// false for a saga that already exists.
+func (o *Orchestrator) start(ctx context.Context, tx pgx.Tx, evt models.Event[json.RawMessage]) (bool, error) {
+	s := &Saga{
+		OrderID:          evt.OrderID,
+		Definition:       o.Def.Name,
+		Status:           StatusRunning,
+		CompletedSteps:   []string{},
+		CompensatedSteps: []string{},
+		CorrelationID:    evt.CorrelationID,
+		LastSeq:          evt.Seq + 1,
+		Data:             evt.Payload,
+	}
+	created, err := o.Sagas.Create(ctx, tx, s)
+	if err != nil || !created {
+		return false, err
+	}
+	if err := o.runStep(ctx, tx, s, 0); err != nil {
+		return false, err
+	}
+	return true, o.Sagas.Save(ctx, tx, s)
+}
+
+// advance moves s forward for evt and reports whether s changed.
+func (o *Orchestrator) advance(ctx context.Context, tx pgx.Tx, s *Saga, evt models.Event[json.RawMessage]) (bool, error) {
+	if s.Step < 0 || s.Step >= len(o.Def.Steps) {
+		return false, nil
+	}
+	step := o.Def.Steps[s.Step]
+
+	switch s.Status {
+	case StatusRunning:
+		switch {
+		case evt.Type == step.OnSuccess:
+			s.CompletedSteps = append(s.CompletedSteps, step.Name)
+			if s.Step+1 < len(o.Def.Steps) {
+				return true, o.runStep(ctx, tx, s, s.Step+1)
+			}
+			return true, o.finish(ctx, tx, s, StatusCompleted, o.Def.Completed, o.Def.CompletedPayload())
+		case slices.Contains(step.OnFailure, evt.Type):
+			s.Status = StatusCompensating
+			s.FailureReason = failureReason(evt)
+			o.Log.Warn().Str("order_id", s.OrderID).Str("step", step.Name).Str("reason", s.FailureReason).Msg("saga step failed, compensating")
+			return true, o.compensate(ctx, tx, s, s.Step-1)
+		case slices.Contains(step.OnHold, evt.Type):
+			var held struct {
+				Deadline time.Time `json:"deadline"`
+			}
+			if err := json.Unmarshal(evt.Payload, &held); err != nil || held.Deadline.IsZero() {
+				return false, fmt.Errorf("%s payload: no deadline", evt.Type)
+			}
+			deadline := held.Deadline.Add(o.stepTimeout(step))
+			s.DeadlineAt = &deadline
+			o.Log.Info().Str("order_id", s.OrderID).Str("step", step.Name).Time("deadline", deadline).Msg("saga step on hold")
+			return true, nil
+		}
+
+	case StatusCompensating:
+		if evt.Type == step.Compensated {
+			s.CompensatedSteps = append(s.CompensatedSteps, step.Name)
+			return true, o.compensate(ctx, tx, s, s.Step-1)
+		}
+	}
+	return false, nil
+}
+
+// runStep makes step i current and sends its command.
+func (o *Orchestrator) runStep(ctx context.Context, tx pgx.Tx, s *Saga, i int) error {
+	step := o.Def.Steps[i]
+	var order models.OrderCreatedPayload
+	if err := json.Unmarshal(s.Data, &order); err != nil {
+		return fmt.Errorf("saga data: %w", err)
+	}
+	o.setStep(s, i)
+	return o.publish(ctx, tx, step.Command, s.OrderID, step.Build(order))
+}
+
+// compensate undoes completed steps strictly one at a time, newest first,
+// starting at step i. Once nothing is left the saga is aborted.
+func (o *Orchestrator) compensate(ctx context.Context, tx pgx.Tx, s *Saga, i int) error {
+	for ; i >= 0; i-- {
+		step := o.Def.Steps[i]
+		if step.Compensate == "" || !slices.Contains(s.CompletedSteps, step.Name) {
+			continue
+		}
+		return o.compensateStep(ctx, tx, s, i)
+	}
+	return o.finish(ctx, tx, s, StatusAborted, o.Def.Aborted, o.Def.AbortedPayload(s.FailureReason))
+}
+
+// compensateStep makes step i current and sends its compensation command.
+func (o *Orchestrator) compensateStep(ctx context.Context, tx pgx.Tx, s *Saga, i int) error {
+	step := o.Def.Steps[i]
+	o.setStep(s, i)
+	s.CompensationAttempts = 0
+	return o.publish(ctx, tx, step.Compensate, s.OrderID, step.BuildCompensate(s.FailureReason))
+}
+
+func (o *Orchestrator) finish(ctx context.Context, tx pgx.Tx, s *Saga, status, eventType string, payload any) error {
+	s.Status = status
+	s.StepName = ""
+	s.DeadlineAt = nil
+	if err := o.publish(ctx, tx, eventType, s.OrderID, payload); err != nil {
+		return err
+	}
+	o.Log.Info().Str("order_id", s.OrderID).Str("status", status).Msg("saga finished")
+	return nil
+}
+
+// countFinished counts s as finished if it reached a final status from
+// status; call it once the transaction that saved s has committed.
+func (o *Orchestrator) countFinished(status string, s *Saga) {
+	switch s.Status {
+	case StatusCompleted, StatusAborted, StatusFailed:
+		if s.Status != status {
+			metrics.SagaFinishedTotal.WithLabelValues(o.Def.Name, s.Status).Inc()
+		}
+	}
+}
+
+func (o *Orchestrator) setStep(s *Saga, i int) {
+	step := o.Def.Steps[i]
+	deadline := time.Now().Add(o.stepTimeout(step))
+	s.Step = i
+	s.StepName = step.Name
+	s.DeadlineAt = &deadline
+}
+
+func (o *Orchestrator) stepTimeout(step Step) time.Duration {
+	timeout := step.Timeout
+	if d, ok := o.StepTimeouts[step.Name]; ok {
+		timeout = d
+	}
+	if timeout <= 0 {
+		timeout = o.StepTimeout
+	}
+	return timeout
+}
+
+// publish enqueues a command or event in tx, linked to the links in ctx;
+// outbox-worker publishes it after commit.
+func (o *Orchestrator) publish(ctx context.Context, tx pgx.Tx, eventType, orderID string, payload any) error {
+	if err := outbox.EnqueueEvent(ctx, tx, "saga-orchestrator", models.NewEvent(eventType, orderID, payload)); err != nil {
+		return fmt.Errorf("enqueue %s: %w", eventType, err)
+	}
+	metrics.SagaMessagesTotal.WithLabelValues(eventType).Inc()
+	return nil
+}
+
+func failureReason(evt models.Event[json.RawMessage]) string {
+	var p struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(evt.Payload, &p); err == nil && p.Reason != "" {
+		return p.Reason
+	}
+	return evt.Type
+}
@@ -0,0 +1,154 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	StatusRunning      = "running"
+	StatusCompensating = "compensating"
+	StatusCompleted    = "completed"
+	StatusAborted      = "aborted"
+	// StatusFailed: a compensation kept timing out and was given up on; the
+	// order needs manual attention.
+	StatusFailed = "failed"
+)
+
+// Saga is one row of the sagas table. While running, Step/StepName is the step
+// waiting for its reply; while compensating, the step being undone.
+type Saga struct {
+	OrderID          string     `json:"order_id"`
+	Definition       string     `json:"definition"`
+	Status           string     `json:"status"`
+	Step             int        `json:"step"`
+	StepName         string     `json:"step_name,omitempty"`
+	DeadlineAt       *time.Time `json:"deadline_at,omitempty"`
+	CompletedSteps   []string   `json:"completed_steps"`
+	CompensatedSteps []string   `json:"compensated_steps"`
+	FailureReason    string     `json:"failure_reason,omitempty"`
+	TimedOutStep     string     `json:"timed_out_step,omitempty"`
+	// CompensationAttempts counts how often the current compensation command
+	// was sent again after its deadline.
+	CompensationAttempts int    `json:"compensation_attempts"`
+	CorrelationID        string `json:"correlation_id,omitempty"`
+	// LastSeq is the seq of the last message the orchestrator published.
+	LastSeq   int64           `json:"last_seq"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+type SagasPG struct{ DB *pgxpool.Pool }
+
+const sagaColumns = `
+	order_id::text, definition, status, step, step_name, deadline_at,
+	completed_steps, compensated_steps, coalesce(failure_reason, ''),
+	coalesce(timed_out_step, ''), compensation_attempts, coalesce(correlation_id, ''), last_seq, data::text,
+	created_at, updated_at`
+
+// Create inserts s; false means a saga for the order already exists.
+func (r *SagasPG) Create(ctx context.Context, tx pgx.Tx, s *Saga) (bool, error) {
+	ct, err := tx.Exec(ctx, `
+		insert into sagas (order_id, definition, status, step, step_name, correlation_id, data)
+		values ($1::uuid, $2, $3, $4, $5, nullif($6, ''), $7::jsonb)
+		on conflict (order_id) do nothing
+	`, s.OrderID, s.Definition, s.Status, s.Step, s.StepName, s.CorrelationID, string(s.Data))
+	if err != nil {
+		return false, err
+	}
+	return ct.RowsAffected() == 1, nil
+}
+
+// Lock loads the saga of an order and locks its row until tx ends.
+func (r *SagasPG) Lock(ctx context.Context, tx pgx.Tx, orderID string) (*Saga, error) {
+	return scanSaga(tx.QueryRow(ctx, `select `+sagaColumns+` from sagas where order_id = $1::uuid for update`, orderID))
+}
+
+func (r *SagasPG) Save(ctx context.Context, tx pgx.Tx, s *Saga) error {
+	_, err := tx.Exec(ctx, `
+		update sagas
+		set status = $2,
+		    step = $3,
+		    step_name = $4,
+		    deadline_at = $5,
+		    completed_steps = $6,
+		    compensated_steps = $7,
+		    failure_reason = nullif($8, ''),
+		    timed_out_step = nullif($9, ''),
+		    compensation_attempts = $10,
+		    last_seq = $11,
+		    updated_at = now()
+		where order_id = $1::uuid
+	`, s.OrderID, s.Status, s.Step, s.StepName, s.DeadlineAt, s.CompletedSteps, s.CompensatedSteps, s.FailureReason, s.TimedOutStep,
+		s.CompensationAttempts, s.LastSeq)
+	return err
+}
+
+// Postpone moves the step deadline of the saga locked in tx to at, so a saga
+// whose timeout cannot be handled is retried later instead of being picked
+// again by every sweep.
+func (r *SagasPG) Postpone(ctx context.Context, tx pgx.Tx, orderID string, at time.Time) error {
+	_, err := tx.Exec(ctx, `update sagas set deadline_at = $2, updated_at = now() where order_id = $1::uuid`, orderID, at)
+	return err
+}
+
+// LockExpired locks one running or compensating saga whose step deadline has
+// passed. Rows locked by another replica are skipped, so several orchestrators
+// can sweep concurrently.
+func (r *SagasPG) LockExpired(ctx context.Context, tx pgx.Tx) (*Saga, error) {
+	return scanSaga(tx.QueryRow(ctx, `
+		select `+sagaColumns+`
+		from sagas
+		where status in ('running', 'compensating')
+		  and deadline_at <= now()
+		order by deadline_at
+		limit 1
+		for update skip locked
+	`))
+}
+
+func (r *SagasPG) Get(ctx context.Context, orderID string) (*Saga, error) {
+	return scanSaga(r.DB.QueryRow(ctx, `select `+sagaColumns+` from sagas where order_id = $1::uuid`, orderID))
+}
+
+// List returns the most recently updated sagas, optionally filtered by status.
+func (r *SagasPG) List(ctx context.Context, status string, limit int) ([]*Saga, error) {
+	rows, err := r.DB.Query(ctx, `
+		select `+sagaColumns+`
+		from sagas
+		where $1 = '' or status = $1
+		order by updated_at desc
+		limit $2
+	`, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*Saga{}
+	for rows.Next() {
+		s, err := scanSaga(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func scanSaga(row pgx.Row) (*Saga, error) {
+	var s Saga
+	var data string
+	if err := row.Scan(&s.OrderID, &s.Definition, &s.Status, &s.Step, &s.StepName, &s.DeadlineAt,
+		&s.CompletedSteps, &s.CompensatedSteps, &s.FailureReason, &s.TimedOutStep, &s.CompensationAttempts, &s.CorrelationID, &s.LastSeq, &data,
+		&s.CreatedAt, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	s.Data = json.RawMessage(data)
+	return &s, nil
+}
@@ -0,0 +1,143 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"ecommerce-order-system/services/saga-orchestrator/internal/metrics"
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+// RunTimeouts sweeps expired step deadlines every interval until ctx is done.
+// Deadlines live in the sagas table, so a restarted orchestrator picks up
+// whatever expired while it was down.
+func (o *Orchestrator) RunTimeouts(ctx context.Context, interval time.Duration) {
+	o.Log.Info().Dur("interval", interval).Msg("saga timeout sweeper started")
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			o.Log.Info().Msg("saga timeout sweeper stopped")
+			return
+		case <-t.C:
+			for {
+				expired, err := o.ExpireOne(ctx)
+				if err != nil {
+					o.Log.Error().Err(err).Msg("saga timeout sweep failed")
+				}
+				if !expired {
+					break
+				}
+			}
+		}
+	}
+}
+
+// ExpireOne handles one saga whose step deadline has passed and reports
+// whether there was one. If handling it fails, its deadline is moved
+// TimeoutRetryDelay ahead, so it does not hold up the other expired sagas;
+// the error is returned with true.
+//
+// A running step that timed out publishes order.timed_out and starts
+// compensation with the step itself, since its command may still have been
+// applied. A compensation that timed out is sent again, as compensation
+// commands are idempotent, up to MaxCompensationAttempts times; after that
+// the saga fails.
+func (o *Orchestrator) ExpireOne(ctx context.Context) (bool, error) {
+	tx, err := o.DB.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	s, err := o.Sagas.LockExpired(ctx, tx)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// The savepoint lets the postponed deadline be committed without what
+	// was written before handling failed.
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	status, step := s.Status, s.StepName
+	if err := o.expire(ctx, sp, s); err != nil {
+		_ = sp.Rollback(ctx)
+		if perr := o.Sagas.Postpone(ctx, tx, s.OrderID, time.Now().Add(o.TimeoutRetryDelay)); perr != nil {
+			return false, errors.Join(err, perr)
+		}
+		if cerr := tx.Commit(ctx); cerr != nil {
+			return false, errors.Join(err, cerr)
+		}
+		return true, fmt.Errorf("saga %s: %w", s.OrderID, err)
+	}
+	if err := sp.Commit(ctx); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+
+	metrics.SagaStepTimeoutsTotal.WithLabelValues(o.Def.Name, step, status).Inc()
+	o.countFinished(status, s)
+	return true, nil
+}
+
+// expire handles the timeout of s in tx and saves s.
+func (o *Orchestrator) expire(ctx context.Context, tx pgx.Tx, s *Saga) error {
+	if s.Step < 0 || s.Step >= len(o.Def.Steps) {
+		return fmt.Errorf("step %d out of range", s.Step)
+	}
+	step := o.Def.Steps[s.Step]
+
+	// What is published here follows the saga's last message in the order's chain.
+	ctx = models.ContextWithLinks(ctx, models.Links{CorrelationID: s.CorrelationID, Seq: s.LastSeq})
+	s.LastSeq++
+	log := o.Log.With().Str("order_id", s.OrderID).Str("step", step.Name).Str("status", s.Status).Logger()
+
+	var err error
+	switch s.Status {
+	case StatusRunning:
+		deadline := ""
+		if s.DeadlineAt != nil {
+			deadline = s.DeadlineAt.UTC().Format(time.RFC3339)
+		}
+		if err := o.publish(ctx, tx, models.TypeOrderTimedOut, s.OrderID, models.OrderTimedOutPayload{Step: step.Name, Deadline: deadline}); err != nil {
+			return err
+		}
+		s.Status = StatusCompensating
+		s.TimedOutStep = step.Name
+		s.FailureReason = "timed out in " + step.Name
+		log.Warn().Msg("saga step timed out, compensating")
+		if step.Compensate != "" {
+			err = o.compensateStep(ctx, tx, s, s.Step)
+		} else {
+			err = o.compensate(ctx, tx, s, s.Step-1)
+		}
+
+	case StatusCompensating:
+		attempts := s.CompensationAttempts + 1
+		if attempts > o.MaxCompensationAttempts {
+			s.Status = StatusFailed
+			s.DeadlineAt = nil
+			log.Error().Int("attempts", s.CompensationAttempts).Msg("saga compensation keeps timing out, saga failed: needs manual attention")
+			break
+		}
+		log.Warn().Int("attempt", attempts).Msg("saga compensation timed out, resending")
+		err = o.compensateStep(ctx, tx, s, s.Step)
+		s.CompensationAttempts = attempts
+	}
+	if err != nil {
+		return err
+	}
+	return o.Sagas.Save(ctx, tx, s)
+}
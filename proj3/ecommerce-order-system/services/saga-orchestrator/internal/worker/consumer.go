@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"context"
+	"errors"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/rs/zerolog"
+
+	"ecommerce-order-system/services/saga-orchestrator/internal/saga"
+	"ecommerce-order-system/shared/pkg/logger"
+	"ecommerce-order-system/shared/pkg/models"
+	"ecommerce-order-system/shared/pkg/rabbit"
+)
+
+type Consumer struct {
+	Log  zerolog.Logger
+	Saga *saga.Orchestrator
+
+	RetryPub *rabbit.Publisher
+	DLQPub   *rabbit.Publisher
+
+	// Events declares the payload versions this consumer understands.
+	Events models.Decoder
+
+	Service     string
+	MaxAttempts int
+	DLQKey      string
+}
+
+func (c *Consumer) Run(ctx context.Context, deliveries <-chan amqp.Delivery) {
+	c.Log.Info().Msg("saga consumer started")
+	for {
+		select {
+		case <-ctx.Done():
+			c.Log.Info().Msg("saga consumer stopped")
+			return
+		case d, ok := <-deliveries:
+			if !ok {
+				c.Log.Info().Msg("deliveries closed")
+				return
+			}
+			c.handle(ctx, d)
+		}
+	}
+}
+
+func (c *Consumer) handle(ctx context.Context, d amqp.Delivery) {
+	evt, err := rabbit.DecodeDelivery(c.Events, d)
+	if errors.Is(err, models.ErrSkipped) {
+		_ = d.Ack(false)
+		c.Log.Debug().Str("rk", d.RoutingKey).Int("version", evt.Version).Msg("dual-published copy skipped")
+		return
+	}
+	if err != nil {
+		c.Log.Error().Err(err).Str("rk", d.RoutingKey).Msg("invalid event -> dlq")
+		_ = rabbit.RetryOrDLQ(ctx, d, c.Service, 0, c.RetryPub, c.DLQPub, c.DLQKey)
+		return
+	}
+	if evt.OrderID == "" || evt.ID == "" {
+		c.Log.Error().Str("rk", d.RoutingKey).Msg("missing order_id/event_id -> dlq")
+		_ = rabbit.RetryOrDLQ(ctx, d, c.Service, 0, c.RetryPub, c.DLQPub, c.DLQKey)
+		return
+	}
+
+	ctx = models.ContextWithEvent(ctx, evt)
+	log := logger.ForEvent(c.Log, evt)
+
+	if err := c.Saga.Handle(ctx, evt); err != nil {
+		log.Error().Err(err).Msg("saga step failed -> retry/dlq")
+		_ = rabbit.RetryOrDLQ(ctx, d, c.Service, int32(c.MaxAttempts), c.RetryPub, c.DLQPub, c.DLQKey)
+		return
+	}
+	_ = d.Ack(false)
+	log.Debug().Msg("saga event handled")
+}
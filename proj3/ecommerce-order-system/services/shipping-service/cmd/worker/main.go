@@ -2,14 +2,22 @@ package main
 
 import (
 	"context"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"ecommerce-order-system/services/shipping-service/internal/carrier"
+	httpx "ecommerce-order-system/services/shipping-service/internal/http"
+	"ecommerce-order-system/services/shipping-service/internal/repo"
 	"ecommerce-order-system/services/shipping-service/internal/worker"
 	"ecommerce-order-system/shared/pkg/config"
 	"ecommerce-order-system/shared/pkg/logger"
+	"ecommerce-order-system/shared/pkg/models"
 	"ecommerce-order-system/shared/pkg/rabbit"
+
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 func main() {
@@ -19,6 +27,14 @@ func main() {
 	}
 	log := logger.New("shipping-service", cfg.Common.LogLevel)
 
+	ctxDB, cancelDB := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelDB()
+	db, err := pgxpool.New(ctxDB, cfg.Postgres.DSN)
+	if err != nil {
+		log.Fatal().Err(err).Msg("pg connect failed")
+	}
+	defer db.Close()
+
 	rc, err := rabbit.Connect(cfg.Rabbit.URL)
 	if err != nil {
 		log.Fatal().Err(err).Msg("rabbit connect failed")
@@ -29,36 +45,73 @@ func main() {
 		log.Fatal().Err(err).Msg("declare base failed")
 	}
 
+	// Labels are cancelled on order.cancelled in both modes.
+	keys, other := cfg.Saga.Bindings(
+		[]string{models.TypePaymentAuthorized, models.TypePaymentCaptured},
+		[]string{models.CmdShippingSchedule},
+		models.TypeOrderCancelled,
+	)
+
 	if err := rabbit.DeclareQueueWithDLQ(rc.Ch, rabbit.QueueSpec{
-		Name:     "shipping.q",
-		BindKeys: []string{"payment.processed"},
-		DLQKey:   "shipping.dlq",
-		Prefetch: 20,
+		Name:       "shipping.q",
+		BindKeys:   keys,
+		UnbindKeys: other,
+		DLQKey:     "shipping.dlq",
+		Prefetch:   20,
 	}); err != nil {
 		log.Fatal().Err(err).Msg("declare shipping topology failed")
 	}
 
-	_ = rabbit.DeclareRetryQueue(rc.Ch, "shipping.retry.payment.processed.5s", "shipping.payment.processed", "payment.processed", 5000)
+	for _, k := range keys {
+		_ = rabbit.DeclareRetryQueue(rc.Ch, "shipping.retry."+k+".5s", "shipping."+k, k, 5000)
+	}
 
 	deliveries, err := rabbit.NewConsumer(rc.Ch).Consume("shipping.q", 20)
 	if err != nil {
 		log.Fatal().Err(err).Msg("consume failed")
 	}
 
+	shipments := &repo.ShipmentsPG{DB: db}
+	c, err := carrier.New(cfg.Shipping)
+	if err != nil {
+		log.Fatal().Err(err).Msg("shipping carrier failed")
+	}
 	w := &worker.Consumer{
-		Log:         log,
-		EventsPub:   rabbit.NewPublisher(rc.Ch, rabbit.ExchangeEvents),
-		RetryPub:    rabbit.NewPublisher(rc.Ch, rabbit.ExchangeRetry),
-		DLQPub:      rabbit.NewPublisher(rc.Ch, rabbit.ExchangeDLX),
-		Service:     "shipping",
-		MaxAttempts: 5,
-		DLQKey:      "shipping.dlq",
+		Log:            log,
+		EventsPub:      rabbit.NewPublisher(rc.Ch, rabbit.ExchangeEvents).UseFormat(cfg.Events.Format, "shipping-service").UseEncoding(cfg.Events.Encoding),
+		RetryPub:       rabbit.NewPublisher(rc.Ch, rabbit.ExchangeRetry),
+		DLQPub:         rabbit.NewPublisher(rc.Ch, rabbit.ExchangeDLX),
+		Shipments:      shipments,
+		Carrier:        c,
+		ServiceLevel:   cfg.Shipping.ServiceLevel,
+		OriginCountry:  cfg.Shipping.OriginCountry,
+		CarrierTimeout: cfg.Shipping.CarrierTimeout,
+		Service:        "shipping",
+		MaxAttempts:    5,
+		DLQKey:         "shipping.dlq",
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go w.Run(ctx, deliveries)
 
+	sh := &httpx.ShipmentHandlers{Shipments: shipments, Carrier: c, CarrierTimeout: cfg.Shipping.CarrierTimeout, Log: log}
+	srv := &http.Server{
+		Addr: cfg.Shipping.HTTPAddr,
+		Handler: httpx.NewRouter(&httpx.Handlers{
+			Health:      httpx.Health,
+			GetShipment: sh.GetShipment,
+		}),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		log.Info().Str("addr", srv.Addr).Msg("http started")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal().Err(err).Msg("http failed")
+		}
+	}()
+
 	log.Info().Msg("shipping worker started")
 
 	sig := make(chan os.Signal, 1)
@@ -67,4 +120,7 @@ func main() {
 
 	log.Info().Msg("shutdown")
 	cancel()
+	shCtx, shCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shCancel()
+	_ = srv.Shutdown(shCtx)
 }
@@ -0,0 +1,120 @@
+// Package carrier talks to shipping carriers: it quotes rates, buys labels,
+// cancels them and tracks parcels.
+package carrier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ecommerce-order-system/shared/pkg/config"
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+// Service levels, slowest first.
+const (
+	ServiceGround    = "ground"
+	ServiceExpress   = "express"
+	ServiceOvernight = "overnight"
+)
+
+// Tracking statuses.
+const (
+	StatusLabelCreated   = "label_created"
+	StatusInTransit      = "in_transit"
+	StatusOutForDelivery = "out_for_delivery"
+	StatusDelivered      = "delivered"
+	StatusCancelled      = "cancelled"
+)
+
+var (
+	// ErrTransient marks carrier errors worth retrying (connection reset,
+	// 5xx); wrap it to add detail.
+	ErrTransient = errors.New("carrier temporarily unavailable")
+	// ErrUnknownShipment: the carrier has no label with that tracking number.
+	ErrUnknownShipment = errors.New("unknown shipment")
+	// ErrNotCancellable: the parcel has been picked up; it can only be
+	// returned.
+	ErrNotCancellable = errors.New("shipment already picked up")
+)
+
+type Carrier interface {
+	Name() string
+	Quote(ctx context.Context, p Parcel) ([]Rate, error)
+	CreateShipment(ctx context.Context, req ShipmentRequest) (Label, error)
+	Cancel(ctx context.Context, trackingNumber string) error
+	Track(ctx context.Context, trackingNumber string) (Tracking, error)
+}
+
+// New builds the carrier named by cfg.Carrier.
+func New(cfg config.ShippingConfig) (Carrier, error) {
+	switch cfg.Carrier {
+	case config.CarrierSimulated:
+		return &Simulated{FailRate: cfg.FakeFailRate, StepDelay: cfg.FakeStepDelay}, nil
+	default:
+		return nil, fmt.Errorf("unknown shipping carrier %q", cfg.Carrier)
+	}
+}
+
+// Parcel is an order's shipment: Units items sent from the From country to
+// To, priced in Currency. To is nil for orders placed without an address.
+type Parcel struct {
+	OrderID  string
+	From     string
+	To       *models.AddressPayload
+	Units    int
+	Currency string
+}
+
+type Rate struct {
+	Carrier       string `json:"carrier"`
+	ServiceLevel  string `json:"service_level"`
+	CostCents     int    `json:"cost_cents"`
+	Currency      string `json:"currency"`
+	EstimatedDays int    `json:"estimated_days"`
+}
+
+// ShipmentRequest buys a label for Parcel at Rate. IdempotencyKey is the
+// same on every retry, so a request that timed out but went through does
+// not buy a second label.
+type ShipmentRequest struct {
+	Parcel         Parcel
+	Rate           Rate
+	IdempotencyKey string
+}
+
+// Label is a bought label; Rate is what the carrier charged.
+type Label struct {
+	TrackingNumber    string
+	LabelURL          string
+	Rate              Rate
+	EstimatedDelivery time.Time
+}
+
+type Tracking struct {
+	TrackingNumber string          `json:"tracking_number"`
+	Status         string          `json:"status"`
+	Events         []TrackingEvent `json:"events"`
+}
+
+type TrackingEvent struct {
+	Status string    `json:"status"`
+	At     time.Time `json:"at"`
+}
+
+// Choose returns the rate at level, or the cheapest one if level is not
+// offered; false if there are no rates.
+func Choose(rates []Rate, level string) (Rate, bool) {
+	var best Rate
+	found := false
+	for _, r := range rates {
+		if r.ServiceLevel == level {
+			return r, true
+		}
+		if !found || r.CostCents < best.CostCents {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,45 @@
+package carrier
+
+import (
+	"testing"
+
+	"ecommerce-order-system/shared/pkg/config"
+)
+
+func TestChoose(t *testing.T) {
+	ground := Rate{ServiceLevel: ServiceGround, CostCents: 649}
+	express := Rate{ServiceLevel: ServiceExpress, CostCents: 1399}
+	overnight := Rate{ServiceLevel: ServiceOvernight, CostCents: 2649}
+	tests := []struct {
+		name   string
+		rates  []Rate
+		level  string
+		want   Rate
+		wantOK bool
+	}{
+		{"level offered", []Rate{ground, express, overnight}, ServiceExpress, express, true},
+		{"level offered but dearer", []Rate{ground, overnight}, ServiceOvernight, overnight, true},
+		{"level missing takes cheapest", []Rate{express, ground}, ServiceOvernight, ground, true},
+		{"unknown level takes cheapest", []Rate{overnight, express}, "teleport", express, true},
+		{"single rate", []Rate{overnight}, ServiceGround, overnight, true},
+		{"no rates", nil, ServiceGround, Rate{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Choose(tt.rates, tt.level)
+			if got != tt.want || ok != tt.wantOK {
+				t.Fatalf("Choose = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	c, err := New(config.ShippingConfig{Carrier: config.CarrierSimulated})
+	if err != nil || c.Name() != "simulated" {
+		t.Fatalf("New(simulated) = %v, %v", c, err)
+	}
+	if _, err := New(config.ShippingConfig{Carrier: "pigeon"}); err == nil {
+		t.Fatal("New(pigeon) succeeded")
+	}
+}
@@ -0,0 +1,175 @@
+package carrier
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// simulatedRates are the simulated carrier's prices: Base plus PerUnit for
+// every item. International parcels cost twice as much and take five days
+// longer; overnight is domestic only.
+var simulatedRates = []struct {
+	ServiceLevel  string
+	Base, PerUnit int
+	Days          int
+	DomesticOnly  bool
+}{
+	{ServiceGround, 599, 50, 5, false},
+	{ServiceExpress, 1299, 100, 2, false},
+	{ServiceOvernight, 2499, 150, 1, true},
+}
+
+// Simulated is a local carrier. FailRate percent of calls fail with
+// ErrTransient. A parcel moves one tracking status every StepDelay from the
+// time its label was bought, which the tracking number encodes, so tracking
+// survives restarts; cancellations and idempotency keys are kept in memory.
+// Parcels without an address are treated as domestic.
+type Simulated struct {
+	FailRate  int // 0..100
+	StepDelay time.Duration
+
+	mu        sync.Mutex
+	rng       *rand.Rand
+	labels    map[string]Label     // by idempotency key
+	cancelled map[string]time.Time // by tracking number
+}
+
+func (s *Simulated) Name() string { return "simulated" }
+
+func (s *Simulated) Quote(ctx context.Context, p Parcel) ([]Rate, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	international := p.To != nil && !strings.EqualFold(p.To.Country, p.From)
+	var rates []Rate
+	for _, r := range simulatedRates {
+		if international && r.DomesticOnly {
+			continue
+		}
+		rate := Rate{
+			Carrier:       s.Name(),
+			ServiceLevel:  r.ServiceLevel,
+			CostCents:     r.Base + r.PerUnit*max(p.Units, 1),
+			Currency:      p.Currency,
+			EstimatedDays: r.Days,
+		}
+		if international {
+			rate.CostCents *= 2
+			rate.EstimatedDays += 5
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+func (s *Simulated) CreateShipment(ctx context.Context, req ShipmentRequest) (Label, error) {
+	if err := s.fail(); err != nil {
+		return Label{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, ok := s.labels[req.IdempotencyKey]; ok {
+		return l, nil
+	}
+	now := time.Now().UTC()
+	tracking := fmt.Sprintf("SIM%s%04d", strings.ToUpper(strconv.FormatInt(now.Unix(), 36)), s.rand().Intn(10000))
+	l := Label{
+		TrackingNumber:    tracking,
+		LabelURL:          "https://carrier.local/labels/" + tracking + ".pdf",
+		Rate:              req.Rate,
+		EstimatedDelivery: now.Add(time.Duration(req.Rate.EstimatedDays) * 24 * time.Hour),
+	}
+	if s.labels == nil {
+		s.labels = map[string]Label{}
+	}
+	s.labels[req.IdempotencyKey] = l
+	return l, nil
+}
+
+// Cancel voids a label until the parcel is picked up, one StepDelay after
+// the label was bought.
+func (s *Simulated) Cancel(ctx context.Context, trackingNumber string) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	created, err := labelCreated(trackingNumber)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.cancelled[trackingNumber]; ok {
+		return nil
+	}
+	if time.Since(created) >= s.StepDelay {
+		return fmt.Errorf("%w: %s", ErrNotCancellable, trackingNumber)
+	}
+	if s.cancelled == nil {
+		s.cancelled = map[string]time.Time{}
+	}
+	s.cancelled[trackingNumber] = time.Now().UTC()
+	return nil
+}
+
+func (s *Simulated) Track(ctx context.Context, trackingNumber string) (Tracking, error) {
+	if err := s.fail(); err != nil {
+		return Tracking{}, err
+	}
+	created, err := labelCreated(trackingNumber)
+	if err != nil {
+		return Tracking{}, err
+	}
+	t := Tracking{TrackingNumber: trackingNumber, Events: []TrackingEvent{{Status: StatusLabelCreated, At: created}}}
+
+	s.mu.Lock()
+	cancelledAt, cancelled := s.cancelled[trackingNumber]
+	s.mu.Unlock()
+	if cancelled {
+		t.Events = append(t.Events, TrackingEvent{Status: StatusCancelled, At: cancelledAt})
+	} else {
+		for i, status := range []string{StatusInTransit, StatusOutForDelivery, StatusDelivered} {
+			at := created.Add(time.Duration(i+1) * s.StepDelay)
+			if at.After(time.Now()) {
+				break
+			}
+			t.Events = append(t.Events, TrackingEvent{Status: status, At: at})
+		}
+	}
+	t.Status = t.Events[len(t.Events)-1].Status
+	return t, nil
+}
+
+// labelCreated reads the time a label was bought from its tracking number.
+func labelCreated(trackingNumber string) (time.Time, error) {
+	ts, ok := strings.CutPrefix(trackingNumber, "SIM")
+	if !ok || len(ts) <= 4 {
+		return time.Time{}, fmt.Errorf("%w: %s", ErrUnknownShipment, trackingNumber)
+	}
+	unix, err := strconv.ParseInt(strings.ToLower(ts[:len(ts)-4]), 36, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s", ErrUnknownShipment, trackingNumber)
+	}
+	return time.Unix(unix, 0).UTC(), nil
+}
+
+func (s *Simulated) fail() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rand().Intn(100) < s.FailRate {
+		return fmt.Errorf("%w: simulated 503", ErrTransient)
+	}
+	return nil
+}
+
+// rand must be called with mu held.
+func (s *Simulated) rand() *rand.Rand {
+	if s.rng == nil {
+		s.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return s.rng
+}
@@ -0,0 +1,48 @@
+package carrier
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLabelCreated(t *testing.T) {
+	tests := []struct {
+		name     string
+		tracking string
+		want     time.Time
+		ok       bool
+	}{
+		{"simulated", "SIMTMWS1S0042", time.Unix(1_792_000_000, 0).UTC(), true},
+		{"lower case time", "SIMtmws1s0042", time.Unix(1_792_000_000, 0).UTC(), true},
+		{"other carrier", "1Z999AA10123456784", time.Time{}, false},
+		{"no time", "SIM0042", time.Time{}, false},
+		{"not base 36", "SIM-!?0042", time.Time{}, false},
+		{"empty", "", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := labelCreated(tt.tracking)
+			if tt.ok && (err != nil || !got.Equal(tt.want)) {
+				t.Fatalf("labelCreated(%q) = %s, %v, want %s", tt.tracking, got, err, tt.want)
+			}
+			if !tt.ok && !errors.Is(err, ErrUnknownShipment) {
+				t.Fatalf("labelCreated(%q) = %v, want ErrUnknownShipment", tt.tracking, err)
+			}
+		})
+	}
+}
+
+func TestLabelCreatedReadsSimulatedLabels(t *testing.T) {
+	s := &Simulated{}
+	before := time.Now().Truncate(time.Second)
+	l, err := s.CreateShipment(context.Background(), ShipmentRequest{IdempotencyKey: "order:1"})
+	if err != nil {
+		t.Fatalf("CreateShipment: %v", err)
+	}
+	got, err := labelCreated(l.TrackingNumber)
+	if err != nil || got.Before(before) || got.After(time.Now()) {
+		t.Fatalf("labelCreated(%q) = %s, %v, want about %s", l.TrackingNumber, got, err, before)
+	}
+}
@@ -0,0 +1,68 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+
+	"ecommerce-order-system/services/shipping-service/internal/carrier"
+	"ecommerce-order-system/services/shipping-service/internal/repo"
+)
+
+func Health(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+type ShipmentHandlers struct {
+	Shipments      *repo.ShipmentsPG
+	Carrier        carrier.Carrier
+	CarrierTimeout time.Duration
+	Log            zerolog.Logger
+}
+
+type shipmentResp struct {
+	Shipment repo.Shipment     `json:"shipment"`
+	Tracking *carrier.Tracking `json:"tracking,omitempty"`
+}
+
+// GetShipment returns the order's shipment and, unless it was cancelled,
+// where the parcel is. Tracking is left out if the carrier does not answer.
+func (h *ShipmentHandlers) GetShipment(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	s, err := h.Shipments.Get(r.Context(), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Log.Error().Err(err).Str("order_id", id).Msg("get shipment failed")
+		http.Error(w, "failed to read shipment", http.StatusInternalServerError)
+		return
+	}
+
+	resp := shipmentResp{Shipment: s}
+	if s.Status == repo.ShipmentStatusCreated {
+		ctx, cancel := context.WithTimeout(r.Context(), h.CarrierTimeout)
+		t, err := h.Carrier.Track(ctx, s.TrackingNumber)
+		cancel()
+		if err != nil {
+			h.Log.Warn().Err(err).Str("order_id", id).Str("tracking", s.TrackingNumber).Msg("track shipment failed")
+		} else {
+			resp.Tracking = &t
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,25 @@
+package httpx
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"ecommerce-order-system/shared/pkg/metrics"
+)
+
+type Handlers struct {
+	Health      http.HandlerFunc
+	GetShipment http.HandlerFunc
+}
+
+func NewRouter(h *Handlers) http.Handler {
+	r := chi.NewRouter()
+	r.Use(metrics.Middleware("shipping-service"))
+	r.Handle("/metrics", promhttp.Handler())
+
+	r.Get("/health", h.Health)
+	r.Get("/api/v1/shipments/{id}", h.GetShipment)
+	return r
+}
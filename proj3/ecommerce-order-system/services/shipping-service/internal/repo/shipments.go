@@ -0,0 +1,88 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	ShipmentStatusCreated   = "created"
+	ShipmentStatusCancelled = "cancelled"
+)
+
+// Shipment is the label bought for an order. An order cancelled before a
+// label was bought has a cancelled shipment without one.
+type Shipment struct {
+	OrderID           string     `json:"order_id"`
+	Carrier           string     `json:"carrier"`
+	ServiceLevel      string     `json:"service_level"`
+	TrackingNumber    string     `json:"tracking_number"`
+	LabelURL          string     `json:"label_url,omitempty"`
+	CostCents         int        `json:"cost_cents"`
+	Currency          string     `json:"currency"`
+	Status            string     `json:"status"`
+	EstimatedDelivery *time.Time `json:"estimated_delivery,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	CancelledAt       *time.Time `json:"cancelled_at,omitempty"`
+	CancelReason      string     `json:"cancel_reason,omitempty"`
+}
+
+type ShipmentsPG struct{ DB *pgxpool.Pool }
+
+const shipmentColumns = `
+	order_id::text, coalesce(carrier, ''), coalesce(service_level, ''), coalesce(tracking_number, ''),
+	coalesce(label_url, ''), cost_cents, coalesce(currency, ''), status, estimated_delivery, created_at, cancelled_at, coalesce(cancel_reason, '')`
+
+// Get returns the order's shipment; pgx.ErrNoRows if it has none.
+func (r *ShipmentsPG) Get(ctx context.Context, orderID string) (Shipment, error) {
+	return scanShipment(r.DB.QueryRow(ctx, `select `+shipmentColumns+` from shipments where order_id = $1::uuid`, orderID))
+}
+
+// Create stores s unless the order already has a shipment and returns the
+// one stored; created reports whether it is s.
+func (r *ShipmentsPG) Create(ctx context.Context, s Shipment) (out Shipment, created bool, err error) {
+	out, err = scanShipment(r.DB.QueryRow(ctx, `
+		insert into shipments (order_id, carrier, service_level, tracking_number, label_url, cost_cents, currency, estimated_delivery)
+		values ($1::uuid, $2, $3, $4, nullif($5, ''), $6, $7, $8)
+		on conflict (order_id) do nothing
+		returning `+shipmentColumns,
+		s.OrderID, s.Carrier, s.ServiceLevel, s.TrackingNumber, s.LabelURL, s.CostCents, s.Currency, s.EstimatedDelivery))
+	if errors.Is(err, pgx.ErrNoRows) {
+		out, err = r.Get(ctx, s.OrderID)
+		return out, false, err
+	}
+	return out, err == nil, err
+}
+
+// Cancel marks the order's shipment cancelled with reason. Cancelling it
+// again is a no-op.
+func (r *ShipmentsPG) Cancel(ctx context.Context, orderID, reason string) error {
+	_, err := r.DB.Exec(ctx, `
+		update shipments set status = $2, cancelled_at = now(), cancel_reason = nullif($3, '')
+		where order_id = $1::uuid and status <> $2
+	`, orderID, ShipmentStatusCancelled, reason)
+	return err
+}
+
+// CancelUnshipped records that the order was cancelled before a label was
+// bought, unless it has a shipment already; cancelled reports whether the
+// record was made.
+func (r *ShipmentsPG) CancelUnshipped(ctx context.Context, orderID, reason string) (cancelled bool, err error) {
+	ct, err := r.DB.Exec(ctx, `
+		insert into shipments (order_id, status, cancelled_at, cancel_reason)
+		values ($1::uuid, $2, now(), nullif($3, ''))
+		on conflict (order_id) do nothing
+	`, orderID, ShipmentStatusCancelled, reason)
+	return ct.RowsAffected() == 1, err
+}
+
+func scanShipment(row pgx.Row) (Shipment, error) {
+	var s Shipment
+	err := row.Scan(&s.OrderID, &s.Carrier, &s.ServiceLevel, &s.TrackingNumber, &s.LabelURL, &s.CostCents, &s.Currency,
+		&s.Status, &s.EstimatedDelivery, &s.CreatedAt, &s.CancelledAt, &s.CancelReason)
+	return s, err
+}
@@ -3,15 +3,18 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/rs/zerolog"
 
+	"ecommerce-order-system/services/shipping-service/internal/carrier"
+	"ecommerce-order-system/services/shipping-service/internal/repo"
+	"ecommerce-order-system/shared/pkg/logger"
 	"ecommerce-order-system/shared/pkg/models"
 	"ecommerce-order-system/shared/pkg/rabbit"
-
-	"github.com/google/uuid"
 )
 
 type Consumer struct {
@@ -21,19 +24,22 @@ type Consumer struct {
 	RetryPub  *rabbit.Publisher
 	DLQPub    *rabbit.Publisher
 
+	// Events declares the payload versions this consumer understands.
+	Events models.Decoder
+
+	Shipments *repo.ShipmentsPG
+	Carrier   carrier.Carrier
+	// Labels are bought at ServiceLevel if the carrier offers it, else at
+	// the cheapest rate, for parcels sent from OriginCountry.
+	ServiceLevel   string
+	OriginCountry  string
+	CarrierTimeout time.Duration
+
 	Service     string
 	MaxAttempts int
 	DLQKey      string
 }
 
-type ShippingScheduledPayload struct {
-	Tracking string `json:"tracking"`
-}
-
-type OrderCompletedPayload struct {
-	Note string `json:"note,omitempty"`
-}
-
 func (c *Consumer) Run(ctx context.Context, deliveries <-chan amqp.Delivery) {
 	c.Log.Info().Msg("shipping consumer started")
 	for {
@@ -52,9 +58,14 @@ func (c *Consumer) Run(ctx context.Context, deliveries <-chan amqp.Delivery) {
 }
 
 func (c *Consumer) handle(ctx context.Context, d amqp.Delivery) {
-	var evt models.Event[json.RawMessage]
-	if err := json.Unmarshal(d.Body, &evt); err != nil {
-		c.Log.Error().Err(err).Str("rk", d.RoutingKey).Msg("bad json -> dlq")
+	evt, err := rabbit.DecodeDelivery(c.Events, d)
+	if errors.Is(err, models.ErrSkipped) {
+		_ = d.Ack(false)
+		c.Log.Debug().Str("rk", d.RoutingKey).Int("version", evt.Version).Msg("dual-published copy skipped")
+		return
+	}
+	if err != nil {
+		c.Log.Error().Err(err).Str("rk", d.RoutingKey).Msg("invalid event -> dlq")
 		_ = rabbit.RetryOrDLQ(ctx, d, c.Service, 0, c.RetryPub, c.DLQPub, c.DLQKey)
 		return
 	}
@@ -64,49 +75,101 @@ func (c *Consumer) handle(ctx context.Context, d amqp.Delivery) {
 		return
 	}
 
-	if d.RoutingKey != "payment.processed" {
-		c.Log.Warn().Str("rk", d.RoutingKey).Str("order_id", evt.OrderID).Msg("unexpected routing key -> ack")
+	ctx = models.ContextWithEvent(ctx, evt)
+	log := logger.ForEvent(c.Log, evt)
+
+	var out []models.Event[any]
+	switch d.RoutingKey {
+	case models.TypePaymentAuthorized, models.CmdShippingSchedule:
+		order, err := shipmentOrder(d.RoutingKey, evt.Payload)
+		if err != nil {
+			log.Error().Err(err).Msg("invalid payload -> dlq")
+			_ = rabbit.RetryOrDLQ(ctx, d, c.Service, 0, c.RetryPub, c.DLQPub, c.DLQKey)
+			return
+		}
+		if order != nil {
+			log = log.With().Int("items", len(order.Items)).Str("ship_to", shipTo(order)).Logger()
+		}
+		shipment, err := c.schedule(ctx, log, evt.OrderID, order)
+		if err != nil {
+			log.Error().Err(err).Msg("schedule shipment failed -> retry/dlq")
+			_ = rabbit.RetryOrDLQ(ctx, d, c.Service, int32(c.MaxAttempts), c.RetryPub, c.DLQPub, c.DLQKey)
+			return
+		}
+		switch {
+		case shipment.Status != repo.ShipmentStatusCancelled:
+			log = log.With().Str("carrier", shipment.Carrier).Str("tracking", shipment.TrackingNumber).Logger()
+			// the payment is captured once shipping is scheduled
+			out = append(out, models.NewEvent[any](models.TypeShippingScheduled, evt.OrderID, scheduled(shipment)))
+		case d.RoutingKey == models.CmdShippingSchedule:
+			// the saga waits for an answer to its command and compensates
+			out = append(out, models.NewEvent[any](models.TypeShippingFailed, evt.OrderID, shippingFailed(shipment)))
+		default:
+			_ = d.Ack(false)
+			log.Info().Msg("order cancelled, nothing to ship")
+			return
+		}
+	case models.TypePaymentCaptured:
+		out = append(out, models.NewEvent[any](models.TypeOrderCompleted, evt.OrderID, models.OrderCompletedPayload{Note: "done"}))
+	case models.TypeOrderCancelled:
+		var p models.OrderCancelledPayload
+		if err := json.Unmarshal(evt.Payload, &p); err != nil {
+			log.Error().Err(err).Msg("invalid payload -> dlq")
+			_ = rabbit.RetryOrDLQ(ctx, d, c.Service, 0, c.RetryPub, c.DLQPub, c.DLQKey)
+			return
+		}
+		if err := c.cancel(ctx, log, evt.OrderID, p.Reason); err != nil {
+			log.Error().Err(err).Msg("cancel shipment failed -> retry/dlq")
+			_ = rabbit.RetryOrDLQ(ctx, d, c.Service, int32(c.MaxAttempts), c.RetryPub, c.DLQPub, c.DLQKey)
+			return
+		}
+		_ = d.Ack(false)
+		log.Info().Msg("order cancellation handled")
+		return
+	default:
+		log.Warn().Str("rk", d.RoutingKey).Msg("unexpected routing key -> ack")
 		_ = d.Ack(false)
 		return
-	}
-
-	sched := models.Event[ShippingScheduledPayload]{
-		ID:      uuid.NewString(),
-		Type:    "shipping.scheduled",
-		Version: 1,
-		Time:    time.Now(),
-		OrderID: evt.OrderID,
-		Payload: ShippingScheduledPayload{Tracking: "TRK-" + evt.OrderID[:8]},
-	}
-	complete := models.Event[OrderCompletedPayload]{
-		ID:      uuid.NewString(),
-		Type:    "order.completed",
-		Version: 1,
-		Time:    time.Now(),
-		OrderID: evt.OrderID,
-		Payload: OrderCompletedPayload{Note: "done"},
 	}
 
 	pubCtx, cancel := rabbit.WithTimeout(ctx)
-	err1 := c.EventsPub.PublishJSON(pubCtx, sched.Type, sched, amqp.Table{"x-correlation-id": evt.ID})
-	err2 := c.EventsPub.PublishJSON(pubCtx, complete.Type, complete, amqp.Table{"x-correlation-id": evt.ID})
+	for _, e := range out {
+		if err = c.EventsPub.PublishJSON(pubCtx, e.Type, e, nil); err != nil {
+			break
+		}
+	}
 	cancel()
-
-	if err1 != nil || err2 != nil {
-		c.Log.Error().Err(firstErr(err1, err2)).Str("order_id", evt.OrderID).Msg("publish shipping events failed -> retry/dlq")
+	if err != nil {
+		log.Error().Err(err).Msg("publish shipping events failed -> retry/dlq")
 		_ = rabbit.RetryOrDLQ(ctx, d, c.Service, int32(c.MaxAttempts), c.RetryPub, c.DLQPub, c.DLQKey)
 		return
 	}
 
 	_ = d.Ack(false)
-	c.Log.Info().Str("order_id", evt.OrderID).Msg("shipping scheduled + order completed")
+	log.Info().Str("published", out[0].Type).Msg("shipping event handled")
 }
 
-func firstErr(errs ...error) error {
-	for _, e := range errs {
-		if e != nil {
-			return e
+// shipmentOrder returns the order carried by payment.authorized or
+// shipping.schedule; nil if the publisher predates order snapshots.
+func shipmentOrder(routingKey string, payload json.RawMessage) (*models.OrderSnapshotPayload, error) {
+	if routingKey == models.CmdShippingSchedule {
+		var cmd models.ShippingSchedulePayload
+		if err := json.Unmarshal(payload, &cmd); err != nil {
+			return nil, fmt.Errorf("shipping.schedule payload: %w", err)
 		}
+		return cmd.Order, nil
+	}
+	var authorized models.PaymentAuthorizedPayload
+	if err := json.Unmarshal(payload, &authorized); err != nil {
+		return nil, fmt.Errorf("payment.authorized payload: %w", err)
+	}
+	return authorized.Order, nil
+}
+
+// shipTo is the destination country, or "" for orders without an address.
+func shipTo(o *models.OrderSnapshotPayload) string {
+	if o.ShippingAddress == nil {
+		return ""
 	}
-	return nil
+	return o.ShippingAddress.Country
 }
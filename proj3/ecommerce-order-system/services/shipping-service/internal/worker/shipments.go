@@ -0,0 +1,130 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+
+	"ecommerce-order-system/services/shipping-service/internal/carrier"
+	"ecommerce-order-system/services/shipping-service/internal/repo"
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+// schedule buys the order's label once: a redelivered request gets the
+// stored shipment. If two deliveries race, the label of the one that lost
+// is cancelled. An order already cancelled gets its cancelled shipment and
+// no label; one bought while the cancellation was recorded is cancelled
+// again.
+func (c *Consumer) schedule(ctx context.Context, log zerolog.Logger, orderID string, order *models.OrderSnapshotPayload) (repo.Shipment, error) {
+	if s, err := c.Shipments.Get(ctx, orderID); err == nil {
+		return s, nil
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return repo.Shipment{}, err
+	}
+
+	parcel := carrier.Parcel{OrderID: orderID, From: c.OriginCountry, Units: 1, Currency: models.DefaultCurrency}
+	if order != nil {
+		parcel.To, parcel.Currency, parcel.Units = order.ShippingAddress, order.Currency, 0
+		for _, it := range order.Items {
+			parcel.Units += it.Qty
+		}
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, c.CarrierTimeout)
+	defer cancel()
+	rates, err := c.Carrier.Quote(cctx, parcel)
+	if err != nil {
+		return repo.Shipment{}, fmt.Errorf("quote: %w", err)
+	}
+	rate, ok := carrier.Choose(rates, c.ServiceLevel)
+	if !ok {
+		return repo.Shipment{}, fmt.Errorf("carrier %s offers no rate for order %s", c.Carrier.Name(), orderID)
+	}
+	label, err := c.Carrier.CreateShipment(cctx, carrier.ShipmentRequest{Parcel: parcel, Rate: rate, IdempotencyKey: "order:" + orderID})
+	if err != nil {
+		return repo.Shipment{}, fmt.Errorf("create shipment: %w", err)
+	}
+
+	s, created, err := c.Shipments.Create(ctx, repo.Shipment{
+		OrderID:           orderID,
+		Carrier:           label.Rate.Carrier,
+		ServiceLevel:      label.Rate.ServiceLevel,
+		TrackingNumber:    label.TrackingNumber,
+		LabelURL:          label.LabelURL,
+		CostCents:         label.Rate.CostCents,
+		Currency:          label.Rate.Currency,
+		EstimatedDelivery: &label.EstimatedDelivery,
+	})
+	if err != nil {
+		return repo.Shipment{}, err
+	}
+	if !created && s.TrackingNumber != label.TrackingNumber {
+		if err := c.Carrier.Cancel(cctx, label.TrackingNumber); err != nil {
+			log.Warn().Err(err).Str("tracking", label.TrackingNumber).Msg("cancel duplicate label failed")
+		}
+	}
+	return s, nil
+}
+
+// cancel voids the order's label. An order cancelled before its label was
+// bought gets a cancelled shipment instead, so a later schedule request
+// buys none. A parcel already picked up is left to be returned; the
+// shipment stays as it is.
+func (c *Consumer) cancel(ctx context.Context, log zerolog.Logger, orderID, reason string) error {
+	s, err := c.Shipments.Get(ctx, orderID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		cancelled, err := c.Shipments.CancelUnshipped(ctx, orderID, reason)
+		if err != nil || cancelled {
+			return err
+		}
+		// a label was bought in the meantime
+		s, err = c.Shipments.Get(ctx, orderID)
+	}
+	if err != nil {
+		return err
+	}
+	if s.Status == repo.ShipmentStatusCancelled {
+		return nil
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, c.CarrierTimeout)
+	defer cancel()
+	err = c.Carrier.Cancel(cctx, s.TrackingNumber)
+	switch {
+	case errors.Is(err, carrier.ErrNotCancellable):
+		log.Warn().Err(err).Str("tracking", s.TrackingNumber).Msg("order cancelled after pickup, parcel needs a return")
+		return nil
+	case err != nil && !errors.Is(err, carrier.ErrUnknownShipment):
+		return fmt.Errorf("cancel shipment: %w", err)
+	}
+	return c.Shipments.Cancel(ctx, orderID, reason)
+}
+
+// scheduled is the shipping.scheduled payload for s.
+func scheduled(s repo.Shipment) models.ShippingScheduledPayload {
+	p := models.ShippingScheduledPayload{
+		Tracking:     s.TrackingNumber,
+		Carrier:      s.Carrier,
+		ServiceLevel: s.ServiceLevel,
+		CostCents:    s.CostCents,
+		Currency:     s.Currency,
+		LabelURL:     s.LabelURL,
+	}
+	if s.EstimatedDelivery != nil {
+		p.EstimatedDelivery = s.EstimatedDelivery.UTC().Format(time.RFC3339)
+	}
+	return p
+}
+
+// shippingFailed answers a schedule command for a cancelled order.
+func shippingFailed(s repo.Shipment) models.ShippingFailedPayload {
+	reason := "order cancelled"
+	if s.CancelReason != "" {
+		reason += ": " + s.CancelReason
+	}
+	return models.ShippingFailedPayload{Reason: reason}
+}
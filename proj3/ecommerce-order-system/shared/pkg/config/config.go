@@ -2,6 +2,11 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
+	"path"
+	"slices"
+	"strings"
+	"time"
 
 	env "github.com/caarlos0/env/v11"
 )
@@ -12,6 +17,21 @@ type CommonConfig struct {
 
 type HTTPConfig struct {
 	Addr string `env:"HTTP_ADDR" envDefault:":8080"`
+
+	// ClientCountryHeader carries the client's country as resolved by the
+	// edge proxy (GeoIP). It is only believed from peers in TrustedProxies
+	// (CIDRs); from anyone else the country is left unknown.
+	ClientCountryHeader string   `env:"HTTP_CLIENT_COUNTRY_HEADER" envDefault:"X-Client-Country"`
+	TrustedProxies      []string `env:"HTTP_TRUSTED_PROXIES" envSeparator:","`
+}
+
+func (c HTTPConfig) Validate() error {
+	for _, p := range c.TrustedProxies {
+		if _, err := netip.ParsePrefix(strings.TrimSpace(p)); err != nil {
+			return fmt.Errorf("http trusted proxy %q is not a CIDR: %w", p, err)
+		}
+	}
+	return nil
 }
 
 type PostgresConfig struct {
@@ -25,16 +45,488 @@ type RabbitConfig struct {
 
 type OrderStatusConfig struct {
 	URL string `env:"ORDER_STATUS_URL" envDefault:"http://order-status-service:8090"`
+
+	// ReorderTimeout is how long an event that arrived before its cause is
+	// held back; after that it is applied anyway (the cause was probably lost).
+	ReorderTimeout       time.Duration `env:"ORDER_STATUS_REORDER_TIMEOUT" envDefault:"30s"`
+	ReorderSweepInterval time.Duration `env:"ORDER_STATUS_REORDER_SWEEP_INTERVAL" envDefault:"5s"`
+}
+
+func (c OrderStatusConfig) Validate() error {
+	if c.ReorderTimeout <= 0 {
+		return fmt.Errorf("order status reorder timeout must be > 0, got %s", c.ReorderTimeout)
+	}
+	if c.ReorderSweepInterval <= 0 {
+		return fmt.Errorf("order status reorder sweep interval must be > 0, got %s", c.ReorderSweepInterval)
+	}
+	return nil
 }
 
 type PaymentConfig struct {
 	FailRate int `env:"PAYMENT_FAIL_RATE" envDefault:"30"`
+
+	// AuthTTL is how long an authorization holds funds; uncaptured ones are
+	// expired by a sweep every AuthSweepInterval.
+	AuthTTL           time.Duration `env:"PAYMENT_AUTH_TTL" envDefault:"168h"`
+	AuthSweepInterval time.Duration `env:"PAYMENT_AUTH_SWEEP_INTERVAL" envDefault:"30s"`
+
+	// The provider fee on each capture: FeeBasisPoints of the amount (1/100
+	// of a percent) plus FeeFixedCents. It is booked in the ledger.
+	FeeBasisPoints int `env:"PAYMENT_FEE_BASIS_POINTS" envDefault:"290"`
+	FeeFixedCents  int `env:"PAYMENT_FEE_FIXED_CENTS" envDefault:"30"`
+
+	HTTPAddr string `env:"PAYMENT_HTTP_ADDR" envDefault:":8097"`
+
+	// Authorizations the provider confirms by webhook fail if it has not
+	// within PendingTTL. Webhooks are signed with WebhookSecret and refused
+	// when their timestamp is further than WebhookTolerance from now.
+	PendingTTL       time.Duration `env:"PAYMENT_PENDING_TTL" envDefault:"15m"`
+	WebhookSecret    string        `env:"PAYMENT_WEBHOOK_SECRET"`
+	WebhookTolerance time.Duration `env:"PAYMENT_WEBHOOK_TOLERANCE" envDefault:"5m"`
+
+	// Provider calls time out after ProviderTimeout. Timeouts and transient
+	// errors are tried up to ProviderMaxTries times per attempt, each time
+	// after a pass through the retry queue. A soft decline leaves RetryWindow
+	// for a retry with another payment method.
+	ProviderTimeout  time.Duration `env:"PAYMENT_PROVIDER_TIMEOUT" envDefault:"3s"`
+	ProviderMaxTries int           `env:"PAYMENT_PROVIDER_MAX_TRIES" envDefault:"3"`
+	RetryWindow      time.Duration `env:"PAYMENT_RETRY_WINDOW" envDefault:"30m"`
+
+	// The local fake provider, in percent of calls: FailRate hard declines,
+	// FakeSoftDeclineRate soft declines, FakeTransientRate transient errors,
+	// FakeTimeoutRate calls that never answer, FakeActionRate 3-D Secure and
+	// FakePendingRate pending ones; the last two are confirmed at
+	// FakeWebhookURL after FakeConfirmDelay.
+	FakeSoftDeclineRate int           `env:"PAYMENT_FAKE_SOFT_DECLINE_RATE" envDefault:"10"`
+	FakeTransientRate   int           `env:"PAYMENT_FAKE_TRANSIENT_RATE" envDefault:"10"`
+	FakeTimeoutRate     int           `env:"PAYMENT_FAKE_TIMEOUT_RATE" envDefault:"2"`
+	FakeActionRate      int           `env:"PAYMENT_FAKE_ACTION_RATE" envDefault:"20"`
+	FakePendingRate     int           `env:"PAYMENT_FAKE_PENDING_RATE" envDefault:"10"`
+	FakeConfirmDelay    time.Duration `env:"PAYMENT_FAKE_CONFIRM_DELAY" envDefault:"5s"`
+	FakeWebhookURL      string        `env:"PAYMENT_FAKE_WEBHOOK_URL" envDefault:"http://localhost:8097/webhooks/psp"`
+
+	// AdminToken is required as a bearer token to issue and read gift cards
+	// and store credit; the balances API is off while it is unset.
+	AdminToken string `env:"PAYMENT_ADMIN_TOKEN"`
+}
+
+func (c PaymentConfig) Validate() error {
+	if c.AuthTTL <= 0 {
+		return fmt.Errorf("payment auth ttl must be > 0, got %s", c.AuthTTL)
+	}
+	if c.AuthSweepInterval <= 0 {
+		return fmt.Errorf("payment auth sweep interval must be > 0, got %s", c.AuthSweepInterval)
+	}
+	if c.FeeBasisPoints < 0 || c.FeeBasisPoints > 10000 {
+		return fmt.Errorf("payment fee basis points must be in 0..10000, got %d", c.FeeBasisPoints)
+	}
+	if c.FeeFixedCents < 0 {
+		return fmt.Errorf("payment fixed fee must be >= 0, got %d", c.FeeFixedCents)
+	}
+	if c.PendingTTL <= 0 {
+		return fmt.Errorf("payment pending ttl must be > 0, got %s", c.PendingTTL)
+	}
+	if c.WebhookSecret == "" {
+		return fmt.Errorf("payment webhook secret is empty: set PAYMENT_WEBHOOK_SECRET")
+	}
+	if c.WebhookTolerance <= 0 {
+		return fmt.Errorf("payment webhook tolerance must be > 0, got %s", c.WebhookTolerance)
+	}
+	if c.ProviderTimeout <= 0 {
+		return fmt.Errorf("payment provider timeout must be > 0, got %s", c.ProviderTimeout)
+	}
+	if c.ProviderMaxTries < 1 {
+		return fmt.Errorf("payment provider max tries must be >= 1, got %d", c.ProviderMaxTries)
+	}
+	if c.RetryWindow <= 0 {
+		return fmt.Errorf("payment retry window must be > 0, got %s", c.RetryWindow)
+	}
+	rates := []int{c.FailRate, c.FakeSoftDeclineRate, c.FakeTransientRate, c.FakeTimeoutRate, c.FakeActionRate, c.FakePendingRate}
+	sum := 0
+	for _, r := range rates {
+		if r < 0 {
+			return fmt.Errorf("payment fail and fake rates must be >= 0, got %v", rates)
+		}
+		sum += r
+	}
+	if sum > 100 {
+		return fmt.Errorf("payment fail and fake rates must add up to at most 100, got %v", rates)
+	}
+	if c.FakeConfirmDelay <= 0 {
+		return fmt.Errorf("payment fake confirm delay must be > 0, got %s", c.FakeConfirmDelay)
+	}
+	return nil
+}
+
+// FraudConfig configures fraud-service. The rules file is re-read whenever
+// its modification time changes, checked every RulesReloadInterval.
+type FraudConfig struct {
+	RulesFile           string        `env:"FRAUD_RULES_FILE" envDefault:"/etc/fraud/rules.json"`
+	RulesReloadInterval time.Duration `env:"FRAUD_RULES_RELOAD_INTERVAL" envDefault:"5s"`
+	HTTPAddr            string        `env:"FRAUD_HTTP_ADDR" envDefault:":8098"`
+
+	// HoldTimeout is how long an order waits for manual review before it is
+	// rejected automatically; expired holds are looked for every
+	// HoldSweepInterval.
+	HoldTimeout       time.Duration `env:"FRAUD_HOLD_TIMEOUT" envDefault:"24h"`
+	HoldSweepInterval time.Duration `env:"FRAUD_HOLD_SWEEP_INTERVAL" envDefault:"30s"`
+	// ReviewerTokens maps each reviewer to the bearer token they use with the
+	// holds API, e.g. "alice:tok1,bob:tok2"; the reviewer recorded on a hold
+	// is the one whose token was sent. Without any the API is off.
+	ReviewerTokens map[string]string `env:"FRAUD_REVIEWER_TOKENS" envSeparator:"," envKeyValSeparator:":"`
+}
+
+func (c FraudConfig) Validate() error {
+	if c.RulesFile == "" {
+		return fmt.Errorf("fraud rules file is empty: set FRAUD_RULES_FILE")
+	}
+	if c.RulesReloadInterval <= 0 {
+		return fmt.Errorf("fraud rules reload interval must be > 0, got %s", c.RulesReloadInterval)
+	}
+	if c.HoldTimeout <= 0 {
+		return fmt.Errorf("fraud hold timeout must be > 0, got %s", c.HoldTimeout)
+	}
+	if c.HoldSweepInterval <= 0 {
+		return fmt.Errorf("fraud hold sweep interval must be > 0, got %s", c.HoldSweepInterval)
+	}
+	for name, token := range c.ReviewerTokens {
+		if name == "" || token == "" {
+			return fmt.Errorf("fraud reviewer tokens need a name and a token each, got %q:%q", name, token)
+		}
+	}
+	return nil
+}
+
+// ShippingConfig configures shipping-service. Carrier names the carrier
+// integration; labels are bought at ServiceLevel, or at the cheapest rate if
+// the carrier does not offer it, for parcels sent from OriginCountry.
+// Carrier calls time out after CarrierTimeout.
+type ShippingConfig struct {
+	Carrier        string        `env:"SHIPPING_CARRIER" envDefault:"simulated"`
+	ServiceLevel   string        `env:"SHIPPING_SERVICE_LEVEL" envDefault:"ground"`
+	OriginCountry  string        `env:"SHIPPING_ORIGIN_COUNTRY" envDefault:"US"`
+	CarrierTimeout time.Duration `env:"SHIPPING_CARRIER_TIMEOUT" envDefault:"5s"`
+	HTTPAddr       string        `env:"SHIPPING_HTTP_ADDR" envDefault:":8099"`
+
+	// The simulated carrier fails FakeFailRate percent of calls with a
+	// transient error and moves a parcel one tracking status every
+	// FakeStepDelay.
+	FakeFailRate  int           `env:"SHIPPING_FAKE_FAIL_RATE" envDefault:"0"`
+	FakeStepDelay time.Duration `env:"SHIPPING_FAKE_STEP_DELAY" envDefault:"1m"`
+}
+
+// Shipping carriers accepted by ShippingConfig.Carrier.
+const (
+	CarrierSimulated = "simulated"
+)
+
+func (c ShippingConfig) Validate() error {
+	switch c.Carrier {
+	case CarrierSimulated:
+	default:
+		return fmt.Errorf("unknown shipping carrier %q (want simulated)", c.Carrier)
+	}
+	if len(c.OriginCountry) != 2 {
+		return fmt.Errorf("shipping origin country must be ISO 3166 alpha-2, got %q", c.OriginCountry)
+	}
+	if c.CarrierTimeout <= 0 {
+		return fmt.Errorf("shipping carrier timeout must be > 0, got %s", c.CarrierTimeout)
+	}
+	if c.FakeFailRate < 0 || c.FakeFailRate > 100 {
+		return fmt.Errorf("shipping fake fail rate must be in 0..100, got %d", c.FakeFailRate)
+	}
+	if c.FakeStepDelay <= 0 {
+		return fmt.Errorf("shipping fake step delay must be > 0, got %s", c.FakeStepDelay)
+	}
+	return nil
 }
 
 type OutboxHTTPConfig struct {
 	Addr string `env:"OUTBOX_HTTP_ADDR" envDefault:":8085"`
 }
 
+type EventStoreConfig struct {
+	HTTPAddr string `env:"EVENT_STORE_HTTP_ADDR" envDefault:":8095"`
+}
+
+// SagaConfig selects how the order flow is coordinated. In choreography mode
+// each service reacts to the previous service's event; in orchestration mode
+// saga-orchestrator sends commands and the services only answer them.
+type SagaConfig struct {
+	Mode        string        `env:"SAGA_MODE" envDefault:"orchestration"`
+	HTTPAddr    string        `env:"SAGA_HTTP_ADDR" envDefault:":8096"`
+	StepTimeout time.Duration `env:"SAGA_STEP_TIMEOUT" envDefault:"30s"`
+	// StepTimeouts overrides StepTimeout per step name,
+	// e.g. "authorize_payment:5m,schedule_shipping:10m".
+	StepTimeouts map[string]time.Duration `env:"SAGA_STEP_TIMEOUTS" envSeparator:"," envKeyValSeparator:":"`
+	// TimeoutPollInterval is how often expired step deadlines are looked for.
+	TimeoutPollInterval time.Duration `env:"SAGA_TIMEOUT_POLL_INTERVAL" envDefault:"1s"`
+	// A timed-out compensation is sent again at most MaxCompensationAttempts
+	// times before the saga fails; a timeout that cannot be handled is
+	// retried after TimeoutRetryDelay.
+	MaxCompensationAttempts int           `env:"SAGA_MAX_COMPENSATION_ATTEMPTS" envDefault:"5"`
+	TimeoutRetryDelay       time.Duration `env:"SAGA_TIMEOUT_RETRY_DELAY" envDefault:"30s"`
+}
+
+const (
+	SagaChoreography  = "choreography"
+	SagaOrchestration = "orchestration"
+)
+
+func (c SagaConfig) Validate() error {
+	switch c.Mode {
+	case SagaChoreography, SagaOrchestration:
+	default:
+		return fmt.Errorf("unknown saga mode %q (want choreography or orchestration)", c.Mode)
+	}
+	if c.StepTimeout <= 0 {
+		return fmt.Errorf("saga step timeout must be > 0, got %s", c.StepTimeout)
+	}
+	for step, d := range c.StepTimeouts {
+		if d <= 0 {
+			return fmt.Errorf("saga step timeout for %q must be > 0, got %s", step, d)
+		}
+	}
+	if c.TimeoutPollInterval <= 0 {
+		return fmt.Errorf("saga timeout poll interval must be > 0, got %s", c.TimeoutPollInterval)
+	}
+	if c.MaxCompensationAttempts < 1 {
+		return fmt.Errorf("saga max compensation attempts must be >= 1, got %d", c.MaxCompensationAttempts)
+	}
+	if c.TimeoutRetryDelay <= 0 {
+		return fmt.Errorf("saga timeout retry delay must be > 0, got %s", c.TimeoutRetryDelay)
+	}
+	return nil
+}
+
+// Bindings returns the routing keys a saga participant binds in this mode and
+// the other mode's keys, which it unbinds: choreography reacts to the
+// previous step's events, orchestration answers saga-orchestrator commands.
+// always is bound in both modes.
+func (c SagaConfig) Bindings(choreography, orchestration []string, always ...string) (bind, unbind []string) {
+	bind, unbind = choreography, orchestration
+	if c.Mode == SagaOrchestration {
+		bind, unbind = orchestration, choreography
+	}
+	return append(slices.Clone(bind), always...), slices.Clone(unbind)
+}
+
+type OutboxConfig struct {
+	PollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" envDefault:"500ms"`
+	BatchSize    int           `env:"OUTBOX_BATCH_SIZE" envDefault:"50"`
+	MaxAttempts  int           `env:"OUTBOX_MAX_ATTEMPTS" envDefault:"10"`
+	RetryPolicy  string        `env:"OUTBOX_RETRY_POLICY" envDefault:"exponential"`
+	BackoffBase  time.Duration `env:"OUTBOX_BACKOFF_BASE" envDefault:"1s"`
+	BackoffMax   time.Duration `env:"OUTBOX_BACKOFF_MAX" envDefault:"60s"`
+	// AdminToken is required as a bearer token by the config API, which is
+	// off while it is unset.
+	AdminToken string `env:"OUTBOX_ADMIN_TOKEN"`
+}
+
+// Outbox retry policies accepted by OutboxConfig.RetryPolicy.
+const (
+	RetryExponential = "exponential"
+	RetryFixed       = "fixed"
+	RetryLinear      = "linear"
+)
+
+func (c OutboxConfig) Validate() error {
+	if c.PollInterval < 10*time.Millisecond {
+		return fmt.Errorf("outbox poll interval must be >= 10ms, got %s", c.PollInterval)
+	}
+	if c.BatchSize < 1 || c.BatchSize > 1000 {
+		return fmt.Errorf("outbox batch size must be in 1..1000, got %d", c.BatchSize)
+	}
+	if c.MaxAttempts < 1 {
+		return fmt.Errorf("outbox max attempts must be >= 1, got %d", c.MaxAttempts)
+	}
+	switch c.RetryPolicy {
+	case RetryExponential, RetryFixed, RetryLinear:
+	default:
+		return fmt.Errorf("unknown outbox retry policy %q (want exponential, fixed or linear)", c.RetryPolicy)
+	}
+	if c.BackoffBase <= 0 {
+		return fmt.Errorf("outbox backoff base must be > 0, got %s", c.BackoffBase)
+	}
+	if c.BackoffMax < c.BackoffBase {
+		return fmt.Errorf("outbox backoff max (%s) must be >= backoff base (%s)", c.BackoffMax, c.BackoffBase)
+	}
+	return nil
+}
+
+type OutboxSinksConfig struct {
+	// Default lists the sinks used for event types that match no route.
+	Default []string `env:"OUTBOX_DEFAULT_SINKS" envSeparator:"," envDefault:"rabbit"`
+	// Routes maps event type patterns (path.Match syntax, e.g. "order.*") to
+	// "+"-separated sink names: OUTBOX_ROUTES="order.*:rabbit+webhook,payment.*:rabbit".
+	Routes map[string]string `env:"OUTBOX_ROUTES" envSeparator:"," envKeyValSeparator:":"`
+
+	WebhookURL     string        `env:"OUTBOX_WEBHOOK_URL"`
+	WebhookSecret  string        `env:"OUTBOX_WEBHOOK_SECRET"`
+	WebhookTimeout time.Duration `env:"OUTBOX_WEBHOOK_TIMEOUT" envDefault:"5s"`
+
+	FilePath string `env:"OUTBOX_FILE_PATH" envDefault:"outbox-events.ndjson"`
+	// The memory sink keeps only the last MemoryLimit messages.
+	MemoryLimit int `env:"OUTBOX_MEMORY_LIMIT" envDefault:"1000"`
+}
+
+// Outbox sink names accepted in OutboxSinksConfig.
+const (
+	SinkRabbit  = "rabbit"
+	SinkWebhook = "webhook"
+	SinkFile    = "file"
+	SinkMemory  = "memory"
+)
+
+// SinkNames returns every sink referenced by Default and Routes.
+func (c OutboxSinksConfig) SinkNames() []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(name string) {
+		name = strings.TrimSpace(name)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	for _, n := range c.Default {
+		add(n)
+	}
+	for _, v := range c.Routes {
+		for _, n := range strings.Split(v, "+") {
+			add(n)
+		}
+	}
+	return out
+}
+
+func (c OutboxSinksConfig) Validate() error {
+	if len(c.Default) == 0 {
+		return fmt.Errorf("outbox default sinks are empty: set OUTBOX_DEFAULT_SINKS")
+	}
+	if c.MemoryLimit < 1 {
+		return fmt.Errorf("outbox memory limit must be >= 1, got %d", c.MemoryLimit)
+	}
+	for pattern := range c.Routes {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad outbox route pattern %q: %w", pattern, err)
+		}
+	}
+	for _, name := range c.SinkNames() {
+		switch name {
+		case SinkRabbit, SinkFile, SinkMemory:
+		case SinkWebhook:
+			if c.WebhookURL == "" {
+				return fmt.Errorf("outbox webhook sink is used but OUTBOX_WEBHOOK_URL is empty")
+			}
+		default:
+			return fmt.Errorf("unknown outbox sink %q (want rabbit, webhook, file or memory)", name)
+		}
+	}
+	return nil
+}
+
+type OutboxCoordConfig struct {
+	Mode              string        `env:"OUTBOX_COORDINATION" envDefault:"none"`
+	ReplicaID         string        `env:"OUTBOX_REPLICA_ID"`
+	LeaderLockKey     int64         `env:"OUTBOX_LEADER_LOCK_KEY" envDefault:"4242001"`
+	HeartbeatInterval time.Duration `env:"OUTBOX_HEARTBEAT_INTERVAL" envDefault:"2s"`
+	MemberTTL         time.Duration `env:"OUTBOX_MEMBER_TTL" envDefault:"10s"`
+}
+
+// Outbox coordination modes accepted by OutboxCoordConfig.Mode.
+const (
+	CoordNone   = "none"
+	CoordLeader = "leader"
+	CoordShard  = "shard"
+)
+
+func (c OutboxCoordConfig) Validate() error {
+	switch c.Mode {
+	case CoordNone, CoordLeader, CoordShard:
+	default:
+		return fmt.Errorf("unknown outbox coordination mode %q (want none, leader or shard)", c.Mode)
+	}
+	if c.HeartbeatInterval <= 0 {
+		return fmt.Errorf("outbox heartbeat interval must be > 0, got %s", c.HeartbeatInterval)
+	}
+	if c.MemberTTL < 2*c.HeartbeatInterval {
+		return fmt.Errorf("outbox member ttl (%s) must be at least twice the heartbeat interval (%s)", c.MemberTTL, c.HeartbeatInterval)
+	}
+	return nil
+}
+
+type OutboxRelayConfig struct {
+	Mode           string        `env:"OUTBOX_RELAY" envDefault:"poll"`
+	Slot           string        `env:"OUTBOX_CDC_SLOT" envDefault:"outbox_relay"`
+	Publication    string        `env:"OUTBOX_CDC_PUBLICATION" envDefault:"outbox_pub"`
+	StatusInterval time.Duration `env:"OUTBOX_CDC_STATUS_INTERVAL" envDefault:"10s"`
+}
+
+// Outbox relay modes accepted by OutboxRelayConfig.Mode.
+const (
+	RelayPoll = "poll"
+	RelayCDC  = "cdc"
+)
+
+func (c OutboxRelayConfig) Validate() error {
+	switch c.Mode {
+	case RelayPoll:
+		return nil
+	case RelayCDC:
+	default:
+		return fmt.Errorf("unknown outbox relay mode %q (want poll or cdc)", c.Mode)
+	}
+	if c.Slot == "" || c.Publication == "" {
+		return fmt.Errorf("outbox cdc relay needs OUTBOX_CDC_SLOT and OUTBOX_CDC_PUBLICATION")
+	}
+	if c.StatusInterval <= 0 {
+		return fmt.Errorf("outbox cdc status interval must be > 0, got %s", c.StatusInterval)
+	}
+	return nil
+}
+
+type EventsConfig struct {
+	// DualPublish lists, per event type, an extra payload version to publish
+	// alongside the current one during a migration: EVENTS_DUAL_PUBLISH="orders.created:1".
+	DualPublish map[string]int `env:"EVENTS_DUAL_PUBLISH" envSeparator:"," envKeyValSeparator:":"`
+
+	// Format is the content mode for messages published to orders.events.
+	// Consumers accept all of them regardless of this setting.
+	Format string `env:"EVENTS_FORMAT" envDefault:"legacy"`
+	// Encoding of legacy envelopes: json or protobuf (application/x-protobuf).
+	Encoding string `env:"EVENTS_ENCODING" envDefault:"json"`
+}
+
+const (
+	EventsFormatLegacy            = "legacy"
+	EventsFormatCloudEvents       = "cloudevents"
+	EventsFormatCloudEventsBinary = "cloudevents-binary"
+)
+
+const (
+	EventsEncodingJSON     = "json"
+	EventsEncodingProtobuf = "protobuf"
+)
+
+func (c EventsConfig) Validate() error {
+	switch c.Format {
+	case EventsFormatLegacy, EventsFormatCloudEvents, EventsFormatCloudEventsBinary:
+	default:
+		return fmt.Errorf("unknown events format %q (want legacy, cloudevents or cloudevents-binary)", c.Format)
+	}
+	switch c.Encoding {
+	case EventsEncodingJSON:
+	case EventsEncodingProtobuf:
+		if c.Format != EventsFormatLegacy {
+			return fmt.Errorf("events encoding protobuf needs EVENTS_FORMAT=legacy, got %q", c.Format)
+		}
+	default:
+		return fmt.Errorf("unknown events encoding %q (want json or protobuf)", c.Encoding)
+	}
+	return nil
+}
+
 type Config struct {
 	Common      CommonConfig
 	HTTP        HTTPConfig
@@ -42,7 +534,39 @@ type Config struct {
 	Rabbit      RabbitConfig
 	OrderStatus OrderStatusConfig
 	Payment     PaymentConfig
+	Fraud       FraudConfig
+	Shipping    ShippingConfig
 	OutboxHTTP  OutboxHTTPConfig
+	Outbox      OutboxConfig
+	OutboxSinks OutboxSinksConfig
+	OutboxCoord OutboxCoordConfig
+	OutboxRelay OutboxRelayConfig
+	Events      EventsConfig
+	EventStore  EventStoreConfig
+	Saga        SagaConfig
+}
+
+// ValidateOutboxWorker checks the OUTBOX_* settings. Only outbox-worker
+// calls it, so a bad outbox variable in the shared environment does not
+// stop the other services.
+func (c Config) ValidateOutboxWorker() error {
+	if err := c.Outbox.Validate(); err != nil {
+		return err
+	}
+	if err := c.OutboxSinks.Validate(); err != nil {
+		return err
+	}
+	if err := c.OutboxCoord.Validate(); err != nil {
+		return err
+	}
+	if err := c.OutboxRelay.Validate(); err != nil {
+		return err
+	}
+	// one replication slot has one reader: replicas can only take turns
+	if c.OutboxRelay.Mode == RelayCDC && c.OutboxCoord.Mode == CoordShard {
+		return fmt.Errorf("outbox cdc relay cannot be sharded: use OUTBOX_COORDINATION=leader or none")
+	}
+	return nil
 }
 
 func Load() (Config, error) {
@@ -56,5 +580,26 @@ func Load() (Config, error) {
 	if cfg.Postgres.DSN == "" {
 		return Config{}, fmt.Errorf("postgres dsn is empty: set POSTGRES_DSN (or legacy PG_DSN)")
 	}
+	if err := cfg.HTTP.Validate(); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Events.Validate(); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Saga.Validate(); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.OrderStatus.Validate(); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Payment.Validate(); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Fraud.Validate(); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Shipping.Validate(); err != nil {
+		return Config{}, err
+	}
 	return cfg, nil
 }
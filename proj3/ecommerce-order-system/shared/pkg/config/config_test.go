@@ -0,0 +1,48 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSagaBindings(t *testing.T) {
+	choreo := []string{"inventory.reserved"}
+	orch := []string{"fraud.screen"}
+	tests := []struct {
+		name       string
+		mode       string
+		always     []string
+		bind, drop []string
+	}{
+		{"choreography", SagaChoreography, nil, []string{"inventory.reserved"}, []string{"fraud.screen"}},
+		{"orchestration", SagaOrchestration, nil, []string{"fraud.screen"}, []string{"inventory.reserved"}},
+		{"always in choreography", SagaChoreography, []string{"orders.created"}, []string{"inventory.reserved", "orders.created"}, []string{"fraud.screen"}},
+		{"always in orchestration", SagaOrchestration, []string{"orders.created"}, []string{"fraud.screen", "orders.created"}, []string{"inventory.reserved"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bind, unbind := SagaConfig{Mode: tt.mode}.Bindings(choreo, orch, tt.always...)
+			if !slices.Equal(bind, tt.bind) || !slices.Equal(unbind, tt.drop) {
+				t.Fatalf("Bindings = %v, %v; want %v, %v", bind, unbind, tt.bind, tt.drop)
+			}
+		})
+	}
+	if len(choreo) != 1 || len(orch) != 1 {
+		t.Fatalf("Bindings modified its arguments: %v, %v", choreo, orch)
+	}
+}
+
+func TestLoadLeavesOutboxToOutboxWorker(t *testing.T) {
+	t.Setenv("POSTGRES_DSN", "postgres://localhost/orders")
+	t.Setenv("PAYMENT_WEBHOOK_SECRET", "whsec_test")
+	t.Setenv("FRAUD_REVIEWER_TOKENS", "alice:tok-a")
+	t.Setenv("OUTBOX_RETRY_POLICY", "sometimes")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load with a bad outbox setting: %v", err)
+	}
+	if err := cfg.ValidateOutboxWorker(); err == nil {
+		t.Fatal("ValidateOutboxWorker accepted OUTBOX_RETRY_POLICY=sometimes")
+	}
+}
@@ -0,0 +1,68 @@
+package eventstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Projection is a read model that can be rebuilt from the store.
+type Projection interface {
+	Name() string
+	// Reset clears the projection's state for one order before replay.
+	Reset(ctx context.Context, tx pgx.Tx, orderID string) error
+	// Apply folds one stored event into the projection.
+	Apply(ctx context.Context, tx pgx.Tx, rec Record) error
+}
+
+type ReplayStats struct {
+	Orders int
+	Events int
+}
+
+// Replay rebuilds p for orderIDs (every stored order when empty). Each order
+// is reset and replayed in its own transaction, so a failure leaves the
+// already rebuilt orders in place and the failing one untouched.
+func (s *StorePG) Replay(ctx context.Context, p Projection, orderIDs []string) (ReplayStats, error) {
+	var stats ReplayStats
+	if len(orderIDs) == 0 {
+		ids, err := s.OrderIDs(ctx)
+		if err != nil {
+			return stats, err
+		}
+		orderIDs = ids
+	}
+
+	for _, id := range orderIDs {
+		n, err := s.replayOrder(ctx, p, id)
+		if err != nil {
+			return stats, fmt.Errorf("replay %s for order %s: %w", p.Name(), id, err)
+		}
+		stats.Orders++
+		stats.Events += n
+	}
+	return stats, nil
+}
+
+func (s *StorePG) replayOrder(ctx context.Context, p Projection, orderID string) (int, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	recs, err := s.stream(ctx, tx, orderID, 0)
+	if err != nil {
+		return 0, err
+	}
+	if err := p.Reset(ctx, tx, orderID); err != nil {
+		return 0, err
+	}
+	for _, r := range recs {
+		if err := p.Apply(ctx, tx, r); err != nil {
+			return 0, fmt.Errorf("event %s (seq %d): %w", r.EventID, r.Seq, err)
+		}
+	}
+	return len(recs), tx.Commit(ctx)
+}
@@ -0,0 +1,111 @@
+package eventstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+// lockClass namespaces the per-order advisory locks taken while appending.
+const lockClass = 37
+
+// Record is one stored event. Envelope is the event exactly as consumed
+// (legacy JSON envelope, at the version it was published with).
+type Record struct {
+	OrderID       string          `json:"order_id"`
+	Seq           int64           `json:"seq"`
+	EventID       string          `json:"event_id"`
+	Type          string          `json:"type"`
+	Version       int             `json:"version"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	StoredAt      time.Time       `json:"stored_at"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	CausationID   string          `json:"causation_id,omitempty"`
+	Envelope      json.RawMessage `json:"envelope"`
+}
+
+// StorePG is the append-only event_store table.
+type StorePG struct{ DB *pgxpool.Pool }
+
+// Append stores evt as the next event of its order. Redelivered events (same
+// event ID) are not stored twice: appended is false and seq is 0.
+func (s *StorePG) Append(ctx context.Context, evt models.Event[json.RawMessage], envelope []byte) (seq int64, appended bool, err error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, `select pg_advisory_xact_lock($1, hashtext($2))`, lockClass, evt.OrderID); err != nil {
+		return 0, false, err
+	}
+	err = tx.QueryRow(ctx, `
+		insert into event_store (
+			order_id, seq, event_id, event_type, version,
+			occurred_at, correlation_id, causation_id, envelope
+		)
+		select $1, coalesce(max(seq), 0) + 1, $2, $3, $4, $5, nullif($6, ''), nullif($7, ''), $8::jsonb
+		from event_store
+		where order_id = $1
+		on conflict (event_id) do nothing
+		returning seq
+	`, evt.OrderID, evt.ID, evt.Type, evt.Version, evt.Time, evt.CorrelationID, evt.CausationID, string(envelope)).Scan(&seq)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return seq, true, tx.Commit(ctx)
+}
+
+// Stream returns the events of an order with seq > afterSeq, in order.
+func (s *StorePG) Stream(ctx context.Context, orderID string, afterSeq int64) ([]Record, error) {
+	return s.stream(ctx, s.DB, orderID, afterSeq)
+}
+
+// OrderIDs lists every order with stored events.
+func (s *StorePG) OrderIDs(ctx context.Context) ([]string, error) {
+	rows, err := s.DB.Query(ctx, `select distinct order_id from event_store order by order_id`)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func (s *StorePG) stream(ctx context.Context, q querier, orderID string, afterSeq int64) ([]Record, error) {
+	rows, err := q.Query(ctx, `
+		select order_id, seq, event_id, event_type, version, occurred_at, stored_at,
+		       coalesce(correlation_id, ''), coalesce(causation_id, ''), envelope::text
+		from event_store
+		where order_id = $1 and seq > $2
+		order by seq
+	`, orderID, afterSeq)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Record
+	for rows.Next() {
+		var r Record
+		var envelopeText string
+		if err := rows.Scan(&r.OrderID, &r.Seq, &r.EventID, &r.Type, &r.Version, &r.OccurredAt, &r.StoredAt,
+			&r.CorrelationID, &r.CausationID, &envelopeText); err != nil {
+			return nil, err
+		}
+		r.Envelope = json.RawMessage(envelopeText)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,63 @@
+// Package httpauth guards admin APIs with bearer tokens.
+package httpauth
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+type principalKey struct{}
+
+// Bearer admits requests sending one of tokens (principal name -> token) as
+// "Authorization: Bearer <token>" and makes the principal's name available
+// through Principal. Tokens are compared in constant time. With no tokens
+// configured the API is off, not open: every request gets 503.
+func Bearer(tokens map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(tokens) == 0 {
+				http.Error(w, "admin api disabled: no token configured", http.StatusServiceUnavailable)
+				return
+			}
+			name, ok := match(tokens, r.Header.Get("Authorization"))
+			if !ok {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, name)))
+		})
+	}
+}
+
+// Single is Bearer with one token for principal "admin"; an empty token
+// configures none.
+func Single(token string) func(http.Handler) http.Handler {
+	if token == "" {
+		return Bearer(nil)
+	}
+	return Bearer(map[string]string{"admin": token})
+}
+
+// Principal is the name of the token a request passed Bearer with.
+func Principal(ctx context.Context) string {
+	name, _ := ctx.Value(principalKey{}).(string)
+	return name
+}
+
+// match checks every token, so the time taken does not tell which one (or
+// how much of one) matched.
+func match(tokens map[string]string, header string) (string, bool) {
+	got, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || got == "" {
+		return "", false
+	}
+	found := ""
+	for name, token := range tokens {
+		if token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+			found = name
+		}
+	}
+	return found, found != ""
+}
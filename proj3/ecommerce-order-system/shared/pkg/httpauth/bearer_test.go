@@ -0,0 +1,55 @@
+package httpauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBearer(t *testing.T) {
+	tokens := map[string]string{"alice": "tok-a", "bob": "tok-b"}
+	tests := []struct {
+		name       string
+		tokens     map[string]string
+		header     string
+		wantStatus int
+		wantWho    string
+	}{
+		{"no tokens configured", nil, "Bearer tok-a", http.StatusServiceUnavailable, ""},
+		{"missing header", tokens, "", http.StatusUnauthorized, ""},
+		{"wrong scheme", tokens, "Basic tok-a", http.StatusUnauthorized, ""},
+		{"empty token", tokens, "Bearer ", http.StatusUnauthorized, ""},
+		{"wrong token", tokens, "Bearer tok-c", http.StatusUnauthorized, ""},
+		{"prefix of a token", tokens, "Bearer tok-", http.StatusUnauthorized, ""},
+		{"alice", tokens, "Bearer tok-a", http.StatusOK, "alice"},
+		{"bob", tokens, "Bearer tok-b", http.StatusOK, "bob"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var who string
+			h := Bearer(tt.tokens)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				who = Principal(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus || who != tt.wantWho {
+				t.Fatalf("got %d %q, want %d %q", rec.Code, who, tt.wantStatus, tt.wantWho)
+			}
+		})
+	}
+}
+
+func TestSingleEmptyTokenIsDisabled(t *testing.T) {
+	h := Single("")(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { t.Fatal("handler reached") }))
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got %d, want 503", rec.Code)
+	}
+}
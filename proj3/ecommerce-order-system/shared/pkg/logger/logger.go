@@ -1,10 +1,13 @@
 package logger
 
 import (
+	"context"
 	"os"
 	"time"
 
 	"github.com/rs/zerolog"
+
+	"ecommerce-order-system/shared/pkg/models"
 )
 
 func New(service string, level string) zerolog.Logger {
@@ -20,3 +23,31 @@ func New(service string, level string) zerolog.Logger {
 		Str("service", service).
 		Logger()
 }
+
+// ForEvent adds the event's identity and saga links to every line logged with the result.
+func ForEvent[T any](l zerolog.Logger, evt models.Event[T]) zerolog.Logger {
+	corr := evt.CorrelationID
+	if corr == "" {
+		corr = evt.ID
+	}
+	return l.With().
+		Str("event_id", evt.ID).
+		Str("event_type", evt.Type).
+		Str("order_id", evt.OrderID).
+		Str("correlation_id", corr).
+		Str("causation_id", evt.CausationID).
+		Logger()
+}
+
+// FromContext adds the correlation/causation links stored in ctx, if any.
+func FromContext(ctx context.Context, l zerolog.Logger) zerolog.Logger {
+	links, ok := models.LinksFromContext(ctx)
+	if !ok {
+		return l
+	}
+	c := l.With().Str("correlation_id", links.CorrelationID)
+	if links.CausationID != "" {
+		c = c.Str("causation_id", links.CausationID)
+	}
+	return c.Logger()
+}
@@ -0,0 +1,221 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+//go:generate go run ../../../tools/eventschemas -out schemas -proto proto/events.proto
+
+// Event types. In this system the event type doubles as the routing key on
+// the orders.events exchange.
+const (
+	TypeOrderCreated              = "orders.created"
+	TypeInventoryReserved         = "inventory.reserved"
+	TypeInventoryReleased         = "inventory.released"
+	TypeInventoryReleaseRequested = "inventory.release_requested"
+	TypeInventoryFailed           = "inventory.failed"
+	// TypePaymentProcessed is the single-step charge that payment.authorized
+	// and payment.captured replaced; kept registered for stored history.
+	TypePaymentProcessed            = "payment.processed"
+	TypePaymentPending              = "payment.pending"
+	TypePaymentAuthorized           = "payment.authorized"
+	TypePaymentCaptured             = "payment.captured"
+	TypePaymentCaptureFailed        = "payment.capture_failed"
+	TypePaymentAuthorizationExpired = "payment.authorization_expired"
+	TypePaymentFailed               = "payment.failed"
+	TypePaymentDeclined             = "payment.declined"
+	TypePaymentRetryRequested       = "payment.retry_requested"
+	TypePaymentRefundRequested      = "payment.refund_requested"
+	TypePaymentRefunded             = "payment.refunded"
+	TypePaymentRefundFailed         = "payment.refund_failed"
+	TypeFraudPassed                 = "fraud.passed"
+	TypeFraudReviewRequired         = "fraud.review_required"
+	TypeOrderRejectedFraud          = "order.rejected_fraud"
+	TypeOrderHeld                   = "order.held"
+	TypeOrderHoldApproved           = "order.hold_approved"
+	TypeOrderHoldRejected           = "order.hold_rejected"
+	TypeShippingScheduled           = "shipping.scheduled"
+	TypeShippingFailed              = "shipping.failed"
+	TypeOrderCompleted              = "order.completed"
+	TypeOrderCancelled              = "order.cancelled"
+	TypeOrderTimedOut               = "order.timed_out"
+	TypePaymentVoided               = "payment.voided"
+)
+
+// Commands sent by saga-orchestrator (SAGA_MODE=orchestration). They travel on
+// orders.events like events, so retries and the event store treat them alike;
+// the services answer with the events above.
+const (
+	CmdInventoryReserve = "inventory.reserve"
+	CmdInventoryRelease = "inventory.release"
+	CmdFraudScreen      = "fraud.screen"
+	// CmdPaymentProcess is the single-step charge command that
+	// payment.authorize and payment.capture replaced; kept registered so
+	// stored commands of older sagas still validate and replay.
+	CmdPaymentProcess   = "payment.process"
+	CmdPaymentAuthorize = "payment.authorize"
+	CmdPaymentCapture   = "payment.capture"
+	CmdPaymentVoid      = "payment.void"
+	CmdShippingSchedule = "shipping.schedule"
+)
+
+var ErrUnregisteredEvent = errors.New("unregistered event type")
+
+// EventSpec declares one event type: its routing key, current payload version
+// and payload struct. Schema is generated from the payload struct. Older
+// versions stay registered in Versions (and their structs in Payloads) so they
+// can still be validated, upcast and protobuf-decoded.
+type EventSpec struct {
+	Type       string
+	RoutingKey string
+	Version    int
+	Payload    reflect.Type
+	Schema     *Schema
+	Versions   map[int]*Schema
+	Payloads   map[int]reflect.Type
+}
+
+var catalog = map[string]*EventSpec{}
+
+// register adds a payload version; the highest registered version becomes current.
+func register[T any](eventType string, version int) {
+	payload := reflect.TypeFor[T]()
+	schema := envelopeSchema(eventType, version, payload)
+	if err := registerProto(payload); err != nil {
+		panic(fmt.Sprintf("event %s v%d: %v", eventType, version, err))
+	}
+
+	spec, ok := catalog[eventType]
+	if !ok {
+		spec = &EventSpec{Type: eventType, RoutingKey: eventType, Versions: map[int]*Schema{}, Payloads: map[int]reflect.Type{}}
+		catalog[eventType] = spec
+	}
+	spec.Versions[version] = schema
+	spec.Payloads[version] = payload
+	if version > spec.Version {
+		spec.Version = version
+		spec.Payload = payload
+		spec.Schema = schema
+	}
+}
+
+func init() {
+	register[OrderCreatedPayloadV1](TypeOrderCreated, 1)
+	register[OrderCreatedPayload](TypeOrderCreated, 2)
+	register[InventoryReservedPayload](TypeInventoryReserved, 1)
+	register[InventoryReleasedPayload](TypeInventoryReleased, 1)
+	register[InventoryReleaseRequestedPayload](TypeInventoryReleaseRequested, 1)
+	register[InventoryFailedPayload](TypeInventoryFailed, 1)
+	register[PaymentProcessedPayload](TypePaymentProcessed, 1)
+	register[PaymentPendingPayload](TypePaymentPending, 1)
+	register[PaymentAuthorizedPayload](TypePaymentAuthorized, 1)
+	register[PaymentCapturedPayload](TypePaymentCaptured, 1)
+	register[PaymentCaptureFailedPayload](TypePaymentCaptureFailed, 1)
+	register[PaymentAuthorizationExpiredPayload](TypePaymentAuthorizationExpired, 1)
+	register[PaymentFailedPayload](TypePaymentFailed, 1)
+	register[PaymentDeclinedPayload](TypePaymentDeclined, 1)
+	register[PaymentRetryRequestedPayload](TypePaymentRetryRequested, 1)
+	register[PaymentRefundRequestedPayload](TypePaymentRefundRequested, 1)
+	register[PaymentRefundedPayload](TypePaymentRefunded, 1)
+	register[PaymentRefundFailedPayload](TypePaymentRefundFailed, 1)
+	register[FraudPassedPayload](TypeFraudPassed, 1)
+	register[FraudReviewRequiredPayload](TypeFraudReviewRequired, 1)
+	register[OrderRejectedFraudPayload](TypeOrderRejectedFraud, 1)
+	register[OrderHeldPayload](TypeOrderHeld, 1)
+	register[OrderHoldResolvedPayload](TypeOrderHoldApproved, 1)
+	register[OrderHoldResolvedPayload](TypeOrderHoldRejected, 1)
+	register[ShippingScheduledPayload](TypeShippingScheduled, 1)
+	register[ShippingFailedPayload](TypeShippingFailed, 1)
+	register[OrderCompletedPayload](TypeOrderCompleted, 1)
+	register[OrderCancelledPayload](TypeOrderCancelled, 1)
+	register[OrderTimedOutPayload](TypeOrderTimedOut, 1)
+	register[PaymentVoidedPayload](TypePaymentVoided, 1)
+
+	register[InventoryReservePayload](CmdInventoryReserve, 1)
+	register[InventoryReleasePayload](CmdInventoryRelease, 1)
+	register[FraudScreenPayload](CmdFraudScreen, 1)
+	register[PaymentProcessPayload](CmdPaymentProcess, 1)
+	register[PaymentAuthorizePayload](CmdPaymentAuthorize, 1)
+	register[PaymentCapturePayload](CmdPaymentCapture, 1)
+	register[PaymentVoidPayload](CmdPaymentVoid, 1)
+	register[ShippingSchedulePayload](CmdShippingSchedule, 1)
+}
+
+// Lookup returns the spec for an event type.
+func Lookup(eventType string) (EventSpec, bool) {
+	s, ok := catalog[eventType]
+	if !ok {
+		return EventSpec{}, false
+	}
+	return *s, true
+}
+
+// Specs lists every registered event, sorted by type.
+func Specs() []EventSpec {
+	out := make([]EventSpec, 0, len(catalog))
+	for _, s := range catalog {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Type < out[j].Type })
+	return out
+}
+
+// CurrentVersion is the payload version publishers should emit for eventType (0 if unknown).
+func CurrentVersion(eventType string) int {
+	if s, ok := catalog[eventType]; ok {
+		return s.Version
+	}
+	return 0
+}
+
+// NewEvent builds an envelope for a registered event type at its current version.
+func NewEvent[T any](eventType, orderID string, payload T) Event[T] {
+	return Event[T]{
+		ID:      uuid.NewString(),
+		Type:    eventType,
+		Version: CurrentVersion(eventType),
+		Time:    time.Now(),
+		OrderID: orderID,
+		Payload: payload,
+	}
+}
+
+// Validate checks a serialized envelope published under routingKey against
+// the catalog schema of the version it declares.
+func Validate(routingKey string, body []byte) error {
+	spec, ok := catalog[routingKey]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnregisteredEvent, routingKey)
+	}
+	v, err := decodeAny(body)
+	if err != nil {
+		return err
+	}
+	obj, _ := v.(map[string]any)
+	n, _ := obj["version"].(json.Number)
+	version, err := n.Int64()
+	if err != nil {
+		return fmt.Errorf("event %s: missing or bad version", routingKey)
+	}
+	schema, ok := spec.Versions[int(version)]
+	if !ok {
+		return fmt.Errorf("%w: %s v%d", ErrUnknownVersion, routingKey, version)
+	}
+	if err := schema.Validate(v); err != nil {
+		return fmt.Errorf("event %s v%d: %w", routingKey, version, err)
+	}
+	return nil
+}
+
+// DecodeEvent validates body and returns it at the current catalog version,
+// upcasting older payloads. Consumers that handle other versions use a Decoder.
+func DecodeEvent(routingKey string, body []byte) (Event[json.RawMessage], error) {
+	return Decoder{}.Decode(routingKey, body)
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// catalogConstants maps the Type* and Cmd* constants of catalog.go to their
+// routing keys.
+func catalogConstants(t *testing.T) map[string]string {
+	t.Helper()
+	f, err := parser.ParseFile(token.NewFileSet(), "catalog.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	consts := map[string]string{}
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for i, name := range vs.Names {
+				if !strings.HasPrefix(name.Name, "Type") && !strings.HasPrefix(name.Name, "Cmd") || i >= len(vs.Values) {
+					continue
+				}
+				if lit, ok := vs.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					consts[name.Name], _ = strconv.Unquote(lit.Value)
+				}
+			}
+		}
+	}
+	return consts
+}
+
+func TestCatalogConstantsAreRegistered(t *testing.T) {
+	for name, key := range catalogConstants(t) {
+		if _, ok := Lookup(key); !ok {
+			t.Errorf("%s = %q is not registered in the catalog", name, key)
+		}
+	}
+}
+
+// TestServicesPublishRegisteredTypes walks the services for the event types
+// they publish: the first argument of models.NewEvent and the routing key of
+// PublishJSON. Each must be a registered catalog constant or literal; other
+// expressions (a routing key held in a variable) are checked where the
+// variable is set, since every models.Type*/Cmd* a service names must exist.
+func TestServicesPublishRegisteredTypes(t *testing.T) {
+	consts := catalogConstants(t)
+	fset := token.NewFileSet()
+	published := 0
+
+	err := filepath.WalkDir(filepath.Join("..", "..", "..", "services"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		check := func(e ast.Expr, published *int) {
+			switch e := e.(type) {
+			case *ast.SelectorExpr:
+				pkg, ok := e.X.(*ast.Ident)
+				if !ok || pkg.Name != "models" {
+					return
+				}
+				if strings.HasPrefix(e.Sel.Name, "Type") || strings.HasPrefix(e.Sel.Name, "Cmd") {
+					if published != nil {
+						*published++
+					}
+					if _, ok := consts[e.Sel.Name]; !ok {
+						t.Errorf("%s: models.%s is not a catalog event type", fset.Position(e.Pos()), e.Sel.Name)
+					}
+				}
+			case *ast.BasicLit:
+				if e.Kind != token.STRING {
+					return
+				}
+				if published != nil {
+					*published++
+				}
+				key, _ := strconv.Unquote(e.Value)
+				if _, ok := Lookup(key); !ok {
+					t.Errorf("%s: publishes unregistered event type %q", fset.Position(e.Pos()), key)
+				}
+			}
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				check(n, nil)
+			case *ast.CallExpr:
+				switch name := calleeName(n.Fun); {
+				case name == "NewEvent" && len(n.Args) > 0:
+					check(n.Args[0], &published)
+				case name == "PublishJSON" && len(n.Args) > 1:
+					check(n.Args[1], &published)
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// guards against the walk silently finding nothing
+	if published < 20 {
+		t.Fatalf("found only %d published event types; is the services walk broken?", published)
+	}
+}
+
+// calleeName is the function name of a call, without package or type
+// arguments: models.NewEvent[any] -> NewEvent.
+func calleeName(fun ast.Expr) string {
+	switch f := fun.(type) {
+	case *ast.IndexExpr:
+		return calleeName(f.X)
+	case *ast.IndexListExpr:
+		return calleeName(f.X)
+	case *ast.SelectorExpr:
+		return f.Sel.Name
+	case *ast.Ident:
+		return f.Name
+	}
+	return ""
+}
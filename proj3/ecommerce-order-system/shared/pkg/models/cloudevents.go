@@ -0,0 +1,189 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	CloudEventsSpecVersion = "1.0"
+
+	ContentTypeJSON            = "application/json"
+	ContentTypeCloudEventsJSON = "application/cloudevents+json"
+)
+
+var ErrInvalidCloudEvent = errors.New("invalid cloudevent")
+
+// CloudEvent is an envelope in CloudEvents 1.0 structured JSON form.
+// ID, Type and Time map onto the core attributes, OrderID onto subject and the
+// producing service onto source. Envelope fields without a core counterpart
+// travel as extension attributes.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+
+	DataVersion       int    `json:"dataversion,omitempty"`
+	CorrelationID     string `json:"correlationid,omitempty"`
+	CausationID       string `json:"causationid,omitempty"`
+	PublishedVersions string `json:"publishedversions,omitempty"`
+	// Sequence is the envelope's seq (CloudEvents sequence extension).
+	Sequence string `json:"sequence,omitempty"`
+}
+
+// ToCloudEvent converts a serialized legacy envelope.
+func ToCloudEvent(body []byte, source string) (CloudEvent, error) {
+	var evt Event[json.RawMessage]
+	if err := json.Unmarshal(body, &evt); err != nil {
+		return CloudEvent{}, err
+	}
+	ce := CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              evt.ID,
+		Source:          source,
+		Type:            evt.Type,
+		Subject:         evt.OrderID,
+		Time:            evt.Time,
+		DataContentType: ContentTypeJSON,
+		Data:            evt.Payload,
+		DataVersion:     evt.Version,
+		CorrelationID:   evt.CorrelationID,
+		CausationID:     evt.CausationID,
+	}
+	if evt.Seq > 0 {
+		ce.Sequence = strconv.FormatInt(evt.Seq, 10)
+	}
+	if len(evt.PublishedVersions) > 0 {
+		vs := make([]string, 0, len(evt.PublishedVersions))
+		for _, v := range evt.PublishedVersions {
+			vs = append(vs, strconv.Itoa(v))
+		}
+		ce.PublishedVersions = strings.Join(vs, ",")
+	}
+	return ce, ce.check()
+}
+
+// Envelope converts ce back into a serialized legacy envelope, so consumers
+// decode both forms through the same Decoder.
+func (ce CloudEvent) Envelope() ([]byte, error) {
+	if err := ce.check(); err != nil {
+		return nil, err
+	}
+	if ce.DataContentType != "" && !strings.HasPrefix(ce.DataContentType, ContentTypeJSON) {
+		return nil, fmt.Errorf("%w: unsupported datacontenttype %q", ErrInvalidCloudEvent, ce.DataContentType)
+	}
+	evt := Event[json.RawMessage]{
+		ID:            ce.ID,
+		Type:          ce.Type,
+		Version:       ce.DataVersion,
+		Time:          ce.Time,
+		OrderID:       ce.Subject,
+		Payload:       ce.Data,
+		CorrelationID: ce.CorrelationID,
+		CausationID:   ce.CausationID,
+	}
+	if evt.Version == 0 {
+		evt.Version = 1
+	}
+	if ce.Sequence != "" {
+		seq, err := strconv.ParseInt(ce.Sequence, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: sequence %q", ErrInvalidCloudEvent, ce.Sequence)
+		}
+		evt.Seq = seq
+	}
+	if ce.PublishedVersions != "" {
+		for _, s := range strings.Split(ce.PublishedVersions, ",") {
+			v, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return nil, fmt.Errorf("%w: publishedversions %q", ErrInvalidCloudEvent, ce.PublishedVersions)
+			}
+			evt.PublishedVersions = append(evt.PublishedVersions, v)
+		}
+	}
+	return json.Marshal(evt)
+}
+
+// Attributes returns every attribute except data and datacontenttype as
+// strings, for binary content mode.
+func (ce CloudEvent) Attributes() map[string]string {
+	attrs := map[string]string{
+		"specversion": ce.SpecVersion,
+		"id":          ce.ID,
+		"source":      ce.Source,
+		"type":        ce.Type,
+	}
+	if ce.Subject != "" {
+		attrs["subject"] = ce.Subject
+	}
+	if !ce.Time.IsZero() {
+		attrs["time"] = ce.Time.UTC().Format(time.RFC3339Nano)
+	}
+	if ce.DataVersion > 0 {
+		attrs["dataversion"] = strconv.Itoa(ce.DataVersion)
+	}
+	if ce.CorrelationID != "" {
+		attrs["correlationid"] = ce.CorrelationID
+	}
+	if ce.CausationID != "" {
+		attrs["causationid"] = ce.CausationID
+	}
+	if ce.PublishedVersions != "" {
+		attrs["publishedversions"] = ce.PublishedVersions
+	}
+	if ce.Sequence != "" {
+		attrs["sequence"] = ce.Sequence
+	}
+	return attrs
+}
+
+// CloudEventFromAttributes is the inverse of Attributes for binary content mode.
+func CloudEventFromAttributes(attrs map[string]string, contentType string, data []byte) (CloudEvent, error) {
+	ce := CloudEvent{
+		SpecVersion:       attrs["specversion"],
+		ID:                attrs["id"],
+		Source:            attrs["source"],
+		Type:              attrs["type"],
+		Subject:           attrs["subject"],
+		DataContentType:   contentType,
+		Data:              data,
+		CorrelationID:     attrs["correlationid"],
+		CausationID:       attrs["causationid"],
+		PublishedVersions: attrs["publishedversions"],
+		Sequence:          attrs["sequence"],
+	}
+	if s := attrs["time"]; s != "" {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return CloudEvent{}, fmt.Errorf("%w: time %q", ErrInvalidCloudEvent, s)
+		}
+		ce.Time = t
+	}
+	if s := attrs["dataversion"]; s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return CloudEvent{}, fmt.Errorf("%w: dataversion %q", ErrInvalidCloudEvent, s)
+		}
+		ce.DataVersion = v
+	}
+	return ce, ce.check()
+}
+
+func (ce CloudEvent) check() error {
+	if ce.SpecVersion != CloudEventsSpecVersion {
+		return fmt.Errorf("%w: specversion %q", ErrInvalidCloudEvent, ce.SpecVersion)
+	}
+	if ce.ID == "" || ce.Source == "" || ce.Type == "" {
+		return fmt.Errorf("%w: id, source and type are required", ErrInvalidCloudEvent)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// Compares JSON and protobuf event encoding:
+//
+//	go test ./shared/pkg/models -run '^$' -bench Codec -benchmem
+//
+// bytes/msg is the encoded size. "consumer-path" is what a consumer pays for
+// a protobuf message today: the envelope is decoded and its payload
+// re-serialized as JSON for models.Decoder.
+
+type benchCodec[T any] struct {
+	name   string
+	encode func(Event[T]) ([]byte, error)
+	decode func([]byte) (Event[T], error)
+}
+
+func benchCodecs[T any]() []benchCodec[T] {
+	return []benchCodec[T]{
+		{
+			name:   "json",
+			encode: func(e Event[T]) ([]byte, error) { return json.Marshal(e) },
+			decode: func(b []byte) (Event[T], error) {
+				var e Event[T]
+				err := json.Unmarshal(b, &e)
+				return e, err
+			},
+		},
+		{name: "protobuf", encode: MarshalProto[T], decode: UnmarshalProto[T]},
+	}
+}
+
+func benchCreated() Event[OrderCreatedPayload] {
+	items := make([]OrderItemPayload, 0, 5)
+	for i := range 5 {
+		items = append(items, OrderItemPayload{SKU: fmt.Sprintf("SKU-%04d", i+1), Qty: i + 1, PriceCents: 1999 * (i + 1)})
+	}
+	e := NewEvent(TypeOrderCreated, "0b5c7d0e-6a43-4d0c-9a55-0e3f6b8f2a11", OrderCreatedPayload{
+		UserID:     "user-42",
+		Email:      "user42@example.com",
+		TotalCents: 29985,
+		Currency:   "EUR",
+		Items:      items,
+	})
+	e.CorrelationID = "5f1d3c55-3f0b-4a7e-8f8e-2d5d0f7a9c01"
+	// protobuf carries time as unix nanos, so compare in UTC
+	e.Time = e.Time.UTC()
+	return e
+}
+
+func benchReserved() Event[InventoryReservedPayload] {
+	created := benchCreated()
+	e := NewEvent(TypeInventoryReserved, created.OrderID, InventoryReservedPayload{Note: "reserved", Order: created.Payload.Snapshot()})
+	e.CorrelationID, e.CausationID = created.CorrelationID, created.ID
+	e.Time = e.Time.UTC()
+	return e
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	testRoundTrip(t, benchCreated())
+	testRoundTrip(t, benchReserved())
+}
+
+func testRoundTrip[T any](t *testing.T, evt Event[T]) {
+	for _, c := range benchCodecs[T]() {
+		t.Run(evt.Type+"/"+c.name, func(t *testing.T) {
+			body, err := c.encode(evt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			back, err := c.decode(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(back, evt) {
+				t.Fatalf("round trip mismatch:\n got %+v\nwant %+v", back, evt)
+			}
+		})
+	}
+}
+
+func BenchmarkCodec(b *testing.B) {
+	benchmarkCodec(b, benchCreated())
+	benchmarkCodec(b, benchReserved())
+
+	b.Run(TypeOrderCreated+"/protobuf/consumer-path", func(b *testing.B) {
+		body, err := MarshalProto(benchCreated())
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		for range b.N {
+			if _, err := DecodeProto(body); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func benchmarkCodec[T any](b *testing.B, evt Event[T]) {
+	for _, c := range benchCodecs[T]() {
+		body, err := c.encode(evt)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(evt.Type+"/"+c.name+"/encode", func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				if _, err := c.encode(evt); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(body)), "bytes/msg")
+		})
+		b.Run(evt.Type+"/"+c.name+"/decode", func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				if _, err := c.decode(body); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(body)), "bytes/msg")
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type Event[T any] struct {
 	ID      string    `json:"id"`
@@ -9,4 +12,53 @@ type Event[T any] struct {
 	Time    time.Time `json:"time"`
 	OrderID string    `json:"order_id"`
 	Payload T         `json:"payload"`
+
+	// CorrelationID is constant for the whole saga and originates at the HTTP
+	// request; CausationID is the ID of the event this one was produced from.
+	CorrelationID string `json:"correlation_id,omitempty"`
+	CausationID   string `json:"causation_id,omitempty"`
+	// Seq is the event's position in its order's causal chain: the causing
+	// event's Seq + 1, so an event is never applied before its cause. Events
+	// caused by the same event share a Seq. 0 means unknown (older producers).
+	Seq int64 `json:"seq,omitempty"`
+
+	// PublishedVersions is set when the same event was dual-published at several
+	// payload versions; consumers then process only the copy that suits them.
+	PublishedVersions []int `json:"published_versions,omitempty"`
+}
+
+// Links is the correlation/causation pair carried from a consumed event to
+// the events published while handling it, with the cause's Seq.
+type Links struct {
+	CorrelationID string
+	CausationID   string
+	Seq           int64
+}
+
+type linksKey struct{}
+
+// ContextWithEvent records evt as the cause of anything published with ctx.
+// Events that predate correlation IDs start a new chain at their own ID.
+func ContextWithEvent[T any](ctx context.Context, evt Event[T]) context.Context {
+	corr := evt.CorrelationID
+	if corr == "" {
+		corr = evt.ID
+	}
+	return context.WithValue(ctx, linksKey{}, Links{CorrelationID: corr, CausationID: evt.ID, Seq: evt.Seq})
+}
+
+// ContextWithCorrelation starts a chain at the edge (HTTP request) with no parent event.
+func ContextWithCorrelation(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, linksKey{}, Links{CorrelationID: correlationID})
+}
+
+// ContextWithLinks restores links saved earlier, for publishing outside the
+// handling of the causing event (timers, sweepers).
+func ContextWithLinks(ctx context.Context, l Links) context.Context {
+	return context.WithValue(ctx, linksKey{}, l)
+}
+
+func LinksFromContext(ctx context.Context) (Links, bool) {
+	l, ok := ctx.Value(linksKey{}).(Links)
+	return l, ok
 }
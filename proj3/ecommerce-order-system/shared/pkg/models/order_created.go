@@ -1,25 +1,131 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type OrderItemPayload struct {
-	SKU        string `json:"sku"`
-	Qty        int    `json:"qty"`
-	PriceCents int    `json:"price_cents"`
+	SKU        string `json:"sku" proto:"1" schema:"minLength=1"`
+	Qty        int    `json:"qty" proto:"2" schema:"minimum=1"`
+	PriceCents int    `json:"price_cents" proto:"3" schema:"minimum=0"`
 }
 
+// AddressPayload is a postal address; Country is ISO 3166 alpha-2.
+type AddressPayload struct {
+	Name       string `json:"name" proto:"1" schema:"minLength=1"`
+	Line1      string `json:"line1" proto:"2" schema:"minLength=1"`
+	Line2      string `json:"line2,omitempty" proto:"3"`
+	City       string `json:"city" proto:"4" schema:"minLength=1"`
+	Region     string `json:"region,omitempty" proto:"5"`
+	PostalCode string `json:"postal_code" proto:"6" schema:"minLength=1"`
+	Country    string `json:"country" proto:"7" schema:"minLength=2"`
+}
+
+// Payment method types. Gift cards and store credit are redeemed first, in
+// the order listed; a card pays the rest.
+const (
+	PaymentMethodCard        = "card"
+	PaymentMethodGiftCard    = "gift_card"
+	PaymentMethodStoreCredit = "store_credit"
+)
+
+// PaymentMethodPayload is one way the customer pays. Token is the card's
+// payment method at the provider; Code the gift card. AmountCents caps what a
+// gift card or store credit pays (0: as much as it holds).
+type PaymentMethodPayload struct {
+	Type        string `json:"type" proto:"1" schema:"minLength=1"`
+	Token       string `json:"token,omitempty" proto:"2"`
+	Code        string `json:"code,omitempty" proto:"3"`
+	AmountCents int    `json:"amount_cents,omitempty" proto:"4"`
+}
+
+// OrderCreatedPayload is version 2: v1 plus Currency (ISO 4217). The optional
+// countries (ISO 3166 alpha-2) are used by fraud screening.
 type OrderCreatedPayload struct {
-	UserID     string             `json:"user_id"`
-	Email      string             `json:"email"`
-	TotalCents int                `json:"total_cents"`
-	Items      []OrderItemPayload `json:"items"`
+	UserID          string             `json:"user_id" proto:"1" schema:"minLength=1"`
+	Email           string             `json:"email" proto:"2" schema:"minLength=1"`
+	TotalCents      int                `json:"total_cents" proto:"3" schema:"minimum=0"`
+	Currency        string             `json:"currency" proto:"5" schema:"minLength=3"`
+	Items           []OrderItemPayload `json:"items" proto:"4" schema:"minItems=1"`
+	BillingCountry  string             `json:"billing_country,omitempty" proto:"6"`
+	ShippingCountry string             `json:"shipping_country,omitempty" proto:"7"`
+	IPCountry       string             `json:"ip_country,omitempty" proto:"8"`
+	ShippingAddress *AddressPayload    `json:"shipping_address,omitempty" proto:"9"`
+	// PaymentMethods is empty for orders paid by card with the provider's
+	// default method.
+	PaymentMethods []PaymentMethodPayload `json:"payment_methods,omitempty" proto:"10"`
+}
+
+// OrderSnapshotPayload is what the steps after stock reservation need to
+// know about an order: who pays how much for what, and where it goes. It
+// travels with the events and commands that start payment and shipping, so
+// those services never look the order up.
+type OrderSnapshotPayload struct {
+	UserID          string                 `json:"user_id" proto:"1" schema:"minLength=1"`
+	Email           string                 `json:"email" proto:"2" schema:"minLength=1"`
+	TotalCents      int                    `json:"total_cents" proto:"3" schema:"minimum=0"`
+	Currency        string                 `json:"currency" proto:"4" schema:"minLength=3"`
+	Items           []OrderItemPayload     `json:"items" proto:"5" schema:"minItems=1"`
+	ShippingAddress *AddressPayload        `json:"shipping_address,omitempty" proto:"6"`
+	PaymentMethods  []PaymentMethodPayload `json:"payment_methods,omitempty" proto:"7"`
+}
+
+// Snapshot returns the part of the order carried downstream.
+func (p OrderCreatedPayload) Snapshot() *OrderSnapshotPayload {
+	return &OrderSnapshotPayload{
+		UserID:          p.UserID,
+		Email:           p.Email,
+		TotalCents:      p.TotalCents,
+		Currency:        p.Currency,
+		Items:           p.Items,
+		ShippingAddress: p.ShippingAddress,
+		PaymentMethods:  p.PaymentMethods,
+	}
+}
+
+// OrderCreatedPayloadV1 is the original shape without currency.
+type OrderCreatedPayloadV1 struct {
+	UserID     string             `json:"user_id" proto:"1" schema:"minLength=1"`
+	Email      string             `json:"email" proto:"2" schema:"minLength=1"`
+	TotalCents int                `json:"total_cents" proto:"3" schema:"minimum=0"`
+	Items      []OrderItemPayload `json:"items" proto:"4" schema:"minItems=1"`
+}
+
+// DefaultCurrency is assumed for v1 orders, which predate the currency field.
+const DefaultCurrency = "USD"
+
+func init() {
+	RegisterUpcaster(TypeOrderCreated, 1, func(p json.RawMessage) (json.RawMessage, error) {
+		var v1 OrderCreatedPayloadV1
+		if err := json.Unmarshal(p, &v1); err != nil {
+			return nil, err
+		}
+		return json.Marshal(OrderCreatedPayload{
+			UserID:     v1.UserID,
+			Email:      v1.Email,
+			TotalCents: v1.TotalCents,
+			Currency:   DefaultCurrency,
+			Items:      v1.Items,
+		})
+	})
+	RegisterDowncaster(TypeOrderCreated, 2, func(p json.RawMessage) (json.RawMessage, error) {
+		var v2 OrderCreatedPayload
+		if err := json.Unmarshal(p, &v2); err != nil {
+			return nil, err
+		}
+		return json.Marshal(OrderCreatedPayloadV1{
+			UserID:     v2.UserID,
+			Email:      v2.Email,
+			TotalCents: v2.TotalCents,
+			Items:      v2.Items,
+		})
+	})
 }
 
-func NewOrderCreatedEvent(orderID, userID, email string, total int, items []struct {
+func NewOrderCreatedEvent(orderID, userID, email, currency string, total int, items []struct {
 	SKU        string `json:"sku"`
 	Qty        int    `json:"qty"`
 	PriceCents int    `json:"price_cents"`
@@ -32,14 +138,17 @@ func NewOrderCreatedEvent(orderID, userID, email string, total int, items []stru
 	}
 	return Event[OrderCreatedPayload]{
 		ID:      uuid.NewString(),
-		Type:    "orders.created",
-		Version: 1,
+		Type:    TypeOrderCreated,
+		Version: CurrentVersion(TypeOrderCreated),
 		Time:    time.Now(),
 		OrderID: orderID,
+		// orders.created starts the order's chain.
+		Seq: 1,
 		Payload: OrderCreatedPayload{
 			UserID:     userID,
 			Email:      email,
 			TotalCents: total,
+			Currency:   currency,
 			Items:      outItems,
 		},
 	}
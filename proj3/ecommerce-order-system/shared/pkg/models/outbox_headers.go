@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// OutboxHeaders is stored with an outbox row at enqueue time and mapped onto
+// message properties by outbox-worker (MessageId, Type, CorrelationId, ...).
+type OutboxHeaders struct {
+	MessageID     string    `json:"message_id"`
+	Type          string    `json:"type"`
+	SchemaVersion int       `json:"schema_version"`
+	ContentType   string    `json:"content_type,omitempty"`
+	Source        string    `json:"source,omitempty"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	CausationID   string    `json:"causation_id,omitempty"`
+	TraceParent   string    `json:"traceparent,omitempty"`
+	TraceState    string    `json:"tracestate,omitempty"`
+	Persistent    bool      `json:"persistent"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// NewOutboxHeaders fills the headers derivable from the event itself.
+func NewOutboxHeaders[T any](evt Event[T]) OutboxHeaders {
+	return OutboxHeaders{
+		MessageID:     evt.ID,
+		Type:          evt.Type,
+		SchemaVersion: evt.Version,
+		ContentType:   "application/json",
+		CorrelationID: evt.CorrelationID,
+		CausationID:   evt.CausationID,
+		Persistent:    true,
+		CreatedAt:     evt.Time,
+	}
+}
@@ -0,0 +1,231 @@
+package models
+
+// InventoryReservedPayload carries the order on to fraud screening and
+// payment when stock was reserved from orders.created; in orchestration the
+// saga hands it out instead.
+type InventoryReservedPayload struct {
+	Note  string                `json:"note,omitempty" proto:"1"`
+	Order *OrderSnapshotPayload `json:"order,omitempty" proto:"2"`
+}
+
+type InventoryReleasedPayload struct {
+	Note string `json:"note,omitempty" proto:"1"`
+}
+
+type InventoryReleaseRequestedPayload struct {
+	Reason string `json:"reason" proto:"1" schema:"minLength=1"`
+}
+
+type InventoryFailedPayload struct {
+	Reason string `json:"reason" proto:"1" schema:"minLength=1"`
+}
+
+type PaymentProcessedPayload struct {
+	Note string `json:"note,omitempty" proto:"1"`
+}
+
+// PaymentPendingPayload: the provider confirms the authorization later.
+// Status is requires_action (the customer must complete ActionURL, e.g.
+// 3-D Secure) or pending (bank transfer). payment.authorized or
+// payment.failed follows, at the latest at Deadline (RFC 3339).
+type PaymentPendingPayload struct {
+	AuthorizationID string `json:"authorization_id" proto:"1" schema:"minLength=1"`
+	Status          string `json:"status" proto:"2" schema:"minLength=1"`
+	ActionURL       string `json:"action_url,omitempty" proto:"3"`
+	Deadline        string `json:"deadline" proto:"4" schema:"minLength=1"`
+}
+
+// PaymentAuthorizedPayload: the amount is held until ExpiresAt (RFC 3339) and
+// taken by payment.captured. BalanceCents was already redeemed from gift
+// cards and store credit; AmountCents is the card's share of the order.
+// Order is the order paid for, passed on to shipping.
+type PaymentAuthorizedPayload struct {
+	AuthorizationID string                `json:"authorization_id" proto:"1" schema:"minLength=1"`
+	AmountCents     int                   `json:"amount_cents" proto:"2" schema:"minimum=0"`
+	Currency        string                `json:"currency,omitempty" proto:"3"`
+	ExpiresAt       string                `json:"expires_at" proto:"4" schema:"minLength=1"`
+	Order           *OrderSnapshotPayload `json:"order,omitempty" proto:"5"`
+	BalanceCents    int                   `json:"balance_cents,omitempty" proto:"6"`
+}
+
+type PaymentCapturedPayload struct {
+	AuthorizationID string `json:"authorization_id" proto:"1" schema:"minLength=1"`
+	AmountCents     int    `json:"amount_cents" proto:"2" schema:"minimum=0"`
+	Currency        string `json:"currency,omitempty" proto:"3"`
+}
+
+type PaymentCaptureFailedPayload struct {
+	Reason string `json:"reason" proto:"1" schema:"minLength=1"`
+}
+
+type PaymentAuthorizationExpiredPayload struct {
+	AuthorizationID string `json:"authorization_id" proto:"1" schema:"minLength=1"`
+	ExpiredAt       string `json:"expired_at" proto:"2" schema:"minLength=1"`
+}
+
+type PaymentFailedPayload struct {
+	Reason string `json:"reason" proto:"1" schema:"minLength=1"`
+}
+
+// PaymentDeclinedPayload: attempt Attempt was soft-declined, or the provider
+// stayed unreachable (Outcome soft_decline, transient_error or timeout). The
+// order waits for payment.retry_requested with another payment method until
+// Deadline (RFC 3339), then fails.
+type PaymentDeclinedPayload struct {
+	Outcome  string `json:"outcome" proto:"1" schema:"minLength=1"`
+	Reason   string `json:"reason" proto:"2" schema:"minLength=1"`
+	Attempt  int    `json:"attempt" proto:"3" schema:"minimum=1"`
+	Deadline string `json:"deadline" proto:"4" schema:"minLength=1"`
+}
+
+// PaymentRetryRequestedPayload asks to authorize a declined payment again
+// with PaymentMethod. RequestID makes a redelivered request a no-op.
+type PaymentRetryRequestedPayload struct {
+	RequestID     string `json:"request_id" proto:"1" schema:"minLength=1"`
+	PaymentMethod string `json:"payment_method" proto:"2" schema:"minLength=1"`
+}
+
+// PaymentRefundRequestedPayload asks for AmountCents back; 0 means whatever
+// is left of the captured amount. Items lists what a per-item refund covers.
+type PaymentRefundRequestedPayload struct {
+	RefundID    string              `json:"refund_id" proto:"1" schema:"minLength=1"`
+	AmountCents int                 `json:"amount_cents" proto:"2" schema:"minimum=0"`
+	Items       []RefundItemPayload `json:"items,omitempty" proto:"3"`
+	Reason      string              `json:"reason,omitempty" proto:"4"`
+}
+
+type RefundItemPayload struct {
+	SKU         string `json:"sku" proto:"1" schema:"minLength=1"`
+	Qty         int    `json:"qty" proto:"2" schema:"minimum=1"`
+	AmountCents int    `json:"amount_cents" proto:"3" schema:"minimum=0"`
+}
+
+// PaymentRefundedPayload: of AmountCents, BalanceCents went back to gift
+// cards and store credit, the rest to the card.
+type PaymentRefundedPayload struct {
+	RefundID           string `json:"refund_id" proto:"1" schema:"minLength=1"`
+	AmountCents        int    `json:"amount_cents" proto:"2" schema:"minimum=0"`
+	RefundedTotalCents int    `json:"refunded_total_cents" proto:"3" schema:"minimum=0"`
+	Currency           string `json:"currency,omitempty" proto:"4"`
+	BalanceCents       int    `json:"balance_cents,omitempty" proto:"5"`
+}
+
+type PaymentRefundFailedPayload struct {
+	RefundID string `json:"refund_id" proto:"1" schema:"minLength=1"`
+	Reason   string `json:"reason" proto:"2" schema:"minLength=1"`
+}
+
+type OrderCancelledPayload struct {
+	Reason string `json:"reason" proto:"1" schema:"minLength=1"`
+}
+
+// ShippingScheduledPayload describes the label bought for the order.
+// Tracking is the carrier's tracking number; EstimatedDelivery is RFC 3339.
+// Publishers from before carrier integration set only Tracking.
+type ShippingScheduledPayload struct {
+	Tracking          string `json:"tracking" proto:"1" schema:"minLength=1"`
+	Carrier           string `json:"carrier,omitempty" proto:"2"`
+	ServiceLevel      string `json:"service_level,omitempty" proto:"3"`
+	CostCents         int    `json:"cost_cents,omitempty" proto:"4"`
+	Currency          string `json:"currency,omitempty" proto:"5"`
+	LabelURL          string `json:"label_url,omitempty" proto:"6"`
+	EstimatedDelivery string `json:"estimated_delivery,omitempty" proto:"7"`
+}
+
+// ShippingFailedPayload answers a shipping.schedule that bought no label,
+// e.g. for an order cancelled in the meantime.
+type ShippingFailedPayload struct {
+	Reason string `json:"reason" proto:"1" schema:"minLength=1"`
+}
+
+type OrderCompletedPayload struct {
+	Note string `json:"note,omitempty" proto:"1"`
+}
+
+// OrderTimedOutPayload: Step did not answer before Deadline (RFC 3339).
+type OrderTimedOutPayload struct {
+	Step     string `json:"step" proto:"1" schema:"minLength=1"`
+	Deadline string `json:"deadline" proto:"2" schema:"minLength=1"`
+}
+
+// PaymentVoidedPayload: ReversedCents went back to gift cards and store
+// credit.
+type PaymentVoidedPayload struct {
+	Note          string `json:"note,omitempty" proto:"1"`
+	ReversedCents int    `json:"reversed_cents,omitempty" proto:"2"`
+}
+
+// Fraud screening results. Score is the sum of the rules that matched,
+// Reasons names them. fraud.passed carries the order on to payment.
+
+type FraudPassedPayload struct {
+	Score   int                   `json:"score" proto:"1" schema:"minimum=0"`
+	Reasons []string              `json:"reasons,omitempty" proto:"2"`
+	Order   *OrderSnapshotPayload `json:"order,omitempty" proto:"3"`
+}
+
+type FraudReviewRequiredPayload struct {
+	Score   int      `json:"score" proto:"1" schema:"minimum=0"`
+	Reasons []string `json:"reasons" proto:"2" schema:"minItems=1"`
+}
+
+type OrderRejectedFraudPayload struct {
+	Score   int      `json:"score" proto:"1" schema:"minimum=0"`
+	Reasons []string `json:"reasons" proto:"2" schema:"minItems=1"`
+	Reason  string   `json:"reason" proto:"3" schema:"minLength=1"`
+}
+
+// OrderHeldPayload: the order waits for manual review until Deadline
+// (RFC 3339), after which it is rejected automatically.
+type OrderHeldPayload struct {
+	Reason   string   `json:"reason" proto:"1" schema:"minLength=1"`
+	Reasons  []string `json:"reasons,omitempty" proto:"2"`
+	Deadline string   `json:"deadline" proto:"3" schema:"minLength=1"`
+}
+
+// OrderHoldResolvedPayload is the outcome of a review, for both
+// order.hold_approved and order.hold_rejected.
+type OrderHoldResolvedPayload struct {
+	Reason     string `json:"reason" proto:"1" schema:"minLength=1"`
+	ReviewedBy string `json:"reviewed_by" proto:"2" schema:"minLength=1"`
+}
+
+// Command payloads.
+
+type InventoryReservePayload struct {
+	Items []OrderItemPayload `json:"items" proto:"1" schema:"minItems=1"`
+}
+
+type InventoryReleasePayload struct {
+	Reason string `json:"reason" proto:"1" schema:"minLength=1"`
+}
+
+// FraudScreenPayload is empty: fraud-service screens the order it recorded
+// from orders.created.
+type FraudScreenPayload struct {
+	Note string `json:"note,omitempty" proto:"1"`
+}
+
+type PaymentProcessPayload struct {
+	TotalCents int    `json:"total_cents" proto:"1" schema:"minimum=0"`
+	Currency   string `json:"currency" proto:"2" schema:"minLength=3"`
+}
+
+type PaymentAuthorizePayload struct {
+	TotalCents int                   `json:"total_cents" proto:"1" schema:"minimum=0"`
+	Currency   string                `json:"currency" proto:"2" schema:"minLength=3"`
+	Order      *OrderSnapshotPayload `json:"order,omitempty" proto:"3"`
+}
+
+type PaymentCapturePayload struct {
+	Note string `json:"note,omitempty" proto:"1"`
+}
+
+type PaymentVoidPayload struct {
+	Reason string `json:"reason" proto:"1" schema:"minLength=1"`
+}
+
+type ShippingSchedulePayload struct {
+	Note  string                `json:"note,omitempty" proto:"1"`
+	Order *OrderSnapshotPayload `json:"order,omitempty" proto:"2"`
+}
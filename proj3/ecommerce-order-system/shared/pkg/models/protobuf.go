@@ -0,0 +1,545 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const ContentTypeProtobuf = "application/x-protobuf"
+
+var ErrProtobuf = errors.New("invalid protobuf event")
+
+// Envelope field numbers, see proto/events.proto.
+const (
+	envID                protowire.Number = 1
+	envType              protowire.Number = 2
+	envVersion           protowire.Number = 3
+	envTimeUnixNano      protowire.Number = 4
+	envOrderID           protowire.Number = 5
+	envPayload           protowire.Number = 6
+	envCorrelationID     protowire.Number = 7
+	envCausationID       protowire.Number = 8
+	envPublishedVersions protowire.Number = 9
+	envSeq               protowire.Number = 10
+)
+
+// protoField is one payload struct field with its `proto:"N"` number.
+type protoField struct {
+	num   protowire.Number
+	name  string
+	index int
+	typ   reflect.Type
+}
+
+// protoMessages holds the layout of every payload struct (and the structs
+// nested in them). Filled by register during init, read-only afterwards.
+var protoMessages = map[reflect.Type][]protoField{}
+
+func registerProto(t reflect.Type) error {
+	if _, ok := protoMessages[t]; ok {
+		return nil
+	}
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("payload %s is not a struct", t)
+	}
+
+	var fields []protoField
+	used := map[protowire.Number]string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("proto")
+		n, err := strconv.Atoi(tag)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("%s.%s: missing or bad proto tag %q", t.Name(), f.Name, tag)
+		}
+		num := protowire.Number(n)
+		if prev, ok := used[num]; ok {
+			return fmt.Errorf("%s: proto field %d used by both %s and %s", t.Name(), n, prev, f.Name)
+		}
+		used[num] = f.Name
+
+		elem := f.Type
+		switch elem.Kind() {
+		case reflect.Slice:
+			elem = elem.Elem()
+		case reflect.Pointer:
+			// an optional message: nil is left out on the wire
+			if elem.Elem().Kind() != reflect.Struct {
+				return fmt.Errorf("%s.%s: type %s has no protobuf mapping", t.Name(), f.Name, f.Type)
+			}
+			elem = elem.Elem()
+		}
+		switch elem.Kind() {
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Int32, reflect.Int64:
+		case reflect.Struct:
+			if err := registerProto(elem); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s.%s: type %s has no protobuf mapping", t.Name(), f.Name, f.Type)
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			name = strings.ToLower(f.Name)
+		}
+		fields = append(fields, protoField{num: num, name: name, index: i, typ: f.Type})
+	}
+	protoMessages[t] = fields
+	return nil
+}
+
+// MarshalProto encodes evt as an Envelope message. A json.RawMessage payload
+// is first decoded into the catalog struct of its type and version.
+func MarshalProto[T any](evt Event[T]) ([]byte, error) {
+	payload, err := marshalPayload(evt.Type, evt.Version, evt.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	b = appendString(b, envID, evt.ID)
+	b = appendString(b, envType, evt.Type)
+	if evt.Version != 0 {
+		b = protowire.AppendTag(b, envVersion, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(evt.Version))
+	}
+	if !evt.Time.IsZero() {
+		b = protowire.AppendTag(b, envTimeUnixNano, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(evt.Time.UnixNano()))
+	}
+	b = appendString(b, envOrderID, evt.OrderID)
+	if len(payload) > 0 {
+		b = protowire.AppendTag(b, envPayload, protowire.BytesType)
+		b = protowire.AppendBytes(b, payload)
+	}
+	b = appendString(b, envCorrelationID, evt.CorrelationID)
+	b = appendString(b, envCausationID, evt.CausationID)
+	if len(evt.PublishedVersions) > 0 {
+		var packed []byte
+		for _, v := range evt.PublishedVersions {
+			packed = protowire.AppendVarint(packed, uint64(v))
+		}
+		b = protowire.AppendTag(b, envPublishedVersions, protowire.BytesType)
+		b = protowire.AppendBytes(b, packed)
+	}
+	if evt.Seq != 0 {
+		b = protowire.AppendTag(b, envSeq, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(evt.Seq))
+	}
+	return b, nil
+}
+
+// UnmarshalProto decodes an Envelope message. With T = json.RawMessage the
+// payload is returned as JSON, ready for Decoder.
+func UnmarshalProto[T any](b []byte) (Event[T], error) {
+	var evt Event[T]
+	var payload []byte
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return evt, fmt.Errorf("%w: %v", ErrProtobuf, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		switch {
+		case typ == protowire.BytesType && num != envPublishedVersions:
+			v, m := protowire.ConsumeBytes(b)
+			if m < 0 {
+				return evt, fmt.Errorf("%w: %v", ErrProtobuf, protowire.ParseError(m))
+			}
+			switch num {
+			case envID:
+				evt.ID = string(v)
+			case envType:
+				evt.Type = string(v)
+			case envOrderID:
+				evt.OrderID = string(v)
+			case envPayload:
+				payload = v
+			case envCorrelationID:
+				evt.CorrelationID = string(v)
+			case envCausationID:
+				evt.CausationID = string(v)
+			}
+			n = m
+		case typ == protowire.VarintType && (num == envVersion || num == envTimeUnixNano || num == envPublishedVersions || num == envSeq):
+			v, m := protowire.ConsumeVarint(b)
+			if m < 0 {
+				return evt, fmt.Errorf("%w: %v", ErrProtobuf, protowire.ParseError(m))
+			}
+			switch num {
+			case envVersion:
+				evt.Version = int(int32(v))
+			case envTimeUnixNano:
+				evt.Time = time.Unix(0, int64(v)).UTC()
+			case envPublishedVersions:
+				evt.PublishedVersions = append(evt.PublishedVersions, int(int32(v)))
+			case envSeq:
+				evt.Seq = int64(v)
+			}
+			n = m
+		case typ == protowire.BytesType:
+			v, m := protowire.ConsumeBytes(b)
+			if m < 0 {
+				return evt, fmt.Errorf("%w: %v", ErrProtobuf, protowire.ParseError(m))
+			}
+			for len(v) > 0 {
+				x, k := protowire.ConsumeVarint(v)
+				if k < 0 {
+					return evt, fmt.Errorf("%w: %v", ErrProtobuf, protowire.ParseError(k))
+				}
+				evt.PublishedVersions = append(evt.PublishedVersions, int(int32(x)))
+				v = v[k:]
+			}
+			n = m
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return evt, fmt.Errorf("%w: %v", ErrProtobuf, protowire.ParseError(n))
+			}
+		}
+		b = b[n:]
+	}
+
+	if err := unmarshalPayload(evt.Type, evt.Version, payload, &evt.Payload); err != nil {
+		return evt, err
+	}
+	return evt, nil
+}
+
+// EncodeProto converts a serialized JSON envelope to protobuf.
+func EncodeProto(body []byte) ([]byte, error) {
+	var evt Event[json.RawMessage]
+	if err := json.Unmarshal(body, &evt); err != nil {
+		return nil, err
+	}
+	return MarshalProto(evt)
+}
+
+// DecodeProto converts a protobuf envelope back to JSON.
+func DecodeProto(b []byte) ([]byte, error) {
+	evt, err := UnmarshalProto[json.RawMessage](b)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(evt)
+}
+
+func payloadType(eventType string, version int) (reflect.Type, error) {
+	spec, ok := catalog[eventType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnregisteredEvent, eventType)
+	}
+	t, ok := spec.Payloads[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s v%d", ErrUnknownVersion, eventType, version)
+	}
+	return t, nil
+}
+
+func marshalPayload(eventType string, version int, payload any) ([]byte, error) {
+	if raw, ok := payload.(json.RawMessage); ok {
+		t, err := payloadType(eventType, version)
+		if err != nil {
+			return nil, err
+		}
+		v := reflect.New(t)
+		if err := json.Unmarshal(raw, v.Interface()); err != nil {
+			return nil, err
+		}
+		return appendMessage(nil, v.Elem()), nil
+	}
+
+	v := reflect.ValueOf(payload)
+	if _, ok := protoMessages[v.Type()]; !ok {
+		return nil, fmt.Errorf("%w: payload %s is not in the catalog", ErrProtobuf, v.Type())
+	}
+	return appendMessage(nil, v), nil
+}
+
+func unmarshalPayload[T any](eventType string, version int, b []byte, dst *T) error {
+	if _, ok := any(*dst).(json.RawMessage); ok {
+		t, err := payloadType(eventType, version)
+		if err != nil {
+			return err
+		}
+		v := reflect.New(t).Elem()
+		if err := consumeMessage(b, v); err != nil {
+			return err
+		}
+		raw, err := json.Marshal(v.Interface())
+		if err != nil {
+			return err
+		}
+		*dst = any(json.RawMessage(raw)).(T)
+		return nil
+	}
+
+	v := reflect.ValueOf(dst).Elem()
+	if _, ok := protoMessages[v.Type()]; !ok {
+		return fmt.Errorf("%w: payload %s is not in the catalog", ErrProtobuf, v.Type())
+	}
+	return consumeMessage(b, v)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendMessage(b []byte, v reflect.Value) []byte {
+	for _, f := range protoMessages[v.Type()] {
+		fv := v.Field(f.index)
+		if fv.Kind() != reflect.Slice {
+			b = appendValue(b, f.num, fv, false)
+			continue
+		}
+		switch f.typ.Elem().Kind() {
+		case reflect.String, reflect.Struct:
+			for i := 0; i < fv.Len(); i++ {
+				b = appendValue(b, f.num, fv.Index(i), true)
+			}
+		default:
+			if fv.Len() == 0 {
+				continue
+			}
+			var packed []byte
+			for i := 0; i < fv.Len(); i++ {
+				packed = protowire.AppendVarint(packed, varintOf(fv.Index(i)))
+			}
+			b = protowire.AppendTag(b, f.num, protowire.BytesType)
+			b = protowire.AppendBytes(b, packed)
+		}
+	}
+	return b
+}
+
+// appendValue writes one field value; proto3 defaults are skipped unless the
+// value is a repeated element.
+func appendValue(b []byte, num protowire.Number, v reflect.Value, repeated bool) []byte {
+	switch v.Kind() {
+	case reflect.String:
+		if v.Len() == 0 && !repeated {
+			return b
+		}
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		return protowire.AppendString(b, v.String())
+	case reflect.Struct:
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		return protowire.AppendBytes(b, appendMessage(nil, v))
+	case reflect.Pointer:
+		if v.IsNil() {
+			return b
+		}
+		return appendValue(b, num, v.Elem(), repeated)
+	default:
+		x := varintOf(v)
+		if x == 0 && !repeated {
+			return b
+		}
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		return protowire.AppendVarint(b, x)
+	}
+}
+
+func varintOf(v reflect.Value) uint64 {
+	if v.Kind() == reflect.Bool {
+		return protowire.EncodeBool(v.Bool())
+	}
+	return uint64(v.Int())
+}
+
+func setVarint(v reflect.Value, x uint64) {
+	if v.Kind() == reflect.Bool {
+		v.SetBool(protowire.DecodeBool(x))
+		return
+	}
+	v.SetInt(int64(x))
+}
+
+func consumeMessage(b []byte, v reflect.Value) error {
+	fields := protoMessages[v.Type()]
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("%w: %v", ErrProtobuf, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		i := slices.IndexFunc(fields, func(f protoField) bool { return f.num == num })
+		if i < 0 {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return fmt.Errorf("%w: %v", ErrProtobuf, protowire.ParseError(n))
+			}
+			b = b[n:]
+			continue
+		}
+
+		n, err := consumeField(b, typ, fields[i], v.Field(fields[i].index))
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func consumeField(b []byte, typ protowire.Type, f protoField, fv reflect.Value) (int, error) {
+	if fv.Kind() != reflect.Slice {
+		return consumeValue(b, typ, f.num, fv)
+	}
+
+	elem := f.typ.Elem()
+	if typ == protowire.BytesType && elem.Kind() != reflect.String && elem.Kind() != reflect.Struct {
+		packed, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return 0, fmt.Errorf("%w: %v", ErrProtobuf, protowire.ParseError(n))
+		}
+		for len(packed) > 0 {
+			x, m := protowire.ConsumeVarint(packed)
+			if m < 0 {
+				return 0, fmt.Errorf("%w: %v", ErrProtobuf, protowire.ParseError(m))
+			}
+			e := reflect.New(elem).Elem()
+			setVarint(e, x)
+			fv.Set(reflect.Append(fv, e))
+			packed = packed[m:]
+		}
+		return n, nil
+	}
+
+	e := reflect.New(elem).Elem()
+	n, err := consumeValue(b, typ, f.num, e)
+	if err != nil {
+		return 0, err
+	}
+	fv.Set(reflect.Append(fv, e))
+	return n, nil
+}
+
+func consumeValue(b []byte, typ protowire.Type, num protowire.Number, v reflect.Value) (int, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	want := protowire.VarintType
+	if v.Kind() == reflect.String || v.Kind() == reflect.Struct {
+		want = protowire.BytesType
+	}
+	if typ != want {
+		return 0, fmt.Errorf("%w: field %d has wire type %d, want %d", ErrProtobuf, num, typ, want)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		s, n := protowire.ConsumeString(b)
+		if n < 0 {
+			return 0, fmt.Errorf("%w: %v", ErrProtobuf, protowire.ParseError(n))
+		}
+		v.SetString(s)
+		return n, nil
+	case reflect.Struct:
+		msg, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return 0, fmt.Errorf("%w: %v", ErrProtobuf, protowire.ParseError(n))
+		}
+		return n, consumeMessage(msg, v)
+	default:
+		x, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			return 0, fmt.Errorf("%w: %v", ErrProtobuf, protowire.ParseError(n))
+		}
+		setVarint(v, x)
+		return n, nil
+	}
+}
+
+// ProtoSchema renders the .proto definitions of the envelope and every
+// registered payload version; written to proto/events.proto by go generate.
+func ProtoSchema() string {
+	var sb strings.Builder
+	sb.WriteString("// Code generated by eventschemas. DO NOT EDIT.\n\n")
+	sb.WriteString("syntax = \"proto3\";\n\npackage orders.events;\n\n")
+	sb.WriteString(`// Envelope wraps every event. payload holds the message listed below for the
+// event's (type, version); the content type is application/x-protobuf.
+message Envelope {
+  string id = 1;
+  string type = 2;
+  int32 version = 3;
+  int64 time_unix_nano = 4;
+  string order_id = 5;
+  bytes payload = 6;
+  string correlation_id = 7;
+  string causation_id = 8;
+  repeated int32 published_versions = 9;
+  int64 seq = 10;
+}
+`)
+
+	var types []reflect.Type
+	for _, spec := range Specs() {
+		versions := make([]int, 0, len(spec.Payloads))
+		for v := range spec.Payloads {
+			versions = append(versions, v)
+		}
+		slices.Sort(versions)
+		for _, v := range versions {
+			fmt.Fprintf(&sb, "\n// %s v%d: %s", spec.Type, v, spec.Payloads[v].Name())
+		}
+	}
+	sb.WriteString("\n")
+	for t := range protoMessages {
+		types = append(types, t)
+	}
+	slices.SortFunc(types, func(a, b reflect.Type) int { return strings.Compare(a.Name(), b.Name()) })
+
+	for _, t := range types {
+		fmt.Fprintf(&sb, "\nmessage %s {\n", t.Name())
+		for _, f := range protoMessages[t] {
+			ft := f.typ
+			label := ""
+			if ft.Kind() == reflect.Slice {
+				label = "repeated "
+				ft = ft.Elem()
+			}
+			fmt.Fprintf(&sb, "  %s%s %s = %d;\n", label, protoTypeName(ft), f.name, f.num)
+		}
+		sb.WriteString("}\n")
+	}
+	return sb.String()
+}
+
+func protoTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int32:
+		return "int32"
+	case reflect.Struct:
+		return t.Name()
+	case reflect.Pointer:
+		return protoTypeName(t.Elem())
+	default:
+		return "int64"
+	}
+}
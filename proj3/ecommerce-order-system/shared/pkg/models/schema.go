@@ -0,0 +1,228 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of JSON Schema (draft 2020-12) generated for catalog
+// events: types, properties, required, items and a few numeric/string bounds.
+type Schema struct {
+	Schema     string             `json:"$schema,omitempty"`
+	ID         string             `json:"$id,omitempty"`
+	Title      string             `json:"title,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	Format     string             `json:"format,omitempty"`
+	Const      any                `json:"const,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+	Minimum    *float64           `json:"minimum,omitempty"`
+	MinLength  *int               `json:"minLength,omitempty"`
+	MinItems   *int               `json:"minItems,omitempty"`
+}
+
+var (
+	timeType = reflect.TypeFor[time.Time]()
+	rawType  = reflect.TypeFor[json.RawMessage]()
+)
+
+func envelopeSchema(eventType string, version int, payload reflect.Type) *Schema {
+	one := 1.0
+	minLen := 1
+	return &Schema{
+		Schema: "https://json-schema.org/draft/2020-12/schema",
+		ID:     fmt.Sprintf("https://ecommerce-order-system/events/%s.v%d.json", eventType, version),
+		Title:  eventType,
+		Type:   "object",
+		Properties: map[string]*Schema{
+			"id":                 {Type: "string", MinLength: &minLen},
+			"type":               {Type: "string", Const: eventType},
+			"version":            {Type: "integer", Minimum: &one},
+			"time":               {Type: "string", Format: "date-time"},
+			"order_id":           {Type: "string", MinLength: &minLen},
+			"payload":            schemaOf(payload),
+			"published_versions": {Type: "array", Items: &Schema{Type: "integer", Minimum: &one}},
+			"correlation_id":     {Type: "string"},
+			"causation_id":       {Type: "string"},
+			"seq":                {Type: "integer", Minimum: &one},
+		},
+		Required: []string{"id", "type", "version", "time", "order_id", "payload"},
+	}
+}
+
+// schemaOf derives a schema from a Go type. Fields without omitempty are
+// required; `schema:"minLength=1,minimum=0,minItems=1"` adds bounds.
+func schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			fs := schemaOf(f.Type)
+			applyTag(fs, f.Tag.Get("schema"))
+			s.Properties[name] = fs
+			if !strings.Contains(opts, "omitempty") {
+				s.Required = append(s.Required, name)
+			}
+		}
+		return s
+	default:
+		return &Schema{}
+	}
+}
+
+func applyTag(s *Schema, tag string) {
+	for _, kv := range strings.Split(tag, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "minimum":
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				s.Minimum = &f
+			}
+		case "minLength":
+			if n, err := strconv.Atoi(v); err == nil {
+				s.MinLength = &n
+			}
+		case "minItems":
+			if n, err := strconv.Atoi(v); err == nil {
+				s.MinItems = &n
+			}
+		}
+	}
+}
+
+func decodeAny(body []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Validate checks a value decoded with json.Decoder.UseNumber against s.
+func (s *Schema) Validate(v any) error {
+	return s.validate(v, "$")
+}
+
+func (s *Schema) validate(v any, path string) error {
+	if s == nil {
+		return nil
+	}
+	if s.Const != nil && v != s.Const {
+		return fmt.Errorf("%s: must be %v", path, s.Const)
+	}
+
+	switch s.Type {
+	case "":
+		return nil
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected object", path)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s.%s: required", path, name)
+			}
+		}
+		for name, ps := range s.Properties {
+			if pv, ok := obj[name]; ok {
+				if err := ps.validate(pv, path+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected array", path)
+		}
+		if s.MinItems != nil && len(arr) < *s.MinItems {
+			return fmt.Errorf("%s: expected at least %d items", path, *s.MinItems)
+		}
+		for i, item := range arr {
+			if err := s.Items.validate(item, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected string", path)
+		}
+		if s.MinLength != nil && len([]rune(str)) < *s.MinLength {
+			return fmt.Errorf("%s: shorter than %d", path, *s.MinLength)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				return fmt.Errorf("%s: not an RFC 3339 date-time", path)
+			}
+		}
+	case "integer", "number":
+		n, ok := v.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s: expected %s", path, s.Type)
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if s.Type == "integer" {
+			if _, err := n.Int64(); err != nil {
+				return fmt.Errorf("%s: expected integer", path)
+			}
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return fmt.Errorf("%s: less than %v", path, *s.Minimum)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean", path)
+		}
+	}
+	return nil
+}
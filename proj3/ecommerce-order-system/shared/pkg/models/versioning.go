@@ -0,0 +1,220 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownVersion = errors.New("unknown event version")
+	// ErrUnsupportedVersion: the event is newer than anything the consumer accepts
+	// and cannot be downgraded on read. Park it (DLQ) until the consumer is upgraded.
+	ErrUnsupportedVersion = errors.New("unsupported event version")
+	// ErrSkipped: a dual-published copy meant for consumers on another version.
+	ErrSkipped = errors.New("event copy skipped")
+)
+
+// PayloadConverter rewrites a payload between two adjacent versions.
+type PayloadConverter func(json.RawMessage) (json.RawMessage, error)
+
+type convKey struct {
+	eventType string
+	from      int
+}
+
+var (
+	upcasters   = map[convKey]PayloadConverter{}
+	downcasters = map[convKey]PayloadConverter{}
+)
+
+// RegisterUpcaster converts eventType payloads from version `from` to from+1.
+func RegisterUpcaster(eventType string, from int, fn PayloadConverter) {
+	upcasters[convKey{eventType, from}] = fn
+}
+
+// RegisterDowncaster converts eventType payloads from version `from` to from-1;
+// used to dual-publish the previous version during a migration.
+func RegisterDowncaster(eventType string, from int, fn PayloadConverter) {
+	downcasters[convKey{eventType, from}] = fn
+}
+
+// ConvertPayload walks the registered converters from one version to another.
+func ConvertPayload(eventType string, payload json.RawMessage, from, to int) (json.RawMessage, error) {
+	for v := from; v != to; {
+		var (
+			fn   PayloadConverter
+			ok   bool
+			next int
+		)
+		if v < to {
+			fn, ok = upcasters[convKey{eventType, v}]
+			next = v + 1
+		} else {
+			fn, ok = downcasters[convKey{eventType, v}]
+			next = v - 1
+		}
+		if !ok {
+			return nil, fmt.Errorf("no converter for %s v%d -> v%d", eventType, v, next)
+		}
+		out, err := fn(payload)
+		if err != nil {
+			return nil, fmt.Errorf("convert %s v%d -> v%d: %w", eventType, v, next, err)
+		}
+		payload, v = out, next
+	}
+	return payload, nil
+}
+
+// Decoder decodes events for a consumer that declares which payload versions
+// it understands per event type. Types not listed accept the current version.
+//
+// A message in an accepted version is returned as is; an older one is upcast
+// to the nearest accepted version above it; a newer one fails with
+// ErrUnsupportedVersion. For dual-published events only one copy is processed:
+// the highest published version the consumer accepts, otherwise the highest
+// one it can upcast. The other copies fail with ErrSkipped and should be acked.
+type Decoder struct {
+	Accept map[string][]int
+}
+
+// AcceptAll returns a Decoder that takes every registered version as is, for
+// consumers that keep events verbatim rather than interpret them.
+func AcceptAll() Decoder {
+	accept := map[string][]int{}
+	for _, spec := range catalog {
+		for v := range spec.Versions {
+			accept[spec.Type] = append(accept[spec.Type], v)
+		}
+	}
+	return Decoder{Accept: accept}
+}
+
+func (d Decoder) accepted(eventType string) []int {
+	if vs := d.Accept[eventType]; len(vs) > 0 {
+		return vs
+	}
+	return []int{CurrentVersion(eventType)}
+}
+
+func (d Decoder) Decode(routingKey string, body []byte) (Event[json.RawMessage], error) {
+	var evt Event[json.RawMessage]
+	if err := Validate(routingKey, body); err != nil {
+		return evt, err
+	}
+	if err := json.Unmarshal(body, &evt); err != nil {
+		return evt, err
+	}
+	if evt.Type != routingKey {
+		return evt, fmt.Errorf("event type %q does not match routing key %q", evt.Type, routingKey)
+	}
+
+	accepted := d.accepted(evt.Type)
+	maxAccepted := slices.Max(accepted)
+
+	if len(evt.PublishedVersions) > 0 && evt.Version != pickCopy(evt.PublishedVersions, accepted, maxAccepted) {
+		return evt, ErrSkipped
+	}
+	if slices.Contains(accepted, evt.Version) {
+		return evt, nil
+	}
+	if evt.Version > maxAccepted {
+		return evt, fmt.Errorf("%w: %s v%d (accepts %v)", ErrUnsupportedVersion, evt.Type, evt.Version, accepted)
+	}
+
+	target := maxAccepted
+	for _, v := range accepted {
+		if v > evt.Version && v < target {
+			target = v
+		}
+	}
+	payload, err := ConvertPayload(evt.Type, evt.Payload, evt.Version, target)
+	if err != nil {
+		return evt, err
+	}
+	evt.Payload, evt.Version = payload, target
+
+	upcast, err := json.Marshal(evt)
+	if err != nil {
+		return evt, err
+	}
+	if err := Validate(routingKey, upcast); err != nil {
+		return evt, fmt.Errorf("upcast result invalid: %w", err)
+	}
+	return evt, nil
+}
+
+func pickCopy(published, accepted []int, maxAccepted int) int {
+	best := 0
+	for _, v := range published {
+		if slices.Contains(accepted, v) && v > best {
+			best = v
+		}
+	}
+	if best > 0 {
+		return best
+	}
+	for _, v := range published {
+		if v < maxAccepted && v > best {
+			best = v
+		}
+	}
+	if best > 0 {
+		return best
+	}
+	return slices.Max(published)
+}
+
+// DualPublish returns evt plus a copy for each extra version, payloads produced
+// by the registered converters. All copies share the event ID and time and
+// list every published version so each consumer handles exactly one of them.
+func DualPublish[T any](evt Event[T], extra ...int) ([]Event[json.RawMessage], error) {
+	raw, err := json.Marshal(evt.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []int{evt.Version}
+	for _, v := range extra {
+		if !slices.Contains(versions, v) {
+			versions = append(versions, v)
+		}
+	}
+	slices.Sort(versions)
+
+	out := make([]Event[json.RawMessage], 0, len(versions))
+	for _, v := range versions {
+		p, err := ConvertPayload(evt.Type, raw, evt.Version, v)
+		if err != nil {
+			return nil, err
+		}
+		c := Event[json.RawMessage]{
+			ID:            evt.ID,
+			Type:          evt.Type,
+			Version:       v,
+			Time:          evt.Time,
+			OrderID:       evt.OrderID,
+			Payload:       p,
+			CorrelationID: evt.CorrelationID,
+			CausationID:   evt.CausationID,
+			Seq:           evt.Seq,
+		}
+		if len(versions) > 1 {
+			c.PublishedVersions = versions
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// CopyMessageID gives each dual-published copy its own message/outbox ID
+// (the current version keeps the event ID) so broker-level dedup keeps them apart.
+func CopyMessageID[T any](c Event[T], current int) string {
+	if c.Version == current {
+		return c.ID
+	}
+	return uuid.NewSHA1(uuid.NameSpaceOID, fmt.Appendf(nil, "%s/v%d", c.ID, c.Version)).String()
+}
@@ -0,0 +1,55 @@
+// Package outbox writes events into outbox_events, from where outbox-worker
+// publishes them once the writing transaction has committed.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5"
+
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+// Enqueue writes an event and its message headers into outbox_events within the given transaction.
+func Enqueue(ctx context.Context, tx pgx.Tx, eventID string, orderID string, eventType string, payload any, headers models.OutboxHeaders) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if err := models.Validate(eventType, b); err != nil {
+		return err
+	}
+	h, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		insert into outbox_events(
+			id, order_id, event_type, payload, headers,
+			attempts, next_attempt_at, created_at
+		)
+		values ($1::uuid, $2::uuid, $3, $4::jsonb, $5::jsonb, 0, now(), now())
+	`, eventID, orderID, eventType, string(b), string(h))
+	return err
+}
+
+// EnqueueEvent enqueues evt linked to the event being handled in ctx
+// (models.ContextWithEvent) the way rabbit.Publisher links what it publishes:
+// empty correlation and causation IDs are taken from the links and a missing
+// Seq follows the cause's.
+func EnqueueEvent[T any](ctx context.Context, tx pgx.Tx, source string, evt models.Event[T]) error {
+	links, _ := models.LinksFromContext(ctx)
+	if evt.CorrelationID == "" {
+		evt.CorrelationID = links.CorrelationID
+	}
+	if evt.CausationID == "" {
+		evt.CausationID = links.CausationID
+	}
+	if evt.Seq <= 0 {
+		evt.Seq = links.Seq + 1
+	}
+	headers := models.NewOutboxHeaders(evt)
+	headers.Source = source
+	return Enqueue(ctx, tx, evt.ID, evt.OrderID, evt.Type, evt, headers)
+}
@@ -0,0 +1,122 @@
+package rabbit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"ecommerce-order-system/shared/pkg/config"
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+// CloudEventsHeaderPrefix marks CloudEvents attributes in binary content mode
+// (AMQP protocol binding).
+const CloudEventsHeaderPrefix = "cloudEvents:"
+
+// UseFormat switches messages sent to the events exchange to a CloudEvents
+// content mode (config.EventsFormat*). source becomes the CloudEvents source
+// unless a message carries its own AppId.
+func (p *Publisher) UseFormat(format, source string) *Publisher {
+	p.format = format
+	p.source = source
+	return p
+}
+
+// toCloudEvents rewrites a legacy-envelope message into the publisher's format.
+// Messages that are already CloudEvents are left alone.
+func (p *Publisher) toCloudEvents(msg amqp.Publishing) (amqp.Publishing, error) {
+	if p.exchange != ExchangeEvents || p.format == "" || p.format == config.EventsFormatLegacy {
+		return msg, nil
+	}
+	if msg.ContentType != models.ContentTypeJSON || isBinaryCloudEvent(msg.Headers) {
+		return msg, nil
+	}
+
+	source := msg.AppId
+	if source == "" {
+		source = p.source
+	}
+	ce, err := models.ToCloudEvent(msg.Body, source)
+	if err != nil {
+		return msg, err
+	}
+
+	switch p.format {
+	case config.EventsFormatCloudEvents:
+		b, err := json.Marshal(ce)
+		if err != nil {
+			return msg, err
+		}
+		msg.ContentType = models.ContentTypeCloudEventsJSON
+		msg.Body = b
+	case config.EventsFormatCloudEventsBinary:
+		h := amqp.Table{}
+		for k, v := range msg.Headers {
+			h[k] = v
+		}
+		for k, v := range ce.Attributes() {
+			h[CloudEventsHeaderPrefix+k] = v
+		}
+		msg.Headers = h
+		msg.ContentType = ce.DataContentType
+		msg.Body = ce.Data
+	default:
+		return msg, fmt.Errorf("unknown events format %q", p.format)
+	}
+	return msg, nil
+}
+
+// EnvelopeBody returns the body of d as a JSON legacy envelope, whether it
+// arrived as one, in another encoding (see Codec) or as a CloudEvent in
+// structured or binary mode.
+func EnvelopeBody(d amqp.Delivery) ([]byte, error) {
+	if isBinaryCloudEvent(d.Headers) {
+		attrs := map[string]string{}
+		for k, v := range d.Headers {
+			name, ok := strings.CutPrefix(k, CloudEventsHeaderPrefix)
+			if !ok {
+				continue
+			}
+			s, ok := v.(string)
+			if !ok {
+				s = fmt.Sprint(v)
+			}
+			attrs[name] = s
+		}
+		ce, err := models.CloudEventFromAttributes(attrs, d.ContentType, d.Body)
+		if err != nil {
+			return nil, err
+		}
+		return ce.Envelope()
+	}
+
+	if strings.HasPrefix(d.ContentType, models.ContentTypeCloudEventsJSON) {
+		var ce models.CloudEvent
+		if err := json.Unmarshal(d.Body, &ce); err != nil {
+			return nil, fmt.Errorf("%w: %v", models.ErrInvalidCloudEvent, err)
+		}
+		return ce.Envelope()
+	}
+
+	codec, err := CodecFor(d.ContentType)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Decode(d.Body)
+}
+
+// DecodeDelivery decodes d with dec in any supported content mode.
+func DecodeDelivery(dec models.Decoder, d amqp.Delivery) (models.Event[json.RawMessage], error) {
+	body, err := EnvelopeBody(d)
+	if err != nil {
+		return models.Event[json.RawMessage]{}, err
+	}
+	return dec.Decode(d.RoutingKey, body)
+}
+
+func isBinaryCloudEvent(h amqp.Table) bool {
+	_, ok := h[CloudEventsHeaderPrefix+"specversion"]
+	return ok
+}
@@ -0,0 +1,90 @@
+package rabbit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"ecommerce-order-system/shared/pkg/config"
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+// Codec converts between the JSON envelope the services work with and a wire
+// encoding. Publishers pick one by name (EVENTS_ENCODING); consumers pick by
+// the message content type, so every encoding is accepted everywhere.
+type Codec interface {
+	Name() string
+	ContentType() string
+	Encode(envelope []byte) ([]byte, error)
+	Decode(body []byte) ([]byte, error)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                    { return config.EventsEncodingJSON }
+func (jsonCodec) ContentType() string             { return models.ContentTypeJSON }
+func (jsonCodec) Encode(b []byte) ([]byte, error) { return b, nil }
+func (jsonCodec) Decode(b []byte) ([]byte, error) { return b, nil }
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string                    { return config.EventsEncodingProtobuf }
+func (protobufCodec) ContentType() string             { return models.ContentTypeProtobuf }
+func (protobufCodec) Encode(b []byte) ([]byte, error) { return models.EncodeProto(b) }
+func (protobufCodec) Decode(b []byte) ([]byte, error) { return models.DecodeProto(b) }
+
+var codecs = []Codec{jsonCodec{}, protobufCodec{}}
+
+// CodecFor returns the codec for a message content type; no content type means JSON.
+func CodecFor(contentType string) (Codec, error) {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	if mediaType == "" {
+		return jsonCodec{}, nil
+	}
+	for _, c := range codecs {
+		if c.ContentType() == mediaType {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedContentType, contentType)
+}
+
+// CodecNamed returns the codec registered under a config.EventsEncoding* name.
+func CodecNamed(name string) (Codec, error) {
+	for _, c := range codecs {
+		if c.Name() == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown events encoding %q", name)
+}
+
+// UseEncoding makes the publisher encode envelopes sent to the events exchange
+// with the named codec. Unknown names keep JSON; config validation rejects them.
+func (p *Publisher) UseEncoding(name string) *Publisher {
+	if c, err := CodecNamed(name); err == nil {
+		p.codec = c
+	}
+	return p
+}
+
+func (p *Publisher) encode(msg amqp.Publishing) (amqp.Publishing, error) {
+	if p.exchange != ExchangeEvents || p.codec == nil || msg.ContentType != models.ContentTypeJSON {
+		return msg, nil
+	}
+	if isBinaryCloudEvent(msg.Headers) {
+		return msg, nil
+	}
+	b, err := p.codec.Encode(msg.Body)
+	if err != nil {
+		return msg, err
+	}
+	msg.Body = b
+	msg.ContentType = p.codec.ContentType()
+	return msg, nil
+}
@@ -3,9 +3,12 @@ package rabbit
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
+
+	"ecommerce-order-system/shared/pkg/models"
 )
 
 const (
@@ -58,8 +61,11 @@ func DeclareBase(ch *amqp.Channel) error {
 type QueueSpec struct {
 	Name     string
 	BindKeys []string
-	DLQKey   string
-	Prefetch int
+	// UnbindKeys are removed from the queue if bound, e.g. the keys of the
+	// other SAGA_MODE after switching modes.
+	UnbindKeys []string
+	DLQKey     string
+	Prefetch   int
 }
 
 func DeclareQueueWithDLQ(ch *amqp.Channel, spec QueueSpec) error {
@@ -90,6 +96,11 @@ func DeclareQueueWithDLQ(ch *amqp.Channel, spec QueueSpec) error {
 			return err
 		}
 	}
+	for _, key := range spec.UnbindKeys {
+		if err := ch.QueueUnbind(spec.Name, key, ExchangeEvents, nil); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -110,27 +121,163 @@ func DeclareRetryQueue(ch *amqp.Channel, name string, retryRoutingKey string, or
 type Publisher struct {
 	ch       *amqp.Channel
 	exchange string
+	confirm  bool
+
+	// format/source: see UseFormat; codec: see UseEncoding.
+	format string
+	source string
+	codec  Codec
 }
 
 func NewPublisher(ch *amqp.Channel, exchange string) *Publisher {
 	return &Publisher{ch: ch, exchange: exchange}
 }
 
+// NewConfirmPublisher puts ch into confirm mode; every publish then waits for
+// the broker ack and fails on nack or ctx timeout.
+func NewConfirmPublisher(ch *amqp.Channel, exchange string) (*Publisher, error) {
+	if err := ch.Confirm(false); err != nil {
+		return nil, err
+	}
+	return &Publisher{ch: ch, exchange: exchange, confirm: true}, nil
+}
+
 func (p *Publisher) Publish(ctx context.Context, routingKey string, body []byte, headers amqp.Table) error {
-	return p.ch.PublishWithContext(ctx, p.exchange, routingKey, false, false, amqp.Publishing{
-		ContentType: "application/json",
-		Body:        body,
-		Headers:     headers,
-		Timestamp:   time.Now(),
+	return p.PublishMessage(ctx, routingKey, amqp.Publishing{
+		Body:    body,
+		Headers: headers,
 	})
 }
 
+// PublishMessage sends msg, defaulting ContentType and Timestamp when unset and
+// converting it to the publisher's CloudEvents format or encoding, if any.
+func (p *Publisher) PublishMessage(ctx context.Context, routingKey string, msg amqp.Publishing) error {
+	if msg.ContentType == "" {
+		msg.ContentType = models.ContentTypeJSON
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	msg, err := p.toCloudEvents(msg)
+	if err != nil {
+		return err
+	}
+	if msg, err = p.encode(msg); err != nil {
+		return err
+	}
+	if !p.confirm {
+		return p.ch.PublishWithContext(ctx, p.exchange, routingKey, false, false, msg)
+	}
+
+	dc, err := p.ch.PublishWithDeferredConfirmWithContext(ctx, p.exchange, routingKey, false, false, msg)
+	if err != nil {
+		return err
+	}
+	ok, err := dc.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("broker nacked message to %s/%s", p.exchange, routingKey)
+	}
+	return nil
+}
+
+// PublishJSON marshals v and publishes it. Messages for the events exchange
+// must match a catalog entry (models.Validate); unregistered types are rejected.
+// When ctx carries saga links (models.ContextWithEvent) and the envelope has
+// none, they are filled in and mirrored onto CorrelationId / x-causation-id.
+// An envelope without seq gets the cause's seq + 1 (1 without a cause).
 func (p *Publisher) PublishJSON(ctx context.Context, routingKey string, v any, headers amqp.Table) error {
 	b, err := json.Marshal(v)
 	if err != nil {
 		return err
 	}
-	return p.Publish(ctx, routingKey, b, headers)
+	if p.exchange != ExchangeEvents {
+		return p.Publish(ctx, routingKey, b, headers)
+	}
+
+	links, _ := models.LinksFromContext(ctx)
+	b, links, err = linkEnvelope(b, links)
+	if err != nil {
+		return err
+	}
+	if err := models.Validate(routingKey, b); err != nil {
+		return err
+	}
+
+	h := amqp.Table{}
+	for k, v := range headers {
+		h[k] = v
+	}
+	if links.CorrelationID != "" {
+		h["x-correlation-id"] = links.CorrelationID
+	}
+	if links.CausationID != "" {
+		h["x-causation-id"] = links.CausationID
+	}
+	var env struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(b, &env)
+
+	return p.PublishMessage(ctx, routingKey, amqp.Publishing{
+		MessageId:     env.ID,
+		Type:          routingKey,
+		AppId:         p.source,
+		CorrelationId: links.CorrelationID,
+		DeliveryMode:  amqp.Persistent,
+		Headers:       h,
+		Body:          b,
+	})
+}
+
+// linkEnvelope fills empty correlation_id/causation_id fields and a missing
+// seq of a serialized envelope from links and returns the links the envelope
+// ends up with (Seq being the envelope's own seq).
+func linkEnvelope(b []byte, links models.Links) ([]byte, models.Links, error) {
+	var env map[string]json.RawMessage
+	if err := json.Unmarshal(b, &env); err != nil {
+		return nil, links, err
+	}
+
+	changed := false
+	for _, f := range []struct {
+		key string
+		val *string
+	}{
+		{"correlation_id", &links.CorrelationID},
+		{"causation_id", &links.CausationID},
+	} {
+		var cur string
+		if raw, ok := env[f.key]; ok {
+			_ = json.Unmarshal(raw, &cur)
+		}
+		switch {
+		case cur != "":
+			*f.val = cur
+		case *f.val != "":
+			env[f.key], _ = json.Marshal(*f.val)
+			changed = true
+		}
+	}
+
+	var seq int64
+	if raw, ok := env["seq"]; ok {
+		_ = json.Unmarshal(raw, &seq)
+	}
+	if seq <= 0 {
+		seq = links.Seq + 1
+		env["seq"], _ = json.Marshal(seq)
+		changed = true
+	}
+	links.Seq = seq
+
+	if !changed {
+		return b, links, nil
+	}
+	out, err := json.Marshal(env)
+	return out, links, err
 }
 
 type Consumer struct{ ch *amqp.Channel }
@@ -172,12 +319,26 @@ func RetryOrDLQ(ctx context.Context, d amqp.Delivery, service string, maxAttempt
 	pubCtx, cancel := WithTimeout(ctx)
 	defer cancel()
 
+	// Keep the properties consumers rely on: CloudEvents structured mode is
+	// recognised by content type.
+	msg := amqp.Publishing{
+		ContentType:   d.ContentType,
+		MessageId:     d.MessageId,
+		Type:          d.Type,
+		AppId:         d.AppId,
+		CorrelationId: d.CorrelationId,
+		Timestamp:     d.Timestamp,
+		DeliveryMode:  d.DeliveryMode,
+		Headers:       h,
+		Body:          d.Body,
+	}
+
 	if attempts <= maxAttempts {
 		retryRK := service + "." + d.RoutingKey
 		_ = d.Ack(false)
-		return retryPub.Publish(pubCtx, retryRK, d.Body, h)
+		return retryPub.PublishMessage(pubCtx, retryRK, msg)
 	}
 
 	_ = d.Ack(false)
-	return dlqPub.Publish(pubCtx, dlqKey, d.Body, h)
+	return dlqPub.PublishMessage(pubCtx, dlqKey, msg)
 }
@@ -0,0 +1,59 @@
+// Command eventschemas writes the JSON Schema of every catalog event version
+// to <out>/<type>.v<version>.json and, with -proto, the protobuf definitions.
+// Run via `go generate ./shared/pkg/models`.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"ecommerce-order-system/shared/pkg/models"
+)
+
+func main() {
+	out := flag.String("out", "schemas", "output directory")
+	protoOut := flag.String("proto", "", "write protobuf definitions to this file")
+	flag.Parse()
+
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		fail(err)
+	}
+	for _, spec := range models.Specs() {
+		versions := make([]int, 0, len(spec.Versions))
+		for v := range spec.Versions {
+			versions = append(versions, v)
+		}
+		slices.Sort(versions)
+
+		for _, v := range versions {
+			b, err := json.MarshalIndent(spec.Versions[v], "", "  ")
+			if err != nil {
+				fail(err)
+			}
+			name := filepath.Join(*out, fmt.Sprintf("%s.v%d.json", spec.Type, v))
+			if err := os.WriteFile(name, append(b, '\n'), 0o644); err != nil {
+				fail(err)
+			}
+			fmt.Println("wrote", name)
+		}
+	}
+
+	if *protoOut != "" {
+		if err := os.MkdirAll(filepath.Dir(*protoOut), 0o755); err != nil {
+			fail(err)
+		}
+		if err := os.WriteFile(*protoOut, []byte(models.ProtoSchema()), 0o644); err != nil {
+			fail(err)
+		}
+		fmt.Println("wrote", *protoOut)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "eventschemas:", err)
+	os.Exit(1)
+}